@@ -0,0 +1,224 @@
+package beamlines
+
+// constraints module validates constraints of schema record values
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Constraint rules reported by ValidationIssue
+const (
+	RuleMinimum    = "minimum"     // numeric value is below minimum
+	RuleMaximum    = "maximum"     // numeric value is above maximum
+	RulePattern    = "pattern"     // string value does not match pattern
+	RuleLength     = "length"      // string value length is out of bounds
+	RuleItems      = "items"       // number of list elements is out of bounds
+	RuleRequiredIf = "required_if" // key is required by values of other keys
+	RuleExcludes   = "excludes"    // mutually exclusive keys are used together
+)
+
+// compiled patterns of schema records
+var _patterns sync.Map
+
+// helper function to return compiled pattern
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := _patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	_patterns.Store(pattern, re)
+	return re, nil
+}
+
+// helper function to validate constraints of the value, constraints of list
+// types apply to list elements
+func validateConstraints(report *ValidationReport, m SchemaRecord, v any, path, key string) {
+	add := func(rule string, val any, format string, a ...any) {
+		report.Add(ValidationIssue{
+			Path:     path,
+			Key:      key,
+			Expected: m.Type,
+			Value:    val,
+			Rule:     rule,
+			Message:  fmt.Sprintf(format, a...),
+		})
+	}
+	values := []any{v}
+	if strings.HasPrefix(m.Type, "list") {
+		items, ok := listValues(v)
+		if !ok {
+			return
+		}
+		n := len(items)
+		if m.MinItems != nil && n < *m.MinItems {
+			add(RuleItems, v, "key %q has %d element(s), expect at least %d", m.Key, n, *m.MinItems)
+		}
+		if m.MaxItems != nil && n > *m.MaxItems {
+			add(RuleItems, v, "key %q has %d element(s), expect at most %d", m.Key, n, *m.MaxItems)
+		}
+		values = items
+	}
+	for _, val := range values {
+		if num, ok := numericValue(val); ok {
+			if m.Min != nil {
+				if m.ExclusiveMin && num <= *m.Min {
+					add(RuleMinimum, val, "value %v of key %q must be greater than %v", val, m.Key, *m.Min)
+				} else if num < *m.Min {
+					add(RuleMinimum, val, "value %v of key %q must be greater than or equal to %v", val, m.Key, *m.Min)
+				}
+			}
+			if m.Max != nil {
+				if m.ExclusiveMax && num >= *m.Max {
+					add(RuleMaximum, val, "value %v of key %q must be less than %v", val, m.Key, *m.Max)
+				} else if num > *m.Max {
+					add(RuleMaximum, val, "value %v of key %q must be less than or equal to %v", val, m.Key, *m.Max)
+				}
+			}
+		}
+		str, ok := val.(string)
+		if !ok {
+			continue
+		}
+		n := utf8.RuneCountInString(str)
+		if m.MinLength != nil && n < *m.MinLength {
+			add(RuleLength, val, "value %q of key %q has %d character(s), expect at least %d", str, m.Key, n, *m.MinLength)
+		}
+		if m.MaxLength != nil && n > *m.MaxLength {
+			add(RuleLength, val, "value %q of key %q has %d character(s), expect at most %d", str, m.Key, n, *m.MaxLength)
+		}
+		if m.Pattern != "" {
+			re, err := compilePattern(m.Pattern)
+			if err != nil {
+				add(RuleSchema, val, "invalid pattern %q of key %q: %v", m.Pattern, m.Key, err)
+			} else if !re.MatchString(str) {
+				add(RulePattern, val, "value %q of key %q does not match pattern %q", str, m.Key, m.Pattern)
+			}
+		}
+	}
+}
+
+// helper function to validate conditional requirements and mutually exclusive
+// keys of the record, conditional requirements of optional keys are checked
+// only if required is true, e.g. they are not checked for template records
+func (s *Schema) validateDependencies(report *ValidationReport, rec map[string]any, path, prefix string, required bool) {
+	var keys []string
+	for k := range s.Map {
+		// composed keys are validated within their sub-structs
+		if k != "" && !strings.Contains(k, ".") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	reported := make(map[string]bool)
+	for _, k := range keys {
+		m := s.Map[k]
+		_, present := rec[k]
+		if required && !present && m.Optional && len(m.RequiredIf) > 0 && conditionsMet(m.RequiredIf, rec) {
+			report.Add(ValidationIssue{
+				Path:     path + "." + k,
+				Key:      prefix + k,
+				Expected: m.Type,
+				Rule:     RuleRequiredIf,
+				Message:  fmt.Sprintf("key %q is required when %s", k, conditions(m.RequiredIf)),
+			})
+		}
+		if !present {
+			continue
+		}
+		for _, ek := range m.Excludes {
+			pair := []string{k, ek}
+			sort.Strings(pair)
+			id := strings.Join(pair, ",")
+			if _, ok := rec[ek]; !ok || ek == k || reported[id] {
+				continue
+			}
+			reported[id] = true
+			report.Add(ValidationIssue{
+				Path:    path + "." + k,
+				Key:     prefix + k,
+				Value:   rec[k],
+				Rule:    RuleExcludes,
+				Message: fmt.Sprintf("keys %q and %q are mutually exclusive", k, ek),
+			})
+		}
+	}
+}
+
+// helper function to check if record values match all conditions, condition
+// with list of values matches any of them
+func conditionsMet(conds map[string]any, rec map[string]any) bool {
+	for key, cond := range conds {
+		val, ok := rec[key]
+		if !ok {
+			return false
+		}
+		allowed, isList := listValues(cond)
+		if !isList {
+			allowed = []any{cond}
+		}
+		matched := false
+		for _, a := range allowed {
+			if fmt.Sprintf("%v", a) == fmt.Sprintf("%v", val) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// helper function to describe conditions
+func conditions(conds map[string]any) string {
+	var out []string
+	for key, cond := range conds {
+		if vals, ok := listValues(cond); ok {
+			out = append(out, fmt.Sprintf("%s is one of %v", key, vals))
+		} else {
+			out = append(out, fmt.Sprintf("%s is %v", key, cond))
+		}
+	}
+	sort.Strings(out)
+	return strings.Join(out, " and ")
+}
+
+// helper function to return numeric value as float64
+func numericValue(v any) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// helper function to return elements of slice value
+func listValues(v any) ([]any, bool) {
+	if v == nil {
+		return nil, false
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	out := make([]any, rv.Len())
+	for i := range out {
+		out[i] = rv.Index(i).Interface()
+	}
+	return out, true
+}
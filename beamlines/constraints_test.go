@@ -0,0 +1,108 @@
+package beamlines
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+)
+
+// TestConstraints tests validation of schema record constraints
+func TestConstraints(t *testing.T) {
+	config := os.Getenv("FOXDEN_CONFIG")
+	if cobj, err := srvConfig.ParseConfig(config); err == nil {
+		srvConfig.Config = &cobj
+	}
+
+	schemaFile := filepath.Join(t.TempDir(), "schema.json")
+	schemaRecords := `[
+		{"key": "did", "type": "string", "optional": true},
+		{"key": "energy", "type": "float64", "optional": true, "min": 0, "exclusiveMin": true, "max": 100},
+		{"key": "btr", "type": "string", "optional": true, "pattern": "^[a-z]+-[0-9]+$", "minLength": 5, "maxLength": 12},
+		{"key": "angles", "type": "list_float", "optional": true, "min": -90, "max": 90, "minItems": 1, "maxItems": 3},
+		{"key": "Calibration", "type": "bool", "optional": true},
+		{"key": "ReferenceCalibrantScanNumber", "type": "int64", "optional": true, "requiredIf": {"Calibration": true}},
+		{"key": "sample_name", "type": "string", "optional": true, "excludes": ["sample_id"]},
+		{"key": "sample_id", "type": "int64", "optional": true}
+	]`
+	if err := os.WriteFile(schemaFile, []byte(schemaRecords), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := &Schema{FileName: schemaFile}
+
+	tests := []struct {
+		name  string
+		rec   map[string]any
+		rules []string
+	}{
+		{"valid", map[string]any{
+			"energy": 10.5, "btr": "abc-1234", "angles": []any{-10.0, 45.0},
+			"Calibration": true, "ReferenceCalibrantScanNumber": int64(3), "sample_name": "Si",
+		}, nil},
+		{"exclusive minimum", map[string]any{"energy": 0.0}, []string{RuleMinimum}},
+		{"inclusive maximum", map[string]any{"energy": 100.0}, nil},
+		{"maximum", map[string]any{"energy": 100.5}, []string{RuleMaximum}},
+		{"pattern", map[string]any{"btr": "ABC-1234"}, []string{RulePattern}},
+		{"length", map[string]any{"btr": "a-1"}, []string{RuleLength}},
+		{"list elements", map[string]any{"angles": []any{-91.0, 91.0}}, []string{RuleMaximum, RuleMinimum}},
+		{"list length", map[string]any{"angles": []any{}}, []string{RuleItems}},
+		{"required if", map[string]any{"Calibration": true}, []string{RuleRequiredIf}},
+		{"condition not met", map[string]any{"Calibration": false}, nil},
+		{"mutually exclusive", map[string]any{"sample_name": "Si", "sample_id": int64(1)}, []string{RuleExcludes}},
+	}
+	for _, tt := range tests {
+		report := s.ValidateReport(tt.rec)
+		var rules []string
+		for _, issue := range report.Errors() {
+			rules = append(rules, issue.Rule)
+		}
+		sort.Strings(rules)
+		if !reflect.DeepEqual(rules, tt.rules) {
+			t.Errorf("%s: got rules %v, expect %v, issues %+v", tt.name, rules, tt.rules, report.Issues)
+		}
+		if err := s.Validate(tt.rec); (err == nil) != (len(tt.rules) == 0) {
+			t.Errorf("%s: wrong Validate error %v", tt.name, err)
+		}
+	}
+
+	// conditional requirements are not applied to template records
+	if msg := s.ValidateTmplRecord(map[string]any{"Calibration": true}); msg != "" {
+		t.Errorf("template record should be valid, got %s", msg)
+	}
+
+	// constraints are preserved by JSON Schema conversion
+	js, err := ToJSONSchema(schemaFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(js)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, _, err := FromJSONSchema(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var orig []SchemaRecord
+	if err := json.Unmarshal([]byte(schemaRecords), &orig); err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(orig) {
+		t.Fatalf("wrong number of converted records %d", len(records))
+	}
+	for i, r := range records {
+		o := orig[i]
+		if !reflect.DeepEqual(r.Min, o.Min) || !reflect.DeepEqual(r.Max, o.Max) ||
+			r.ExclusiveMin != o.ExclusiveMin || r.ExclusiveMax != o.ExclusiveMax ||
+			r.Pattern != o.Pattern || !reflect.DeepEqual(r.MinLength, o.MinLength) ||
+			!reflect.DeepEqual(r.MaxLength, o.MaxLength) || !reflect.DeepEqual(r.MinItems, o.MinItems) ||
+			!reflect.DeepEqual(r.MaxItems, o.MaxItems) || !reflect.DeepEqual(r.RequiredIf, o.RequiredIf) ||
+			!reflect.DeepEqual(r.Excludes, o.Excludes) {
+			t.Errorf("wrong constraints of converted record %+v, expect %+v", r, o)
+		}
+	}
+}
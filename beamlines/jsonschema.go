@@ -0,0 +1,594 @@
+package beamlines
+
+// jsonschema module converts FOXDEN schema files to and from JSON Schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// JSONSchemaDialect defines JSON Schema dialect of exported schemas
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// ToJSONSchema converts FOXDEN schema file into JSON Schema draft 2020-12.
+// Included files are inlined, struct and list_struct records are converted
+// into nested objects, conditional requirements and mutually exclusive keys
+// are converted into allOf rules and FOXDEN specific attributes are kept in
+// x-type, x-units, x-section, x-multiple, x-schema and x-order extensions.
+func ToJSONSchema(fname string) (map[string]any, error) {
+	obj, err := jsonSchemaObject(fname, make(map[string]bool))
+	if err != nil {
+		return nil, fmt.Errorf("[golib.beamlines.ToJSONSchema] jsonSchemaObject error: %w", err)
+	}
+	obj["$schema"] = JSONSchemaDialect
+	obj["title"] = SchemaName(fname)
+	return obj, nil
+}
+
+// JSONSchema returns JSON Schema representation of the schema
+func (s *Schema) JSONSchema() (map[string]any, error) {
+	return ToJSONSchema(s.FileName)
+}
+
+// FromJSONSchema converts JSON Schema into FOXDEN schema records. Nested
+// objects are converted into struct or list_struct records whose records are
+// returned in a map of nested schema file names, the file names are taken
+// from x-schema extension or composed from the key name.
+func FromJSONSchema(data []byte) ([]SchemaRecord, map[string][]SchemaRecord, error) {
+	var root map[string]any
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, nil, fmt.Errorf("[golib.beamlines.FromJSONSchema] json.Unmarshal error: %w", err)
+	}
+	if t, ok := root["type"]; ok && t != "object" {
+		return nil, nil, fmt.Errorf("[golib.beamlines.FromJSONSchema] JSON Schema of type %v is not an object", t)
+	}
+	conv := jsonSchemaConverter{root: root, nested: make(map[string][]SchemaRecord)}
+	records, err := conv.records(root, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("[golib.beamlines.FromJSONSchema] error: %w", err)
+	}
+	return records, conv.nested, nil
+}
+
+// ImportJSONSchema converts JSON Schema into FOXDEN schema file fname, nested
+// schema files are written into the same directory
+func ImportJSONSchema(data []byte, fname string) error {
+	records, nested, err := FromJSONSchema(data)
+	if err != nil {
+		return fmt.Errorf("[golib.beamlines.ImportJSONSchema] FromJSONSchema error: %w", err)
+	}
+	fdir := filepath.Dir(fname)
+	for name, recs := range nested {
+		if err := writeSchemaRecords(filepath.Join(fdir, name), recs); err != nil {
+			return fmt.Errorf("[golib.beamlines.ImportJSONSchema] writeSchemaRecords error: %w", err)
+		}
+	}
+	if err := writeSchemaRecords(fname, records); err != nil {
+		return fmt.Errorf("[golib.beamlines.ImportJSONSchema] writeSchemaRecords error: %w", err)
+	}
+	return nil
+}
+
+// helper function to write schema records into JSON file
+func writeSchemaRecords(fname string, records []SchemaRecord) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fname, data, 0644)
+}
+
+// helper function to resolve file name of included or nested schema, relative
+// file names are resolved with respect to directory of parent schema
+func nestedSchemaFile(fdir, fname string) string {
+	if _, err := os.Stat(fname); err == nil || filepath.IsAbs(fname) {
+		return fname
+	}
+	return filepath.Join(fdir, fname)
+}
+
+// helper function to convert records of schema file into JSON Schema object
+func jsonSchemaObject(fname string, seen map[string]bool) (map[string]any, error) {
+	if seen[fname] {
+		return nil, fmt.Errorf("circular inclusion of schema file %s", fname)
+	}
+	seen[fname] = true
+	defer delete(seen, fname)
+
+	records, err := loadNestedRecords(fname)
+	if err != nil {
+		return nil, err
+	}
+	fdir := filepath.Dir(fname)
+	props := make(map[string]any)
+	var order, required []string
+	var rules []any
+	for _, r := range records {
+		if r.File != "" && r.Schema == "" {
+			// included schema records are inlined into parent object
+			obj, err := jsonSchemaObject(nestedSchemaFile(fdir, r.File), seen)
+			if err != nil {
+				return nil, err
+			}
+			for _, key := range obj["x-order"].([]string) {
+				props[key] = obj["properties"].(map[string]any)[key]
+				order = append(order, key)
+			}
+			if req, ok := obj["required"].([]string); ok {
+				required = append(required, req...)
+			}
+			if allOf, ok := obj["allOf"].([]any); ok {
+				rules = append(rules, allOf...)
+			}
+			continue
+		}
+		if r.Key == "" {
+			continue
+		}
+		prop, err := jsonSchemaProperty(r, fdir, seen)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := props[r.Key]; !ok {
+			order = append(order, r.Key)
+		}
+		props[r.Key] = prop
+		if !r.Optional {
+			required = append(required, r.Key)
+		}
+		rules = append(rules, jsonSchemaRules(r)...)
+	}
+	obj := map[string]any{
+		"type":       "object",
+		"properties": props,
+		"x-order":    order,
+	}
+	if len(required) > 0 {
+		obj["required"] = required
+	}
+	if len(rules) > 0 {
+		obj["allOf"] = rules
+	}
+	return obj, nil
+}
+
+// helper function to convert conditional requirements and mutually exclusive
+// keys of schema record into if/then and not/required JSON Schema rules
+func jsonSchemaRules(r SchemaRecord) []any {
+	var rules []any
+	if len(r.RequiredIf) > 0 {
+		conds := make(map[string]any)
+		var keys []string
+		for key, cond := range r.RequiredIf {
+			if vals, ok := listValues(cond); ok {
+				conds[key] = map[string]any{"enum": vals}
+			} else {
+				conds[key] = map[string]any{"const": cond}
+			}
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		rules = append(rules, map[string]any{
+			"if":   map[string]any{"properties": conds, "required": keys},
+			"then": map[string]any{"required": []string{r.Key}},
+		})
+	}
+	for _, key := range r.Excludes {
+		rules = append(rules, map[string]any{
+			"not": map[string]any{"required": []string{r.Key, key}},
+		})
+	}
+	return rules
+}
+
+// helper function to convert schema record into JSON Schema property
+func jsonSchemaProperty(r SchemaRecord, fdir string, seen map[string]bool) (map[string]any, error) {
+	prop := make(map[string]any)
+	target := prop // schema which holds allowed values
+	switch {
+	case r.Type == "struct" || r.Type == "list_struct":
+		obj := map[string]any{"type": "object", "properties": map[string]any{}}
+		if r.Schema != "" {
+			var err error
+			obj, err = jsonSchemaObject(nestedSchemaFile(fdir, r.Schema), seen)
+			if err != nil {
+				return nil, err
+			}
+			prop["x-schema"] = r.Schema
+		}
+		if r.Type == "struct" {
+			for k, v := range obj {
+				prop[k] = v
+			}
+		} else {
+			prop["type"] = "array"
+			prop["items"] = obj
+		}
+	case strings.HasPrefix(r.Type, "list_"):
+		items := make(map[string]any)
+		if t := jsonType(strings.TrimPrefix(r.Type, "list_")); t != "" {
+			items["type"] = t
+		}
+		prop["type"] = "array"
+		prop["items"] = items
+		target = items
+	default:
+		if t := jsonType(r.Type); t != "" {
+			prop["type"] = t
+		}
+	}
+	if foxdenType(prop) != r.Type {
+		prop["x-type"] = r.Type
+	}
+	switch v := r.Value.(type) {
+	case nil:
+	case []any:
+		if len(v) > 0 {
+			target["enum"] = v
+		}
+	case string:
+		if v != "" {
+			target["const"] = v
+		}
+	default:
+		target["const"] = v
+	}
+	if r.Min != nil {
+		if r.ExclusiveMin {
+			target["exclusiveMinimum"] = *r.Min
+		} else {
+			target["minimum"] = *r.Min
+		}
+	}
+	if r.Max != nil {
+		if r.ExclusiveMax {
+			target["exclusiveMaximum"] = *r.Max
+		} else {
+			target["maximum"] = *r.Max
+		}
+	}
+	if r.Pattern != "" {
+		target["pattern"] = r.Pattern
+	}
+	if r.MinLength != nil {
+		target["minLength"] = *r.MinLength
+	}
+	if r.MaxLength != nil {
+		target["maxLength"] = *r.MaxLength
+	}
+	if r.MinItems != nil {
+		prop["minItems"] = *r.MinItems
+	}
+	if r.MaxItems != nil {
+		prop["maxItems"] = *r.MaxItems
+	}
+	if r.Description != "" {
+		prop["description"] = r.Description
+	}
+	if r.Placeholder != "" {
+		prop["examples"] = []any{r.Placeholder}
+	}
+	if r.Units != "" {
+		prop["x-units"] = r.Units
+	}
+	if r.Section != "" {
+		prop["x-section"] = r.Section
+	}
+	if r.Multiple {
+		prop["x-multiple"] = true
+	}
+	return prop, nil
+}
+
+// helper function to convert FOXDEN scalar data type into JSON Schema type,
+// empty type is returned for any data type
+func jsonType(dtype string) string {
+	switch {
+	case dtype == "any" || dtype == "":
+		return ""
+	case dtype == "bool":
+		return "boolean"
+	case strings.HasPrefix(dtype, "int"), strings.HasPrefix(dtype, "uint"):
+		return "integer"
+	case strings.HasPrefix(dtype, "float"):
+		return "number"
+	case dtype == "struct":
+		return "object"
+	}
+	return "string"
+}
+
+// helper function to return FOXDEN data type of JSON Schema property
+func foxdenType(prop map[string]any) string {
+	if dtype, ok := prop["x-type"].(string); ok && dtype != "" {
+		return dtype
+	}
+	switch propType(prop) {
+	case "string":
+		return "string"
+	case "integer":
+		return "int64"
+	case "number":
+		return "float64"
+	case "boolean":
+		return "bool"
+	case "object":
+		return "struct"
+	case "array":
+		items, _ := prop["items"].(map[string]any)
+		switch propType(items) {
+		case "object":
+			return "list_struct"
+		case "integer":
+			return "list_int"
+		case "number":
+			return "list_float"
+		}
+		return "list_str"
+	}
+	return "any"
+}
+
+// helper function to return JSON Schema type of property, nullable types
+// like ["string", "null"] are reduced to their non-null type
+func propType(prop map[string]any) string {
+	switch t := prop["type"].(type) {
+	case string:
+		return t
+	case []any:
+		for _, v := range t {
+			if s, ok := v.(string); ok && s != "null" {
+				return s
+			}
+		}
+	}
+	if _, ok := prop["properties"]; ok {
+		return "object"
+	}
+	return ""
+}
+
+// jsonSchemaConverter converts JSON Schema objects into FOXDEN schema records
+type jsonSchemaConverter struct {
+	root   map[string]any            // root JSON Schema used to resolve references
+	nested map[string][]SchemaRecord // records of nested schema files
+}
+
+// helper function to resolve local reference of JSON Schema, e.g. #/$defs/scan
+func (c *jsonSchemaConverter) resolve(prop map[string]any, depth int) (map[string]any, string, error) {
+	ref, ok := prop["$ref"].(string)
+	if !ok {
+		return prop, "", nil
+	}
+	if depth > 32 {
+		return nil, "", fmt.Errorf("too deep references of %s", ref)
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, "", fmt.Errorf("unsupported reference %s", ref)
+	}
+	var node any = c.root
+	var name string
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		name = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		m, ok := node.(map[string]any)
+		if !ok {
+			return nil, "", fmt.Errorf("unable to resolve reference %s", ref)
+		}
+		if node, ok = m[name]; !ok {
+			return nil, "", fmt.Errorf("unable to resolve reference %s", ref)
+		}
+	}
+	target, ok := node.(map[string]any)
+	if !ok {
+		return nil, "", fmt.Errorf("reference %s is not a schema", ref)
+	}
+	// keep annotations of referencing schema, e.g. description or x-units
+	out := make(map[string]any)
+	for k, v := range target {
+		out[k] = v
+	}
+	for k, v := range prop {
+		if k != "$ref" {
+			out[k] = v
+		}
+	}
+	out, _, err := c.resolve(out, depth+1)
+	return out, name, err
+}
+
+// helper function to convert properties of JSON Schema object into schema
+// records ordered by x-order extension and key names
+func (c *jsonSchemaConverter) records(obj map[string]any, depth int) ([]SchemaRecord, error) {
+	props, _ := obj["properties"].(map[string]any)
+	required := make(map[string]bool)
+	if req, ok := obj["required"].([]any); ok {
+		for _, k := range req {
+			required[fmt.Sprintf("%v", k)] = true
+		}
+	}
+	var keys []string
+	seen := make(map[string]bool)
+	if order, ok := obj["x-order"].([]any); ok {
+		for _, k := range order {
+			key := fmt.Sprintf("%v", k)
+			if _, ok := props[key]; ok && !seen[key] {
+				keys = append(keys, key)
+				seen[key] = true
+			}
+		}
+	}
+	var rest []string
+	for key := range props {
+		if !seen[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+	keys = append(keys, rest...)
+
+	var records []SchemaRecord
+	for _, key := range keys {
+		prop, ok := props[key].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("property %s is not a schema", key)
+		}
+		rec, err := c.record(key, prop, depth)
+		if err != nil {
+			return nil, fmt.Errorf("property %s: %w", key, err)
+		}
+		rec.Optional = !required[key]
+		records = append(records, rec)
+	}
+	applyJSONSchemaRules(obj, records)
+	return records, nil
+}
+
+// helper function to convert if/then and not/required rules of JSON Schema
+// object into conditional requirements and mutually exclusive keys of records
+func applyJSONSchemaRules(obj map[string]any, records []SchemaRecord) {
+	index := make(map[string]int)
+	for i, r := range records {
+		index[r.Key] = i
+	}
+	allOf, _ := obj["allOf"].([]any)
+	for _, item := range allOf {
+		rule, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		if not, ok := rule["not"].(map[string]any); ok {
+			keys := stringList(not["required"])
+			if len(keys) == 2 {
+				if i, ok := index[keys[0]]; ok {
+					records[i].Excludes = append(records[i].Excludes, keys[1])
+				}
+			}
+			continue
+		}
+		cond, _ := rule["if"].(map[string]any)
+		then, _ := rule["then"].(map[string]any)
+		if cond == nil || then == nil {
+			continue
+		}
+		props, _ := cond["properties"].(map[string]any)
+		conds := make(map[string]any)
+		for key, p := range props {
+			pm, _ := p.(map[string]any)
+			if v, ok := pm["const"]; ok {
+				conds[key] = v
+			} else if v, ok := pm["enum"]; ok {
+				conds[key] = v
+			}
+		}
+		if len(conds) == 0 {
+			continue
+		}
+		for _, key := range stringList(then["required"]) {
+			if i, ok := index[key]; ok {
+				if records[i].RequiredIf == nil {
+					records[i].RequiredIf = make(map[string]any)
+				}
+				for k, v := range conds {
+					records[i].RequiredIf[k] = v
+				}
+			}
+		}
+	}
+}
+
+// helper function to return integer keyword of JSON Schema
+func intKeyword(prop map[string]any, name string) *int {
+	if v, ok := prop[name].(float64); ok {
+		n := int(v)
+		return &n
+	}
+	return nil
+}
+
+// helper function to convert JSON list into list of strings
+func stringList(v any) []string {
+	var out []string
+	vals, _ := v.([]any)
+	for _, val := range vals {
+		out = append(out, fmt.Sprintf("%v", val))
+	}
+	return out
+}
+
+// helper function to convert JSON Schema property into schema record
+func (c *jsonSchemaConverter) record(key string, prop map[string]any, depth int) (SchemaRecord, error) {
+	rec := SchemaRecord{Key: key}
+	prop, name, err := c.resolve(prop, 0)
+	if err != nil {
+		return rec, err
+	}
+	rec.Type = foxdenType(prop)
+	target := prop // schema which holds allowed values
+	if strings.HasPrefix(rec.Type, "list_") {
+		items, _ := prop["items"].(map[string]any)
+		if items, itemsName, err := c.resolve(items, 0); err == nil {
+			target = items
+			if name == "" {
+				name = itemsName
+			}
+		} else {
+			return rec, err
+		}
+	}
+	if rec.Type == "struct" || rec.Type == "list_struct" {
+		if depth > 32 {
+			return rec, errors.New("too deep nested objects")
+		}
+		rec.Schema, _ = prop["x-schema"].(string)
+		if rec.Schema == "" {
+			if name == "" {
+				name = key
+			}
+			rec.Schema = name + ".json"
+		}
+		if _, ok := c.nested[rec.Schema]; !ok {
+			nested, err := c.records(target, depth+1)
+			if err != nil {
+				return rec, err
+			}
+			c.nested[rec.Schema] = nested
+		}
+	} else if enum, ok := target["enum"].([]any); ok {
+		rec.Value = enum
+	} else if val, ok := target["const"]; ok {
+		rec.Value = val
+	}
+	if v, ok := target["minimum"].(float64); ok {
+		rec.Min = &v
+	}
+	if v, ok := target["exclusiveMinimum"].(float64); ok {
+		rec.Min, rec.ExclusiveMin = &v, true
+	}
+	if v, ok := target["maximum"].(float64); ok {
+		rec.Max = &v
+	}
+	if v, ok := target["exclusiveMaximum"].(float64); ok {
+		rec.Max, rec.ExclusiveMax = &v, true
+	}
+	rec.Pattern, _ = target["pattern"].(string)
+	rec.MinLength = intKeyword(target, "minLength")
+	rec.MaxLength = intKeyword(target, "maxLength")
+	rec.MinItems = intKeyword(prop, "minItems")
+	rec.MaxItems = intKeyword(prop, "maxItems")
+	rec.Description, _ = prop["description"].(string)
+	if rec.Description == "" {
+		rec.Description, _ = prop["title"].(string)
+	}
+	if examples, ok := prop["examples"].([]any); ok && len(examples) > 0 {
+		rec.Placeholder = fmt.Sprintf("%v", examples[0])
+	}
+	rec.Units, _ = prop["x-units"].(string)
+	rec.Section, _ = prop["x-section"].(string)
+	rec.Multiple, _ = prop["x-multiple"].(bool)
+	return rec, nil
+}
@@ -0,0 +1,177 @@
+package beamlines
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+)
+
+// TestJSONSchema tests conversion of FOXDEN schema to JSON Schema and back
+func TestJSONSchema(t *testing.T) {
+	config := os.Getenv("FOXDEN_CONFIG")
+	if cobj, err := srvConfig.ParseConfig(config); err == nil {
+		srvConfig.Config = &cobj
+	}
+
+	tempDir := t.TempDir()
+	commonFile := filepath.Join(tempDir, "common.json")
+	scanFile := filepath.Join(tempDir, "scan.json")
+	schemaFile := filepath.Join(tempDir, "schema.json")
+
+	commonRecords := `[
+		{"key": "did", "type": "string", "section": "User", "description": "Dataset IDentifier", "placeholder": "/beamline=demo"}
+	]`
+	scanRecords := `[
+		{"key": "energy", "type": "float64", "units": "keV"},
+		{"key": "detector", "type": "string", "optional": true, "value": ["eiger", "pilatus"]}
+	]`
+	schemaRecords := `[
+		{"file": "common.json"},
+		{"key": "cycle", "type": "string", "section": "Experiment", "value": ["2024-3", "2025-1"]},
+		{"key": "temperature", "type": "float64", "optional": true, "units": "K", "section": "Sample"},
+		{"key": "nscans", "type": "int", "optional": true},
+		{"key": "tags", "type": "list_str", "optional": true, "multiple": true},
+		{"key": "scans", "type": "list_struct", "schema": "scan.json", "section": "Scans"}
+	]`
+	for fname, data := range map[string]string{
+		commonFile: commonRecords, scanFile: scanRecords, schemaFile: schemaRecords,
+	} {
+		if err := os.WriteFile(fname, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	js, err := ToJSONSchema(schemaFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if js["$schema"] != JSONSchemaDialect {
+		t.Errorf("wrong $schema %v", js["$schema"])
+	}
+	props := js["properties"].(map[string]any)
+	if len(props) != 6 {
+		t.Fatalf("expected 6 properties, got %d: %v", len(props), props)
+	}
+	cycle := props["cycle"].(map[string]any)
+	if cycle["type"] != "string" || cycle["x-section"] != "Experiment" || cycle["enum"] == nil {
+		t.Errorf("wrong cycle property %+v", cycle)
+	}
+	temp := props["temperature"].(map[string]any)
+	if temp["type"] != "number" || temp["x-units"] != "K" || temp["x-type"] != nil {
+		t.Errorf("wrong temperature property %+v", temp)
+	}
+	scans := props["scans"].(map[string]any)
+	items := scans["items"].(map[string]any)
+	if scans["type"] != "array" || items["type"] != "object" || scans["x-schema"] != "scan.json" {
+		t.Errorf("wrong scans property %+v", scans)
+	}
+	energy := items["properties"].(map[string]any)["energy"].(map[string]any)
+	if energy["type"] != "number" || energy["x-units"] != "keV" {
+		t.Errorf("wrong scans.energy property %+v", energy)
+	}
+	nscans := props["nscans"].(map[string]any)
+	if nscans["type"] != "integer" || nscans["x-type"] != "int" {
+		t.Errorf("wrong nscans property %+v", nscans)
+	}
+	if !reflect.DeepEqual(js["required"], []string{"did", "cycle", "scans"}) {
+		t.Errorf("wrong required keys %v", js["required"])
+	}
+
+	// convert JSON Schema back into FOXDEN schema files and load them
+	data, err := json.Marshal(js)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outFile := filepath.Join(t.TempDir(), "imported.json")
+	if err := ImportJSONSchema(data, outFile); err != nil {
+		t.Fatal(err)
+	}
+	records, err := loadNestedRecords(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, r := range records {
+		keys = append(keys, r.Key)
+	}
+	expect := []string{"did", "cycle", "temperature", "nscans", "tags", "scans"}
+	if !reflect.DeepEqual(keys, expect) {
+		t.Errorf("wrong order of imported keys %v, expect %v", keys, expect)
+	}
+	s := &Schema{FileName: outFile}
+	if err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	for key, rec := range map[string]SchemaRecord{
+		"did":          {Type: "string", Section: "User", Placeholder: "/beamline=demo"},
+		"cycle":        {Type: "string", Section: "Experiment", Value: []any{"2024-3", "2025-1"}},
+		"temperature":  {Type: "float64", Optional: true, Units: "K", Section: "Sample"},
+		"nscans":       {Type: "int", Optional: true},
+		"tags":         {Type: "list_str", Optional: true, Multiple: true},
+		"scans":        {Type: "list_struct", Section: "Scans", Schema: "scan.json"},
+		"scans.energy": {Type: "float64", Units: "keV"},
+	} {
+		r, ok := s.Map[key]
+		if !ok {
+			t.Errorf("imported schema has no key %s", key)
+			continue
+		}
+		if r.Type != rec.Type || r.Optional != rec.Optional || r.Units != rec.Units ||
+			r.Multiple != rec.Multiple || r.Placeholder != rec.Placeholder ||
+			r.Schema != rec.Schema || !reflect.DeepEqual(r.Value, rec.Value) {
+			t.Errorf("wrong imported record of %s: %+v, expect %+v", key, r, rec)
+		}
+		if key != "scans.energy" && r.Section != rec.Section {
+			t.Errorf("wrong section of %s: %s, expect %s", key, r.Section, rec.Section)
+		}
+	}
+	if err := s.Validate(map[string]any{
+		"did": "/beamline=demo", "cycle": "2025-1",
+		"scans": []any{map[string]any{"energy": 10.5}},
+	}); err != nil {
+		t.Errorf("imported schema fails validation: %v", err)
+	}
+}
+
+// TestFromJSONSchemaRefs tests conversion of external JSON Schema with references
+func TestFromJSONSchemaRefs(t *testing.T) {
+	data := `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"type": "object",
+		"required": ["sample"],
+		"properties": {
+			"sample": {"$ref": "#/$defs/sample", "description": "sample info"},
+			"count": {"type": ["integer", "null"], "const": 3}
+		},
+		"$defs": {
+			"sample": {
+				"type": "object",
+				"properties": {"name": {"type": "string", "examples": ["Si"]}}
+			}
+		}
+	}`
+	records, nested, err := FromJSONSchema([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Key != "count" || records[1].Key != "sample" {
+		t.Fatalf("wrong records %+v", records)
+	}
+	if records[0].Type != "int64" || records[0].Value != 3.0 || !records[0].Optional {
+		t.Errorf("wrong count record %+v", records[0])
+	}
+	sample := records[1]
+	if sample.Type != "struct" || sample.Schema != "sample.json" || sample.Optional || sample.Description != "sample info" {
+		t.Errorf("wrong sample record %+v", sample)
+	}
+	if recs := nested["sample.json"]; len(recs) != 1 || recs[0].Placeholder != "Si" {
+		t.Errorf("wrong nested records %+v", nested)
+	}
+	if _, _, err := FromJSONSchema([]byte(`{"properties": {"a": {"$ref": "#/$defs/missing"}}}`)); err == nil {
+		t.Error("expected error of missing reference")
+	}
+}
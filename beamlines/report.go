@@ -0,0 +1,157 @@
+package beamlines
+
+// report module provides structured results of schema validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"path/filepath"
+	"strings"
+)
+
+// Severity levels of validation issues
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Validation rules reported by ValidationIssue
+const (
+	RuleSchema   = "schema"      // schema can not be loaded
+	RuleUnknown  = "unknown_key" // key is not defined in schema
+	RuleType     = "type"        // value does not match data type of the key
+	RuleValue    = "value"       // value is not among allowed values of the key
+	RuleRequired = "required"    // mandatory key is missing
+	RuleStruct   = "struct"      // struct value does not match its sub-schema
+)
+
+// ValidationIssue represents single problem found by schema validation
+type ValidationIssue struct {
+	Path     string `json:"path"`               // JSON path of the value, e.g. $.scans[0].energy
+	Key      string `json:"key"`                // schema key, e.g. scans.energy
+	Expected string `json:"expected,omitempty"` // expected data type of the key
+	Value    any    `json:"value,omitempty"`    // actual value
+	Rule     string `json:"rule"`               // violated rule
+	Severity string `json:"severity"`           // error or warning
+	Message  string `json:"message"`
+}
+
+// ValidationReport represents all issues found by schema validation, it
+// implements error interface and can be extracted from errors of
+// Schema.Validate via errors.As
+type ValidationReport struct {
+	Schema string            `json:"schema"`
+	Issues []ValidationIssue `json:"issues"`
+}
+
+// Add adds issue to the report, issues without severity are errors
+func (r *ValidationReport) Add(issue ValidationIssue) {
+	if issue.Severity == "" {
+		issue.Severity = SeverityError
+	}
+	r.Issues = append(r.Issues, issue)
+}
+
+// Valid returns true if report does not contain errors
+func (r *ValidationReport) Valid() bool {
+	return len(r.Errors()) == 0
+}
+
+// Errors returns issues with error severity
+func (r *ValidationReport) Errors() []ValidationIssue {
+	return r.filter(SeverityError)
+}
+
+// Warnings returns issues with warning severity
+func (r *ValidationReport) Warnings() []ValidationIssue {
+	return r.filter(SeverityWarning)
+}
+
+// helper function to return issues of given severity
+func (r *ValidationReport) filter(severity string) []ValidationIssue {
+	var out []ValidationIssue
+	for _, issue := range r.Issues {
+		if issue.Severity == severity {
+			out = append(out, issue)
+		}
+	}
+	return out
+}
+
+// Error implements error interface
+func (r *ValidationReport) Error() string {
+	return r.String()
+}
+
+// String returns human-readable multi-line report of errors, it is empty for
+// valid records
+func (r *ValidationReport) String() string {
+	errs := r.Errors()
+	if len(errs) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("validation failed against schema %s (%d error(s)):\n",
+		filepath.Base(r.Schema), len(errs)))
+	for i, e := range errs {
+		sb.WriteString(fmt.Sprintf("  [%d] %s: %s\n", i+1, e.Path, e.Message))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// MarshalJSON provides JSON representation of the report along with its
+// validity and number of errors and warnings
+func (r ValidationReport) MarshalJSON() ([]byte, error) {
+	issues := r.Issues
+	if issues == nil {
+		issues = []ValidationIssue{}
+	}
+	return json.Marshal(struct {
+		Schema    string            `json:"schema"`
+		Valid     bool              `json:"valid"`
+		NErrors   int               `json:"nerrors"`
+		NWarnings int               `json:"nwarnings"`
+		Issues    []ValidationIssue `json:"issues"`
+	}{r.Schema, r.Valid(), len(r.Errors()), len(r.Warnings()), issues})
+}
+
+// JSON returns JSON representation of the report
+func (r *ValidationReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// HTML returns HTML table of report issues
+func (r *ValidationReport) HTML() (template.HTML, error) {
+	var buf bytes.Buffer
+	if err := reportTmpl.Execute(&buf, r); err != nil {
+		return "", fmt.Errorf("[golib.beamlines.ValidationReport.HTML] template error: %w", err)
+	}
+	return template.HTML(buf.String()), nil
+}
+
+// template of HTML representation of validation report
+var reportTmpl = template.Must(template.New("report").Funcs(template.FuncMap{
+	"base": filepath.Base,
+	"value": func(v any) string {
+		if v == nil {
+			return ""
+		}
+		return fmt.Sprintf("%v", v)
+	},
+}).Parse(`<div class="validation-report">
+{{- if .Valid}}
+<p class="validation-valid">record is valid against schema {{base .Schema}}</p>
+{{- else}}
+<p class="validation-invalid">validation failed against schema {{base .Schema}} ({{len .Errors}} error(s))</p>
+{{- end}}
+{{- if .Issues}}
+<table class="validation-issues">
+<tr><th>Severity</th><th>Path</th><th>Key</th><th>Expected</th><th>Value</th><th>Rule</th><th>Message</th></tr>
+{{- range .Issues}}
+<tr class="validation-{{.Severity}}"><td>{{.Severity}}</td><td>{{.Path}}</td><td>{{.Key}}</td><td>{{.Expected}}</td><td>{{value .Value}}</td><td>{{.Rule}}</td><td>{{.Message}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+</div>`))
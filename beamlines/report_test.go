@@ -0,0 +1,118 @@
+package beamlines
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+)
+
+// TestValidationReport tests structured report of schema validation
+func TestValidationReport(t *testing.T) {
+	config := os.Getenv("FOXDEN_CONFIG")
+	if cobj, err := srvConfig.ParseConfig(config); err == nil {
+		srvConfig.Config = &cobj
+	}
+
+	tempDir := t.TempDir()
+	schemaFile := filepath.Join(tempDir, "schema.json")
+	scanFile := filepath.Join(tempDir, "scan.json")
+	scanRecords := `[
+		{"key": "energy", "type": "float64"},
+		{"key": "detector", "type": "string", "optional": true, "value": ["eiger", "pilatus"]}
+	]`
+	schemaRecords := `[
+		{"key": "did", "type": "string"},
+		{"key": "cycle", "type": "string", "value": ["2024-3", "2025-1"]},
+		{"key": "nscans", "type": "int64"},
+		{"key": "scans", "type": "list_struct", "schema": "scan.json", "optional": true}
+	]`
+	for fname, data := range map[string]string{scanFile: scanRecords, schemaFile: schemaRecords} {
+		if err := os.WriteFile(fname, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := &Schema{FileName: schemaFile}
+	rec := map[string]any{
+		"cycle":   "2023-1",
+		"nscans":  "ten",
+		"unknown": 1,
+		"scans": []any{
+			map[string]any{"energy": 10.5, "detector": "<pilatus>"},
+			map[string]any{"energy": "high", "comment": "test"},
+		},
+	}
+	report := s.ValidateReport(rec)
+	if report.Valid() {
+		t.Fatal("record should not be valid")
+	}
+	type issue struct{ path, key, rule, severity string }
+	expect := []issue{
+		{"$.cycle", "cycle", RuleValue, SeverityError},
+		{"$.nscans", "nscans", RuleType, SeverityError},
+		{"$.scans[0].detector", "scans.detector", RuleValue, SeverityError},
+		{"$.scans[1].comment", "scans.comment", RuleUnknown, SeverityWarning},
+		{"$.scans[1].energy", "scans.energy", RuleType, SeverityError},
+		{"$.unknown", "unknown", RuleUnknown, SeverityError},
+		{"$.did", "did", RuleRequired, SeverityError},
+	}
+	if len(report.Issues) != len(expect) {
+		t.Fatalf("expected %d issues, got %d: %+v", len(expect), len(report.Issues), report.Issues)
+	}
+	for i, e := range expect {
+		got := report.Issues[i]
+		if got.Path != e.path || got.Key != e.key || got.Rule != e.rule || got.Severity != e.severity {
+			t.Errorf("issue %d: got %+v, expect %+v", i, got, e)
+		}
+	}
+	if len(report.Errors()) != 6 || len(report.Warnings()) != 1 {
+		t.Errorf("wrong number of errors %d and warnings %d", len(report.Errors()), len(report.Warnings()))
+	}
+	if msg := s.ValidateAll(rec); !strings.Contains(msg, "(6 error(s))") || !strings.Contains(msg, "$.scans[1].energy") {
+		t.Errorf("wrong ValidateAll report %s", msg)
+	}
+	if msg := s.ValidateTmplRecord(map[string]any{"cycle": "2025-1"}); msg != "" {
+		t.Errorf("template record should be valid, got %s", msg)
+	}
+
+	// errors of Validate wrap validation report
+	err := s.Validate(rec)
+	var verr *ValidationReport
+	if !errors.As(err, &verr) || len(verr.Issues) != len(expect) {
+		t.Errorf("Validate error does not wrap validation report: %v", err)
+	}
+
+	// JSON rendering
+	data, err := report.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]any
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out["valid"] != false || out["nerrors"] != 6.0 || out["nwarnings"] != 1.0 || len(out["issues"].([]any)) != 7 {
+		t.Errorf("wrong JSON report %s", string(data))
+	}
+
+	// HTML rendering escapes values
+	html, err := report.HTML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(html), "&lt;pilatus&gt;") || strings.Contains(string(html), "<pilatus>") {
+		t.Errorf("HTML report does not escape values %s", html)
+	}
+	if strings.Count(string(html), `<tr class="validation-`) != 7 {
+		t.Errorf("wrong number of HTML rows %s", html)
+	}
+
+	valid := s.ValidateReport(map[string]any{"did": "/a=1", "cycle": "2025-1", "nscans": int64(1)})
+	if !valid.Valid() || valid.String() != "" {
+		t.Errorf("record should be valid, got %+v", valid.Issues)
+	}
+}
@@ -135,6 +135,19 @@ type SchemaRecord struct {
 	Units       string `json:"units"`
 	Description string `json:"description"`
 	File        string `json:"file,omitempty"` // Used for inclusion
+
+	// constraints of the value, constraints of list types apply to list elements
+	Min          *float64       `json:"min,omitempty"`          // minimum of numeric value
+	Max          *float64       `json:"max,omitempty"`          // maximum of numeric value
+	ExclusiveMin bool           `json:"exclusiveMin,omitempty"` // value must be greater than Min
+	ExclusiveMax bool           `json:"exclusiveMax,omitempty"` // value must be less than Max
+	Pattern      string         `json:"pattern,omitempty"`      // regular expression of string value
+	MinLength    *int           `json:"minLength,omitempty"`    // minimum length of string value
+	MaxLength    *int           `json:"maxLength,omitempty"`    // maximum length of string value
+	MinItems     *int           `json:"minItems,omitempty"`     // minimum number of list elements
+	MaxItems     *int           `json:"maxItems,omitempty"`     // maximum number of list elements
+	RequiredIf   map[string]any `json:"requiredIf,omitempty"`   // key is required if other keys have given values
+	Excludes     []string       `json:"excludes,omitempty"`     // keys which can not be used along with the key
 }
 
 // SchemaCacheManager provides cache for schema maps
@@ -388,113 +401,16 @@ func (s *Schema) Load() error {
 	return nil
 }
 
-// Validate validates given record against schema
+// Validate validates given record against schema, it returns error with all
+// problems found, the *ValidationReport can be extracted via errors.As
 func (s *Schema) Validate(rec map[string]any) error {
 	if err := s.Load(); err != nil {
 		return fmt.Errorf("[golib.beamlines.Schema.Validate] s.Load error: %w", err)
 	}
-	keys, err := s.Keys()
-	if err != nil {
-		return fmt.Errorf("[golib.beamlines.Schema.Validate] s.Keys error: %w", err)
-	}
-	// hidden mandatory keys we add to each form
-	var mkeys []string
-	for k, v := range rec {
-		// skip user key if it does not belong to schema
-		if utils.InList(k, srvConfig.Config.CHESSMetaData.SkipKeys) && !utils.InList(k, keys) {
-			continue
-		}
-		// check if our record key belong to the schema keys
-		if !utils.InList(k, keys) {
-			// check if our key has struct value with proper attributes
-			if !checkSubKeys(k, v, keys) {
-				log.Println("all record keys", keys)
-				msg := fmt.Sprintf("record key '%s' with value %+v of type %T is not known", k, v, v)
-				//log.Printf("ERROR: %s, schema file %s, schema map %+v", msg, s.FileName, s.Map)
-				log.Printf("ERROR: %s, schema file %s", msg, s.FileName)
-				return errors.New(msg)
-			}
-		}
-		check1 := false // check schema record key-value matching
-		if m, ok := s.Map[k]; ok {
-			// check key name
-			if m.Key != k {
-				msg := fmt.Sprintf("invalid key=%s", k)
-				log.Printf("ERROR: %s", msg)
-				return errors.New(msg)
-			}
-			// check data type
-			if !validateSchemaType(m.Type, v, s.Verbose) {
-				// check if provided data type can be converted to m.Type
-				msg := fmt.Sprintf("invalid data type for key=%s, value=%v, type=%T, expect=%s", k, v, v, m.Type)
-				log.Printf("ERROR: %s", msg)
-				return errors.New(msg)
-			}
-			// check data value
-			if !validateRecordValue(m, v, s.Verbose) {
-				// check if provided data type can be converted to m.Type
-				msg := fmt.Sprintf("invalid data value for key=%s, type=%s, multiple=%v, value=%v valuetype=%T", k, m.Type, m.Multiple, v, v)
-				log.Printf("ERROR: %s", msg)
-				return errors.New(msg)
-			}
-			// collect mandatory keys
-			if !m.Optional {
-				mkeys = append(mkeys, k)
-			}
-			check1 = true
-		}
-		// check sub-struct for given record key
-		check2 := false // check schema sub-record key-value matching
-		for sk := range s.Map {
-			if strings.Contains(sk, ".") && strings.HasPrefix(sk, k) {
-				// we got record with another schema: it should be eitehr map or list of map records
-				if m, ok := s.Map[sk]; ok {
-					if e := validateStructs(m.File, m, v, s.Verbose); e != nil {
-						log.Printf("### ERROR: subkey validateStructs %v", v)
-						return fmt.Errorf("[golib.beamlines.Schema.Validate] validateStructs error: %w", e)
-					}
-					// collect mandatory keys
-					if !m.Optional {
-						mkeys = append(mkeys, sk)
-					}
-					check2 = true
-				} else {
-					msg := fmt.Sprintf("subkey %s does not exist in schema %+v", sk, s.Map)
-					return errors.New(msg)
-				}
-			}
-		}
-		if s.Verbose > 0 {
-			log.Printf("### record k=%s v=%+v passed check1=%v check2=%v", k, v, check1, check2)
-		}
-		// final check to see if we mathed either record key-value or sub-record key-value
-		if !check1 && !check2 {
-			msg := fmt.Sprintf("record key=%s value=%+v does not match record schema %+v", k, v, s.Map)
-			return errors.New(msg)
-		}
-	}
-	// get unique set of keys
-	mkeys = utils.List2Set(mkeys)
-
-	// check that we collected all mandatory keys
-	smkeys, err := s.MandatoryKeys()
-	if err != nil {
-		return fmt.Errorf("[golib.beamlines.Schema.Validate] s.MandatoryKeys error: %w", err)
-	}
-
-	if len(mkeys) != len(smkeys) {
-		sort.Sort(utils.StringList(mkeys))
-		var missing []string
-		for _, k := range smkeys {
-			if !utils.InList(k, mkeys) {
-				missing = append(missing, k)
-			}
-		}
-		if len(missing) > 0 {
-			msg := fmt.Sprintf("Schema %s, records keys != mandatory keys, missing keys %v", s.FileName, missing)
-			log.Printf("ERROR: %s", msg)
-			return errors.New(msg)
-		}
+	report := s.ValidateReport(rec)
+	if !report.Valid() {
+		log.Printf("ERROR: %s", report)
+		return fmt.Errorf("[golib.beamlines.Schema.Validate] validation error: %w", report)
 	}
 	return nil
 }
@@ -652,99 +568,6 @@ func (s *Schema) SectionKeys() (map[string][]string, error) {
 	return smap, nil
 }
 
-// helper function to validate any structs within our record schema
-func validateStructs(path string, rec SchemaRecord, v any, verbose int) error {
-	var structType, listStructType bool
-	valid := false
-	switch vt := v.(type) {
-	case []map[string]any:
-		listStructType = true
-		for _, r := range vt {
-			valid = validateStruct(path, rec, r, verbose)
-			if !valid {
-				break
-			}
-		}
-	case map[string]any:
-		structType = true
-		valid = validateStruct(path, rec, v.(map[string]any), verbose)
-	case []any:
-		for _, item := range vt {
-			switch vi := item.(type) {
-			case map[string]any:
-				structType = true
-				valid = validateStruct(path, rec, vi, verbose)
-			}
-		}
-	default:
-		msg := fmt.Sprintf("unsupported sub-schema record type=%T", v)
-		log.Printf("ERROR: %s", msg)
-		return errors.New(msg)
-	}
-	if !valid {
-		msg := fmt.Sprintf("invalid sub-struct record=%v, subschema=%s, expect=%s", v, rec.Schema, rec.Type)
-		log.Printf("ERROR: %s", msg)
-		return errors.New(msg)
-	}
-	if rec.Type == "struct" && !structType {
-		msg := fmt.Sprintf("mismatch of record type and record value, expected type struct (generic map) but received %T", v)
-		log.Printf("ERROR: %s", msg)
-		return errors.New(msg)
-	}
-	if rec.Type == "list_struct" && !listStructType {
-		msg := fmt.Sprintf("mismatch of record type and record value, expected type list_struct (list of maps) but received %T", v)
-		log.Printf("ERROR: %s", msg)
-		return errors.New(msg)
-	}
-	return nil
-}
-
-// helper function to validate sub-structure within schema record
-func validateStruct(path string, rec SchemaRecord, v map[string]any, verbose int) bool {
-	if verbose > 0 {
-		log.Printf("validate subschema record %+v value=%+v", rec, v)
-	}
-	// load schema from given path and rec.Schema
-	//dir := filepath.Dir(path)
-	//s := &Schema{FileName: filepath.Join(dir, rec.Schema)}
-	s := &Schema{FileName: path}
-	err := s.Load()
-	if err != nil {
-		log.Printf("ERROR: unable to load sub-schema from record %+v filename=%s err=%v", rec, path, err)
-		return false
-	}
-	// loop over new schema records and validate our map value against them
-	passValidation := false
-	for key, record := range s.Map {
-		if verbose > 0 {
-			log.Printf("### subschema key=%s schema=%+v value=%+v", key, record, v)
-		}
-		if val, ok := v[key]; ok {
-			// check data type
-			if verbose > 0 {
-				log.Printf("+++ validateSchemaType type=%s, value=%+v", record.Type, val)
-			}
-			if !validateSchemaType(record.Type, val, verbose) {
-				log.Printf("struct %+v has invalid schema type='%s' expect %T", record, record.Type, val)
-				return false
-			}
-			// check data value
-			if verbose > 0 {
-				log.Printf("+++ validateRecordValue record=%+v, value=%+v", record, val)
-			}
-			if !validateRecordValue(record, val, verbose) {
-				log.Printf("struct %+v has invalid record value %+v", record, val)
-				return false
-			}
-			passValidation = true
-		}
-	}
-	if verbose > 0 {
-		log.Printf("### validateStruct %+v valid=%v", v, passValidation)
-	}
-	return passValidation
-}
-
 // helper function to validate given value with respect to schema one
 // only valid for value of list type
 func validateRecordValue(rec SchemaRecord, v any, verbose int) bool {
@@ -6,7 +6,6 @@ package beamlines
 
 import (
 	"fmt"
-	"path/filepath"
 	"sort"
 	"strings"
 
@@ -18,123 +17,214 @@ import (
 // collecting ALL errors rather than returning on the first one. Returns an empty
 // string on success, or a human-readable multi-line report of every problem found.
 func (s *Schema) ValidateTmplRecord(rec map[string]any) string {
-	return s.validateAll(rec, false)
+	return s.ValidateTmplReport(rec).String()
 }
 
 // ValidateAll validates a record against the schema collecting ALL errors
 // rather than returning on the first one. Returns an empty string on success,
 // or a human-readable multi-line report of every problem found.
 func (s *Schema) ValidateAll(rec map[string]any) string {
+	return s.ValidateReport(rec).String()
+}
+
+// ValidateReport validates a record against the schema and returns report
+// with every problem found
+func (s *Schema) ValidateReport(rec map[string]any) *ValidationReport {
 	return s.validateAll(rec, true)
 }
 
-// validateAll validates a record against the schema collecting ALL errors
-// rather than returning on the first one. Returns an empty string on success,
-// or a human-readable multi-line report of every problem found.
-func (s *Schema) validateAll(rec map[string]any, checkMandatoryKeys bool) string {
-	var errs []string
-	add := func(format string, a ...any) {
-		errs = append(errs, fmt.Sprintf(format, a...))
-	}
+// ValidateTmplReport validates a partial (template) record against the schema
+// and returns report with every problem found, mandatory keys are not required
+func (s *Schema) ValidateTmplReport(rec map[string]any) *ValidationReport {
+	return s.validateAll(rec, false)
+}
 
+// validateAll validates a record against the schema collecting ALL issues
+// rather than returning on the first one.
+func (s *Schema) validateAll(rec map[string]any, checkMandatoryKeys bool) *ValidationReport {
+	report := &ValidationReport{Schema: s.FileName}
 	if err := s.Load(); err != nil {
-		return fmt.Sprintf("schema load error: %v", err)
-	}
-	keys, err := s.Keys()
-	if err != nil {
-		return fmt.Sprintf("schema keys error: %v", err)
+		report.Add(ValidationIssue{Path: "$", Rule: RuleSchema,
+			Message: fmt.Sprintf("schema load error: %v", err)})
+		return report
 	}
 
-	var mkeys []string // mandatory keys actually present in the record
-
 	// ── Pass 1: validate every key/value that the record contains ─────────────
-	for k, v := range rec {
-		// skip known meta-keys that are not part of the schema
-		if utils.InList(k, srvConfig.Config.CHESSMetaData.SkipKeys) && !utils.InList(k, keys) {
-			continue
-		}
+	mkeys, _ := s.validateFields(report, rec, "$", "", false)
+	s.validateDependencies(report, rec, "$", "", checkMandatoryKeys)
 
-		// check the key is known at all
-		if !utils.InList(k, keys) {
-			if !checkSubKeys(k, v, keys) {
-				add("unknown key %q (value %v, type %T) — not present in schema %s",
-					k, v, v, s.FileName)
+	// ── Pass 2: check that all mandatory keys are present ─────────────────────
+	if checkMandatoryKeys {
+		smkeys, err := s.MandatoryKeys()
+		if err != nil {
+			report.Add(ValidationIssue{Path: "$", Rule: RuleSchema,
+				Message: fmt.Sprintf("could not retrieve mandatory keys: %v", err)})
+			return report
+		}
+		for _, k := range smkeys {
+			if !utils.InList(k, mkeys) {
+				report.Add(ValidationIssue{
+					Path:     "$." + k,
+					Key:      k,
+					Expected: s.Map[k].Type,
+					Rule:     RuleRequired,
+					Message:  fmt.Sprintf("missing mandatory key %q", k),
+				})
 			}
-			// unknown key: nothing more to check for it
-			continue
 		}
+	}
+	return report
+}
 
-		check1 := false // matched a top-level schema record
-		if m, ok := s.Map[k]; ok {
-			// key name sanity (should always match, but guard anyway)
-			if m.Key != k {
-				add("key mismatch: got %q, schema has %q", k, m.Key)
-			}
-			// perform validation steps
-			if !validateSchemaType(m.Type, v, s.Verbose) {
-				// type check
-				add("invalid data type for key %q: value=%v (type %T), schema expects type=%s",
-					k, v, v, m.Type)
-			} else if !validateRecordValue(m, v, s.Verbose) {
-				// value check
-				add("invalid value for key %q: value=%v (type %T), schema type=%s, multiple=%v",
-					k, v, v, m.Type, m.Multiple)
+// helper function to validate key/values of the record or of the sub-struct
+// record (nested is true) located at given JSON path. It returns mandatory
+// keys present in the record and number of record keys matched by schema.
+func (s *Schema) validateFields(report *ValidationReport, rec map[string]any, path, prefix string, nested bool) ([]string, int) {
+	var mkeys []string // mandatory keys actually present in the record
+	matched := 0
+	var skipKeys []string
+	if !nested && srvConfig.Config != nil {
+		skipKeys = srvConfig.Config.CHESSMetaData.SkipKeys
+	}
+	keys, _ := s.Keys()
+	for _, k := range utils.MapKeys(rec) {
+		v := rec[k]
+		kpath := path + "." + k
+		m, ok := s.Map[k]
+		if !ok {
+			// skip known meta-keys and struct values with attributes of composed keys
+			if utils.InList(k, skipKeys) || checkSubKeys(k, v, keys) {
+				continue
 			}
-			if !m.Optional {
-				mkeys = append(mkeys, k)
+			issue := ValidationIssue{Path: kpath, Key: prefix + k, Value: v, Rule: RuleUnknown}
+			if nested {
+				// keys of sub-struct records which are not part of sub-schema are ignored
+				issue.Severity = SeverityWarning
+				issue.Message = fmt.Sprintf("key %q is not present in sub-schema %s", k, s.FileName)
+			} else {
+				issue.Message = fmt.Sprintf("unknown key %q (value %v, type %T) — not present in schema %s",
+					k, v, v, s.FileName)
 			}
-			check1 = true
+			report.Add(issue)
+			continue
 		}
-
-		// check composed sub-struct keys (e.g. "sample.name")
-		check2 := false
-		for sk := range s.Map {
-			if strings.Contains(sk, ".") && strings.HasPrefix(sk, k) {
-				if m, ok := s.Map[sk]; ok {
-					if e := validateStructs(m.File, m, v, s.Verbose); e != nil {
-						add("sub-schema validation failed for key %q: %v", k, e)
-					}
-					if !m.Optional {
-						mkeys = append(mkeys, sk)
-					}
-					check2 = true
-				} else {
-					add("composed key %q not found in schema map", sk)
-				}
-			}
+		matched++
+		if !m.Optional {
+			mkeys = append(mkeys, k)
 		}
-
-		if !check1 && !check2 {
-			add("key %q (value %v) matched no schema record", k, v)
+		structType := m.Type == "struct" || m.Type == "list_struct"
+		var fname string
+		if structType {
+			var composedKeys []string
+			composedKeys, fname = s.composedKeys(k)
+			mkeys = append(mkeys, composedKeys...)
+		}
+		if !validateSchemaType(m.Type, v, s.Verbose) {
+			report.Add(ValidationIssue{
+				Path:     kpath,
+				Key:      prefix + k,
+				Expected: m.Type,
+				Value:    v,
+				Rule:     RuleType,
+				Message: fmt.Sprintf("invalid data type for key %q: value=%v (type %T), schema expects type=%s",
+					k, v, v, m.Type),
+			})
+			continue
+		}
+		validateConstraints(report, m, v, kpath, prefix+k)
+		if structType {
+			s.validateStructs(report, m, fname, v, kpath, prefix)
+			continue
+		}
+		if !validateRecordValue(m, v, s.Verbose) {
+			report.Add(ValidationIssue{
+				Path:     kpath,
+				Key:      prefix + k,
+				Expected: m.Type,
+				Value:    v,
+				Rule:     RuleValue,
+				Message: fmt.Sprintf("invalid value for key %q: value=%v (type %T), schema type=%s, multiple=%v, allowed values %v",
+					k, v, v, m.Type, m.Multiple, m.Value),
+			})
 		}
 	}
+	return mkeys, matched
+}
 
-	// ── Pass 2: check that all mandatory keys are present ─────────────────────
-	if checkMandatoryKeys {
-		mkeys = utils.List2Set(mkeys)
-
-		smkeys, err := s.MandatoryKeys()
-		if err != nil {
-			add("could not retrieve mandatory keys: %v", err)
-		} else if len(mkeys) != len(smkeys) {
-			sort.Sort(utils.StringList(mkeys))
-			for _, k := range smkeys {
-				if !utils.InList(k, mkeys) {
-					add("missing mandatory key %q", k)
-				}
-			}
+// helper function to return mandatory composed keys of struct schema key,
+// e.g. sample.name, and file name of its sub-schema
+func (s *Schema) composedKeys(key string) ([]string, string) {
+	var mkeys, keys []string
+	for sk := range s.Map {
+		if strings.HasPrefix(sk, key+".") {
+			keys = append(keys, sk)
 		}
 	}
+	sort.Strings(keys)
+	var fname string
+	for _, sk := range keys {
+		sm := s.Map[sk]
+		if fname == "" {
+			fname = sm.File
+		}
+		if !sm.Optional {
+			mkeys = append(mkeys, sk)
+		}
+	}
+	return mkeys, fname
+}
 
-	// ── Build report ──────────────────────────────────────────────────────────
-	if len(errs) == 0 {
-		return ""
+// helper function to validate struct or list of struct values of schema
+// record against its sub-schema stored in fname
+func (s *Schema) validateStructs(report *ValidationReport, m SchemaRecord, fname string, v any, path, prefix string) {
+	if fname == "" {
+		// struct without sub-schema accepts any attributes
+		return
+	}
+	sub := &Schema{FileName: fname, Verbose: s.Verbose}
+	if err := sub.Load(); err != nil {
+		report.Add(ValidationIssue{Path: path, Key: prefix + m.Key, Rule: RuleSchema,
+			Message: fmt.Sprintf("unable to load sub-schema %s: %v", fname, err)})
+		return
 	}
-	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("validation failed against schema %s (%d error(s)):\n",
-		filepath.Base(s.FileName), len(errs)))
-	for i, e := range errs {
-		sb.WriteString(fmt.Sprintf("  [%d] %s\n", i+1, e))
+	var items []any
+	switch vt := v.(type) {
+	case map[string]any:
+		items = []any{vt}
+	case []map[string]any:
+		for _, item := range vt {
+			items = append(items, item)
+		}
+	case []any:
+		items = vt
+	}
+	for idx, item := range items {
+		ipath := path
+		if m.Type == "list_struct" {
+			ipath = fmt.Sprintf("%s[%d]", path, idx)
+		}
+		r, ok := item.(map[string]any)
+		if !ok {
+			report.Add(ValidationIssue{
+				Path:     ipath,
+				Key:      prefix + m.Key,
+				Expected: "struct",
+				Value:    item,
+				Rule:     RuleType,
+				Message:  fmt.Sprintf("invalid sub-struct record %v of type %T, expect map", item, item),
+			})
+			continue
+		}
+		sub.validateDependencies(report, r, ipath, prefix+m.Key+".", true)
+		if _, matched := sub.validateFields(report, r, ipath, prefix+m.Key+".", true); matched == 0 {
+			report.Add(ValidationIssue{
+				Path:     ipath,
+				Key:      prefix + m.Key,
+				Expected: m.Type,
+				Value:    r,
+				Rule:     RuleStruct,
+				Message:  fmt.Sprintf("sub-struct record %v does not contain any key of sub-schema %s", r, m.Schema),
+			})
+		}
 	}
-	return strings.TrimRight(sb.String(), "\n")
 }
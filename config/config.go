@@ -129,15 +129,17 @@ type DID struct {
 
 // Embed structure
 type Embed struct {
-	DocDb string `mapstructure:"DocDb"`
-	SqlDb string `mapstructure:"SqlDb"`
+	DocDb  string `mapstructure:"DocDb"`
+	SqlDb  string `mapstructure:"SqlDb"`
+	Engine string `mapstructure:"Engine"` // embedded DocDB engine: badger (default), clover or tiedot
 }
 
 // QL structure
 type QL struct {
-	ServiceMapFile string `mapstructure:"ServiceMapFile"` // service map file name
-	Separator      string `mapstructure:"separator"`      // ql separator, default ":"
-	Verbose        int    `mapstructure:"verbose"`        // verbosity level
+	ServiceMapFile string   `mapstructure:"ServiceMapFile"` // service map file name
+	Separator      string   `mapstructure:"separator"`      // ql separator, default ":"
+	Verbose        int      `mapstructure:"verbose"`        // verbosity level
+	ExactMatchKeys []string `mapstructure:"ExactMatchKeys"` // string keys matched exactly instead of case-insensitive regex
 }
 
 // OAuthRecord defines OAuth provider's credentials
@@ -267,6 +269,13 @@ type MongoDB struct {
 	DBName string `mapstructure:"DBName"` // database name
 	DBColl string `mapstructure:"DBColl"` // database collection
 	DBUri  string `mapstructure:"DBUri"`  // database URI
+
+	MaxPoolSize            uint64 `mapstructure:"MaxPoolSize"`            // max number of connections in the pool
+	MinPoolSize            uint64 `mapstructure:"MinPoolSize"`            // min number of connections in the pool
+	ServerSelectionTimeout int    `mapstructure:"ServerSelectionTimeout"` // server selection timeout in seconds
+	Timeout                int    `mapstructure:"Timeout"`                // operation timeout in seconds
+	ReadPreference         string `mapstructure:"ReadPreference"`         // read preference, e.g. primary, secondaryPreferred, nearest
+	WriteConcern           string `mapstructure:"WriteConcern"`           // write concern, e.g. majority or number of nodes
 }
 
 // MLHub represents ML service configuration
@@ -624,6 +633,9 @@ func ParseConfig(cfile string) (SrvConfig, error) {
 			"doi_provider", "doi_foxden_url", "doi_access_metadata", "doi_parents_dids",
 			"globus_link", "history"}
 	}
+	if config.Embed.Engine == "" {
+		config.Embed.Engine = "badger"
+	}
 	if config.AccessRules.AdminGroup == "" {
 		config.AccessRules.AdminGroup = "foxdenadmins"
 	}
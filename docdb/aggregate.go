@@ -0,0 +1,53 @@
+package docdb
+
+import (
+	"context"
+	"fmt"
+
+	query "github.com/CHESSComputing/golib/embed/query"
+)
+
+// Aggregator represents DocDB backend which natively supports aggregation pipelines
+type Aggregator interface {
+	Aggregate(ctx context.Context, dbname, collname string, pipeline []map[string]any) ([]map[string]any, error)
+}
+
+// Aggregate runs aggregation pipeline on given database/collection. MongoDB
+// runs the pipeline natively while for other backends it is evaluated by
+// embedded evaluator which supports $match, $project, $group, $sort, $skip,
+// $limit, $count, $unwind, $facet, $bucket and $sortByCount stages with
+// field path expressions. The evaluator pushes leading $match stage down to
+// the backend and processes matched records in memory, and like other
+// DocDB APIs it does not provide record _id. Pipelines can be built with
+// helpers of mongo package, e.g.
+//
+//	pipeline := mongo.Pipeline{
+//		mongo.Match(map[string]any{"beamline": "3a"}),
+//		mongo.SortByCount("$cycle"),
+//	}
+//	records, err := docdb.Aggregate(ctx, db, dbname, collname, pipeline)
+func Aggregate(ctx context.Context, db DocDBV2, dbname, collname string, pipeline []map[string]any) ([]map[string]any, error) {
+	if adb, ok := db.(Aggregator); ok {
+		records, err := adb.Aggregate(ctx, dbname, collname, pipeline)
+		if err != nil {
+			return nil, fmt.Errorf("[golib.docdb.Aggregate] aggregate error: %w", err)
+		}
+		return records, nil
+	}
+	spec := map[string]any{}
+	if len(pipeline) > 0 && len(pipeline[0]) == 1 {
+		if match, ok := pipeline[0]["$match"].(map[string]any); ok {
+			spec = match
+			pipeline = pipeline[1:]
+		}
+	}
+	records, err := db.Get(ctx, dbname, collname, spec, 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("[golib.docdb.Aggregate] get error: %w", err)
+	}
+	records, err = query.Aggregate(records, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("[golib.docdb.Aggregate] error: %w", err)
+	}
+	return records, nil
+}
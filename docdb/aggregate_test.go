@@ -0,0 +1,71 @@
+package docdb
+
+import (
+	"context"
+	"testing"
+
+	mongo "github.com/CHESSComputing/golib/mongo"
+)
+
+// TestAggregate tests aggregation pipelines of all backends
+func TestAggregate(t *testing.T) {
+	for _, backend := range conformanceBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			ctx := context.Background()
+			db, err := Open(ctx, backend.uri(t))
+			if err != nil {
+				t.Fatalf("Open error: %v", err)
+			}
+			dbname, collname := "chess", "aggregate"
+			if err := db.Remove(ctx, dbname, collname, map[string]any{}); err != nil {
+				t.Fatalf("Remove error: %v", err)
+			}
+			records := []map[string]any{
+				{"did": "/a", "beamline": "3a", "cycle": "2024-1", "energy": 10},
+				{"did": "/b", "beamline": "3a", "cycle": "2024-2", "energy": 30},
+				{"did": "/c", "beamline": "3b", "cycle": "2024-2", "energy": 20},
+				{"did": "/d", "beamline": "3a", "cycle": "2024-2", "energy": 60},
+			}
+			if err := db.Insert(ctx, dbname, collname, records); err != nil {
+				t.Fatalf("Insert error: %v", err)
+			}
+
+			pipeline := mongo.Pipeline{
+				mongo.Match(map[string]any{"beamline": "3a"}),
+				mongo.Group("$cycle", map[string]mongo.Accumulator{
+					"count": mongo.Sum(1), "energy": mongo.Max("$energy")}),
+				mongo.Sort([]string{"_id"}, -1),
+			}
+			results, err := Aggregate(ctx, db, dbname, collname, pipeline)
+			if err != nil {
+				t.Fatalf("Aggregate error: %v", err)
+			}
+			checkRecords(t, "Group", results, []map[string]any{
+				{"_id": "2024-2", "count": 2, "energy": 60},
+				{"_id": "2024-1", "count": 1, "energy": 10},
+			})
+
+			pipeline = mongo.Pipeline{
+				mongo.Facet(map[string]mongo.Pipeline{
+					"cycles": {mongo.SortByCount("$cycle")},
+					"energy": {mongo.Bucket("$energy", []any{0, 25, 50}, "high", nil)},
+				}),
+			}
+			results, err = Aggregate(ctx, db, dbname, collname, pipeline)
+			if err != nil {
+				t.Fatalf("Aggregate error: %v", err)
+			}
+			checkRecords(t, "Facet", results, []map[string]any{{
+				"cycles": []any{
+					map[string]any{"_id": "2024-2", "count": 3},
+					map[string]any{"_id": "2024-1", "count": 1},
+				},
+				"energy": []any{
+					map[string]any{"_id": 0, "count": 2},
+					map[string]any{"_id": 25, "count": 1},
+					map[string]any{"_id": "high", "count": 1},
+				},
+			}})
+		})
+	}
+}
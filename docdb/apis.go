@@ -1,12 +1,16 @@
 package docdb
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"log"
+	"strings"
 
 	srvConfig "github.com/CHESSComputing/golib/config"
 	embed "github.com/CHESSComputing/golib/embed/badger"
+	clover "github.com/CHESSComputing/golib/embed/clover"
+	memory "github.com/CHESSComputing/golib/embed/memory"
+	tiedot "github.com/CHESSComputing/golib/embed/tiedot"
 	mongo "github.com/CHESSComputing/golib/mongo"
 )
 
@@ -25,29 +29,270 @@ type DocDB interface {
 	GetSorted(dbname, collname string, spec map[string]any, skeys []string, sortOrder, idx, limit int) []map[string]any
 }
 
+// DocDBV2 represents context-aware interface for document-oriented database,
+// every method accepts context to allow cancellation of slow queries and
+// returns an error to distinguish empty results from backend failures. Like
+// MongoDB UpdateOne, Update modifies only the first record matching the spec.
+type DocDBV2 interface {
+	InitDB(ctx context.Context, uri string) error
+	Insert(ctx context.Context, dbname, collname string, records []map[string]any) error
+	Upsert(ctx context.Context, dbname, collname, attr string, records []map[string]any) error
+	Get(ctx context.Context, dbname, collname string, spec map[string]any, idx, limit int) ([]map[string]any, error)
+	GetProjection(ctx context.Context, dbname, collname string, spec map[string]any, projection map[string]int, idx, limit int) ([]map[string]any, error)
+	Update(ctx context.Context, dbname, collname string, spec, newdata map[string]any) error
+	Count(ctx context.Context, dbname, collname string, spec map[string]any) (int, error)
+	Remove(ctx context.Context, dbname, collname string, spec map[string]any) error
+	Distinct(ctx context.Context, dbname, collname, field string) ([]any, error)
+	InsertRecord(ctx context.Context, dbname, collname string, rec map[string]any) error
+	GetSorted(ctx context.Context, dbname, collname string, spec map[string]any, skeys []string, sortOrder, idx, limit int) ([]map[string]any, error)
+}
+
+// compile-time checks
+var _ DocDB = (*mongo.MongoDB)(nil)
+var _ DocDB = (*embed.EmbedDB)(nil)
+var _ DocDB = (*clover.EmbedDB)(nil)
+var _ DocDB = (*tiedot.EmbedDB)(nil)
+var _ DocDB = (*memory.MemoryDB)(nil)
+var _ DocDBV2 = (*mongo.MongoDBV2)(nil)
+var _ DocDBV2 = (*embed.EmbedDBV2)(nil)
+var _ DocDBV2 = (*clover.EmbedDBV2)(nil)
+var _ DocDBV2 = (*tiedot.EmbedDBV2)(nil)
+var _ DocDBV2 = (*memory.MemoryDBV2)(nil)
+var _ Transactional = (*mongo.MongoDBV2)(nil)
+var _ Transactional = (*embed.EmbedDBV2)(nil)
+var _ Transactional = (*memory.MemoryDBV2)(nil)
+var _ Watcher = (*mongo.MongoDBV2)(nil)
+var _ Watcher = (*embed.EmbedDBV2)(nil)
+var _ Watcher = (*clover.EmbedDBV2)(nil)
+var _ Watcher = (*tiedot.EmbedDBV2)(nil)
+var _ Watcher = (*memory.MemoryDBV2)(nil)
+var _ Aggregator = (*mongo.MongoDBV2)(nil)
+var _ UpdateCounter = (*mongo.MongoDBV2)(nil)
+var _ UpdateCounter = (*embed.EmbedDBV2)(nil)
+var _ UpdateCounter = (*clover.EmbedDBV2)(nil)
+var _ UpdateCounter = (*tiedot.EmbedDBV2)(nil)
+var _ UpdateCounter = (*memory.MemoryDBV2)(nil)
+
 // InitializeDocDB initializes either mongo or embed database based on server configuration
 func InitializeDocDB(uri string) (DocDB, error) {
-	var docDB DocDB
-	var err error
-	dbType := "mongo"
-	if srvConfig.Config.Embed.DocDb != "" {
-		dbType = "embed"
-	}
-	switch dbType {
-	case "mongo":
-		log.Println("Initializing DocDB with MongoDB backend")
-		docDB = &mongo.MongoDB{}
-	case "embed":
-		log.Printf("Initializing DocDB with embed DB backend %s", srvConfig.Config.Embed.DocDb)
-		docDB = &embed.EmbedDB{}
-	default:
-		err = errors.New(fmt.Sprintf("Unsupported database type: %s", dbType))
-	}
-	if docDB != nil {
-		docDB.InitDB(uri)
+	docDB, err := InitializeDocDBV2(context.Background(), uri)
+	if docDB == nil {
+		return nil, err
+	}
+	return NewDocDB(docDB), err
+}
+
+// InitializeDocDBV2 initializes context-aware mongo or embed database based on server configuration
+func InitializeDocDBV2(ctx context.Context, uri string) (DocDBV2, error) {
+	if dburi := embedURI(); dburi != "" {
+		uri = dburi
 	}
+	docDB, err := Open(ctx, uri)
 	if err != nil {
 		return docDB, fmt.Errorf("[golib.docdb.InitializeDocDB] error: %w", err)
 	}
 	return docDB, nil
 }
+
+// helper function to build embed database URI from server configuration,
+// Embed.DocDb can be either a URI or a path used with configured engine
+func embedURI() string {
+	path := srvConfig.Config.Embed.DocDb
+	if path == "" || strings.Contains(path, "://") {
+		return path
+	}
+	engine := srvConfig.Config.Embed.Engine
+	if engine == "" {
+		engine = "badger"
+	}
+	return engine + "://" + path
+}
+
+// legacyDocDB provides DocDB interface on top of DocDBV2 one
+type legacyDocDB struct {
+	db DocDBV2
+}
+
+// NewDocDB wraps given DocDBV2 object into DocDB interface, all operations
+// use background context and errors which are not part of DocDB interface
+// are logged
+func NewDocDB(db DocDBV2) DocDB {
+	return &legacyDocDB{db: db}
+}
+
+// InitDB initializes underlying database
+func (d *legacyDocDB) InitDB(uri string) {
+	if err := d.db.InitDB(context.Background(), uri); err != nil {
+		log.Println("ERROR:", err)
+	}
+}
+
+// Insert inserts records into provided database/collection
+func (d *legacyDocDB) Insert(dbname, collname string, records []map[string]any) {
+	if err := d.db.Insert(context.Background(), dbname, collname, records); err != nil {
+		log.Println("ERROR:", err)
+	}
+}
+
+// Upsert inserts records into provided database/collection and attribute
+func (d *legacyDocDB) Upsert(dbname, collname, attr string, records []map[string]any) error {
+	return d.db.Upsert(context.Background(), dbname, collname, attr, records)
+}
+
+// Get fetches data from underlying database/collection
+func (d *legacyDocDB) Get(dbname, collname string, spec map[string]any, idx, limit int) []map[string]any {
+	records, err := d.db.Get(context.Background(), dbname, collname, spec, idx, limit)
+	if err != nil {
+		log.Println("ERROR:", err)
+	}
+	return records
+}
+
+// GetProjection fetches data from underlying database/collection
+func (d *legacyDocDB) GetProjection(dbname, collname string, spec map[string]any, projection map[string]int, idx, limit int) []map[string]any {
+	records, err := d.db.GetProjection(context.Background(), dbname, collname, spec, projection, idx, limit)
+	if err != nil {
+		log.Println("ERROR:", err)
+	}
+	return records
+}
+
+// Update updates data into given database/collection
+func (d *legacyDocDB) Update(dbname, collname string, spec, newdata map[string]any) error {
+	return d.db.Update(context.Background(), dbname, collname, spec, newdata)
+}
+
+// Count returns total number of records within database/collection and given spec
+func (d *legacyDocDB) Count(dbname, collname string, spec map[string]any) int {
+	nrec, err := d.db.Count(context.Background(), dbname, collname, spec)
+	if err != nil {
+		log.Println("ERROR:", err)
+	}
+	return nrec
+}
+
+// Remove deletes records in given database/collection using given spec
+func (d *legacyDocDB) Remove(dbname, collname string, spec map[string]any) error {
+	return d.db.Remove(context.Background(), dbname, collname, spec)
+}
+
+// Distinct returns distinct collection of records
+func (d *legacyDocDB) Distinct(dbname, collname, field string) ([]any, error) {
+	return d.db.Distinct(context.Background(), dbname, collname, field)
+}
+
+// InsertRecord inserts single record into given database/collection
+func (d *legacyDocDB) InsertRecord(dbname, collname string, rec map[string]any) error {
+	return d.db.InsertRecord(context.Background(), dbname, collname, rec)
+}
+
+// GetSorted returns sorted records from given database/collection using provided spec, sorted keys, order and limits
+func (d *legacyDocDB) GetSorted(dbname, collname string, spec map[string]any, skeys []string, sortOrder, idx, limit int) []map[string]any {
+	records, err := d.db.GetSorted(context.Background(), dbname, collname, spec, skeys, sortOrder, idx, limit)
+	if err != nil {
+		log.Println("ERROR:", err)
+	}
+	return records
+}
+
+// contextDocDB provides DocDBV2 interface on top of DocDB one
+type contextDocDB struct {
+	db DocDB
+}
+
+// NewDocDBV2 wraps given DocDB object into DocDBV2 interface, contexts are
+// only checked before each operation since DocDB interface does not support
+// cancellation, and errors which are not part of DocDB interface are lost
+func NewDocDBV2(db DocDB) DocDBV2 {
+	return &contextDocDB{db: db}
+}
+
+// InitDB initializes underlying database
+func (d *contextDocDB) InitDB(ctx context.Context, uri string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	d.db.InitDB(uri)
+	return nil
+}
+
+// Insert inserts records into provided database/collection
+func (d *contextDocDB) Insert(ctx context.Context, dbname, collname string, records []map[string]any) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	d.db.Insert(dbname, collname, records)
+	return nil
+}
+
+// Upsert inserts records into provided database/collection and attribute
+func (d *contextDocDB) Upsert(ctx context.Context, dbname, collname, attr string, records []map[string]any) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return d.db.Upsert(dbname, collname, attr, records)
+}
+
+// Get fetches data from underlying database/collection
+func (d *contextDocDB) Get(ctx context.Context, dbname, collname string, spec map[string]any, idx, limit int) ([]map[string]any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return d.db.Get(dbname, collname, spec, idx, limit), nil
+}
+
+// GetProjection fetches data from underlying database/collection
+func (d *contextDocDB) GetProjection(ctx context.Context, dbname, collname string, spec map[string]any, projection map[string]int, idx, limit int) ([]map[string]any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return d.db.GetProjection(dbname, collname, spec, projection, idx, limit), nil
+}
+
+// Update updates data into given database/collection
+func (d *contextDocDB) Update(ctx context.Context, dbname, collname string, spec, newdata map[string]any) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return d.db.Update(dbname, collname, spec, newdata)
+}
+
+// Count returns total number of records within database/collection and given spec
+func (d *contextDocDB) Count(ctx context.Context, dbname, collname string, spec map[string]any) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return d.db.Count(dbname, collname, spec), nil
+}
+
+// Remove deletes records in given database/collection using given spec
+func (d *contextDocDB) Remove(ctx context.Context, dbname, collname string, spec map[string]any) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return d.db.Remove(dbname, collname, spec)
+}
+
+// Distinct returns distinct collection of records
+func (d *contextDocDB) Distinct(ctx context.Context, dbname, collname, field string) ([]any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return d.db.Distinct(dbname, collname, field)
+}
+
+// InsertRecord inserts single record into given database/collection
+func (d *contextDocDB) InsertRecord(ctx context.Context, dbname, collname string, rec map[string]any) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return d.db.InsertRecord(dbname, collname, rec)
+}
+
+// GetSorted returns sorted records from given database/collection using provided spec, sorted keys, order and limits
+func (d *contextDocDB) GetSorted(ctx context.Context, dbname, collname string, spec map[string]any, skeys []string, sortOrder, idx, limit int) ([]map[string]any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return d.db.GetSorted(dbname, collname, spec, skeys, sortOrder, idx, limit), nil
+}
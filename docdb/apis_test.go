@@ -0,0 +1,62 @@
+package docdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	embed "github.com/CHESSComputing/golib/embed/badger"
+	clover "github.com/CHESSComputing/golib/embed/clover"
+	tiedot "github.com/CHESSComputing/golib/embed/tiedot"
+	mongo "github.com/CHESSComputing/golib/mongo"
+)
+
+// TestInitializeDocDBEngine tests selection of embedded DocDB engine
+func TestInitializeDocDBEngine(t *testing.T) {
+	tests := []struct {
+		engine string
+		check  func(db DocDBV2) bool
+	}{
+		{"", func(db DocDBV2) bool { _, ok := db.(*embed.EmbedDBV2); return ok }},
+		{"badger", func(db DocDBV2) bool { _, ok := db.(*embed.EmbedDBV2); return ok }},
+		{"tiedot", func(db DocDBV2) bool { _, ok := db.(*tiedot.EmbedDBV2); return ok }},
+		{"clover", func(db DocDBV2) bool { _, ok := db.(*clover.EmbedDBV2); return ok }},
+	}
+	for _, test := range tests {
+		srvConfig.Config = &srvConfig.SrvConfig{}
+		srvConfig.Config.Embed.DocDb = dbDir(t)
+		srvConfig.Config.Embed.Engine = test.engine
+		db, err := InitializeDocDBV2(context.Background(), "")
+		if err != nil {
+			t.Fatalf("engine %s, unexpected error %v", test.engine, err)
+		}
+		if !test.check(db) {
+			t.Fatalf("engine %s, wrong DocDB type %T", test.engine, db)
+		}
+	}
+	srvConfig.Config.Embed.Engine = "foo"
+	if _, err := InitializeDocDBV2(context.Background(), ""); err == nil {
+		t.Fatal("expected error for unsupported engine")
+	}
+}
+
+// TestInitializeDocDBMongoOptions tests that client options of MongoDB
+// configuration are applied to connection initialized by InitializeDocDB
+func TestInitializeDocDBMongoOptions(t *testing.T) {
+	uri := "mongodb://localhost:1"
+	srvConfig.Config = &srvConfig.SrvConfig{}
+	srvConfig.Config.MetaData.MongoDB = srvConfig.MongoDB{
+		DBUri:                  uri,
+		MaxPoolSize:            7,
+		ServerSelectionTimeout: 1,
+		ReadPreference:         "nearest",
+	}
+	if _, err := InitializeDocDB(uri); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	opts := mongo.Mongo.Options
+	if opts.MaxPoolSize != 7 || opts.ServerSelectionTimeout != time.Second || opts.ReadPreference != "nearest" {
+		t.Fatalf("configuration options are not applied, got %+v", opts)
+	}
+}
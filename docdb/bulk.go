@@ -0,0 +1,158 @@
+package docdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrTransactionNotSupported is returned when DocDB backend does not support transactions
+var ErrTransactionNotSupported = errors.New("transactions are not supported")
+
+// Transactional represents DocDB backend which supports transactions
+type Transactional interface {
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// WithTransaction runs given function within transaction of given database:
+// MongoDB session, BadgerDB transaction, etc. All DocDB calls which use
+// context passed to the function are part of the transaction, which is
+// committed if function returns no error and rolled back otherwise, e.g.
+//
+//	err := docdb.WithTransaction(ctx, db, func(ctx context.Context) error {
+//		if err := db.Insert(ctx, dbname, collname, records); err != nil {
+//			return err
+//		}
+//		return db.Update(ctx, dbname, collname, spec, newdata)
+//	})
+func WithTransaction(ctx context.Context, db DocDBV2, fn func(ctx context.Context) error) error {
+	tdb, ok := db.(Transactional)
+	if !ok {
+		return fmt.Errorf("[golib.docdb.WithTransaction] %w by %T", ErrTransactionNotSupported, db)
+	}
+	return tdb.WithTransaction(ctx, fn)
+}
+
+// UpdateCounter represents DocDB backend which reports number of records
+// matched and modified by update. Like MongoDB UpdateOne all backends update
+// only the first record matching given spec.
+type UpdateCounter interface {
+	UpdateCount(ctx context.Context, dbname, collname string, spec, newdata map[string]any) (int, int, error)
+}
+
+// bulk write operation types
+const (
+	InsertOp = "insert"
+	UpdateOp = "update"
+	UpsertOp = "upsert"
+	DeleteOp = "delete"
+)
+
+// WriteOp represents single operation of bulk write
+type WriteOp struct {
+	Type    string           // operation type: insert, update, upsert or delete
+	Records []map[string]any // records to insert or upsert
+	Attr    string           // attribute used to look-up upserted records
+	Spec    map[string]any   // spec of records to update or delete
+	Update  map[string]any   // new data or update operators of update operation
+}
+
+// WriteResult represents result of single bulk write operation
+type WriteResult struct {
+	Index    int    // index of operation in bulk write
+	Type     string // operation type
+	Records  int    // number of inserted or upserted records
+	Matched  int    // number of records matched by spec of update or delete operation
+	Modified int    // number of records modified by update operation
+	Error    error  // operation error
+}
+
+// BulkWrite performs given write operations on database/collection. In
+// ordered mode operations are executed sequentially and execution stops at
+// first failed operation, while in unordered mode all operations are
+// executed regardless of failures. It returns results of executed operations
+// and joined errors of failed ones. Bulk write is not atomic, use it within
+// WithTransaction to apply all operations or none of them.
+func BulkWrite(ctx context.Context, db DocDBV2, dbname, collname string, ops []WriteOp, ordered bool) ([]WriteResult, error) {
+	var results []WriteResult
+	var errs []error
+	for idx, op := range ops {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		res := writeOp(ctx, db, dbname, collname, op)
+		res.Index = idx
+		results = append(results, res)
+		if res.Error != nil {
+			errs = append(errs, fmt.Errorf("operation %d (%s): %w", idx, op.Type, res.Error))
+			if ordered {
+				break
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return results, fmt.Errorf("[golib.docdb.BulkWrite] error: %w", err)
+	}
+	return results, nil
+}
+
+// helper function to perform single write operation
+func writeOp(ctx context.Context, db DocDBV2, dbname, collname string, op WriteOp) WriteResult {
+	res := WriteResult{Type: op.Type}
+	switch op.Type {
+	case InsertOp:
+		res.Error = db.Insert(ctx, dbname, collname, op.Records)
+		if res.Error == nil {
+			res.Records = len(op.Records)
+		}
+	case UpsertOp:
+		if op.Attr == "" {
+			res.Error = errors.New("upsert operation requires attribute")
+			break
+		}
+		res.Error = db.Upsert(ctx, dbname, collname, op.Attr, op.Records)
+		if res.Error == nil {
+			// records without attribute value are skipped by upsert
+			for _, rec := range op.Records {
+				if val, ok := rec[op.Attr]; ok && val != nil && val != "" {
+					res.Records++
+				}
+			}
+		}
+	case UpdateOp, DeleteOp:
+		if op.Spec == nil {
+			res.Error = fmt.Errorf("%s operation requires spec", op.Type)
+			break
+		}
+		if op.Type == UpdateOp {
+			res.Matched, res.Modified, res.Error = updateCount(ctx, db, dbname, collname, op.Spec, op.Update)
+			break
+		}
+		res.Matched, res.Error = db.Count(ctx, dbname, collname, op.Spec)
+		if res.Error != nil {
+			break
+		}
+		res.Error = db.Remove(ctx, dbname, collname, op.Spec)
+	default:
+		res.Error = fmt.Errorf("unsupported operation type %q", op.Type)
+	}
+	return res
+}
+
+// helper function to update first record matching given spec and return
+// number of matched and modified records, for backends which do not report
+// them both counts are derived from number of matched records
+func updateCount(ctx context.Context, db DocDBV2, dbname, collname string, spec, newdata map[string]any) (int, int, error) {
+	if udb, ok := db.(UpdateCounter); ok {
+		return udb.UpdateCount(ctx, dbname, collname, spec, newdata)
+	}
+	nrec, err := db.Count(ctx, dbname, collname, spec)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := db.Update(ctx, dbname, collname, spec, newdata); err != nil {
+		return 0, 0, err
+	}
+	return min(nrec, 1), min(nrec, 1), nil
+}
@@ -0,0 +1,155 @@
+package docdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// TestBulkWrite tests ordered and unordered bulk writes
+func TestBulkWrite(t *testing.T) {
+	ctx := context.Background()
+	ops := []WriteOp{
+		{Type: InsertOp, Records: []map[string]any{{"_id": "1", "did": "/a"}, {"_id": "2", "did": "/b"}}},
+		{Type: InsertOp, Records: []map[string]any{{"_id": "1", "did": "/c"}}},
+		{Type: UpdateOp, Spec: map[string]any{"did": "/a"}, Update: map[string]any{"$set": map[string]any{"energy": 10}}},
+		{Type: UpsertOp, Attr: "did", Records: []map[string]any{{"did": "/b", "energy": 20}, {"energy": 30}}},
+		{Type: DeleteOp, Spec: map[string]any{"did": "/b"}},
+		{Type: "replace"},
+	}
+	for _, ordered := range []bool{true, false} {
+		db, err := Open(ctx, "memory://")
+		if err != nil {
+			t.Fatalf("Open error: %v", err)
+		}
+		results, err := BulkWrite(ctx, db, "test", "bulk", ops, ordered)
+		if err == nil {
+			t.Fatalf("ordered %v, expected error", ordered)
+		}
+		if ordered {
+			if len(results) != 2 || results[1].Error == nil || results[0].Records != 2 {
+				t.Fatalf("ordered %v, wrong results %+v", ordered, results)
+			}
+			continue
+		}
+		if len(results) != len(ops) {
+			t.Fatalf("ordered %v, expected %d results, got %+v", ordered, len(ops), results)
+		}
+		for i, nrec := range []int{2, 0, 0, 1, 0, 0} {
+			if results[i].Index != i || results[i].Records != nrec {
+				t.Fatalf("ordered %v, wrong result %+v", ordered, results[i])
+			}
+		}
+		for i, matched := range []int{0, 0, 1, 0, 1, 0} {
+			if results[i].Matched != matched {
+				t.Fatalf("ordered %v, wrong result %+v", ordered, results[i])
+			}
+		}
+		if results[1].Error == nil || results[5].Error == nil {
+			t.Fatalf("ordered %v, expected errors in results %+v", ordered, results)
+		}
+		records, err := db.Get(ctx, "test", "bulk", map[string]any{}, 0, 0)
+		if err != nil || len(records) != 1 || records[0]["energy"] != 10 {
+			t.Fatalf("ordered %v, wrong records %v, error %v", ordered, records, err)
+		}
+	}
+}
+
+// TestWithTransaction tests commit and rollback of transactions for all backends
+func TestWithTransaction(t *testing.T) {
+	ctx := context.Background()
+	for _, backend := range conformanceBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			db, err := Open(ctx, backend.uri(t))
+			if err != nil {
+				t.Fatalf("Open error: %v", err)
+			}
+			dbname, collname := "chess", "transaction"
+			if err := db.Remove(ctx, dbname, collname, map[string]any{}); err != nil {
+				t.Fatalf("Remove error: %v", err)
+			}
+			if _, ok := db.(Transactional); !ok {
+				err := WithTransaction(ctx, db, func(ctx context.Context) error { return nil })
+				if !errors.Is(err, ErrTransactionNotSupported) {
+					t.Fatalf("expected ErrTransactionNotSupported, got %v", err)
+				}
+				return
+			}
+			records := []map[string]any{{"did": "/a", "energy": 10}}
+			errFail := errors.New("rollback")
+			err = WithTransaction(ctx, db, func(ctx context.Context) error {
+				if err := db.Insert(ctx, dbname, collname, records); err != nil {
+					return err
+				}
+				if nrec, err := db.Count(ctx, dbname, collname, map[string]any{}); err != nil || nrec != 1 {
+					t.Fatalf("expected 1 record within transaction, got %d, error %v", nrec, err)
+				}
+				return errFail
+			})
+			if !errors.Is(err, errFail) {
+				t.Fatalf("expected rollback error, got %v", err)
+			}
+			if nrec, err := db.Count(ctx, dbname, collname, map[string]any{}); err != nil || nrec != 0 {
+				t.Fatalf("expected no records after rollback, got %d, error %v", nrec, err)
+			}
+			err = WithTransaction(ctx, db, func(ctx context.Context) error {
+				ops := []WriteOp{
+					{Type: InsertOp, Records: records},
+					{Type: UpdateOp, Spec: map[string]any{"did": "/a"}, Update: map[string]any{"$inc": map[string]any{"energy": 5}}},
+				}
+				_, err := BulkWrite(ctx, db, dbname, collname, ops, true)
+				return err
+			})
+			if err != nil {
+				t.Fatalf("WithTransaction error: %v", err)
+			}
+			results, err := db.Get(ctx, dbname, collname, map[string]any{}, 0, 0)
+			if err != nil {
+				t.Fatalf("Get error: %v", err)
+			}
+			checkRecords(t, "WithTransaction", results, []map[string]any{{"did": "/a", "energy": 15}})
+		})
+	}
+}
+
+// TestBulkUpdateCounts tests that all backends update only the first matched
+// record and report number of matched and modified records
+func TestBulkUpdateCounts(t *testing.T) {
+	ctx := context.Background()
+	for _, backend := range conformanceBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			db, err := Open(ctx, backend.uri(t))
+			if err != nil {
+				t.Fatalf("Open error: %v", err)
+			}
+			dbname, collname := "chess", "bulkupdate"
+			if err := db.Remove(ctx, dbname, collname, map[string]any{}); err != nil {
+				t.Fatalf("Remove error: %v", err)
+			}
+			records := []map[string]any{{"did": "/a", "group": 1}, {"did": "/b", "group": 1}, {"did": "/c", "group": 2}}
+			ops := []WriteOp{
+				{Type: InsertOp, Records: records},
+				{Type: UpdateOp, Spec: map[string]any{"group": 1}, Update: map[string]any{"$set": map[string]any{"energy": 10}}},
+				{Type: UpdateOp, Spec: map[string]any{"group": 2}, Update: map[string]any{"$set": map[string]any{"group": 2}}},
+				{Type: UpdateOp, Spec: map[string]any{"group": 3}, Update: map[string]any{"$set": map[string]any{"energy": 10}}},
+			}
+			results, err := BulkWrite(ctx, db, dbname, collname, ops, true)
+			if err != nil {
+				t.Fatalf("BulkWrite error: %v", err)
+			}
+			for i, counts := range [][2]int{{0, 0}, {1, 1}, {1, 0}, {0, 0}} {
+				if results[i].Matched != counts[0] || results[i].Modified != counts[1] {
+					t.Fatalf("wrong result %+v, expect matched %d and modified %d", results[i], counts[0], counts[1])
+				}
+			}
+			if nrec, err := db.Count(ctx, dbname, collname, map[string]any{"energy": 10}); err != nil || nrec != 1 {
+				t.Fatalf("expected 1 updated record, got %d, error %v", nrec, err)
+			}
+			ops = []WriteOp{{Type: DeleteOp, Spec: map[string]any{"group": 1}}}
+			results, err = BulkWrite(ctx, db, dbname, collname, ops, true)
+			if err != nil || results[0].Matched != 2 {
+				t.Fatalf("wrong delete result %+v, error %v", results, err)
+			}
+		})
+	}
+}
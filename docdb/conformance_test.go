@@ -0,0 +1,211 @@
+package docdb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"testing"
+
+	query "github.com/CHESSComputing/golib/embed/query"
+	bson "go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// root directory of embedded databases of the tests
+var testRoot string
+
+// TestMain removes directories of embedded databases once all tests are done,
+// since embedded backends keep their last database open and close it only
+// when they are initialized again
+func TestMain(m *testing.M) {
+	var err error
+	testRoot, err = os.MkdirTemp("", "docdb-")
+	if err != nil {
+		fmt.Println("unable to create test directory", err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	os.RemoveAll(testRoot)
+	os.Exit(code)
+}
+
+// helper function to create directory of embedded database
+func dbDir(t *testing.T) string {
+	dir, err := os.MkdirTemp(testRoot, "db-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// conformanceBackend represents DocDB implementation tested by conformance suite
+type conformanceBackend struct {
+	name string
+	uri  func(t *testing.T) string
+}
+
+// conformanceBackends returns list of DocDB implementations to test, MongoDB
+// backend is tested only if DOCDB_MONGO_URI environment variable is set
+func conformanceBackends() []conformanceBackend {
+	backends := []conformanceBackend{
+		{"badger", func(t *testing.T) string { return "badger://" + dbDir(t) }},
+		{"clover", func(t *testing.T) string { return "clover://" + dbDir(t) }},
+		{"tiedot", func(t *testing.T) string { return "tiedot://" + dbDir(t) }},
+		{"memory", func(t *testing.T) string { return "memory://" }},
+	}
+	if uri := os.Getenv("DOCDB_MONGO_URI"); uri != "" {
+		backends = append(backends, conformanceBackend{"mongo", func(t *testing.T) string { return uri }})
+	}
+	return backends
+}
+
+// helper function to convert MongoDB documents into plain maps and slices
+func plain(val any) any {
+	switch v := val.(type) {
+	case bson.D:
+		out := make(map[string]any)
+		for _, e := range v {
+			out[e.Key] = plain(e.Value)
+		}
+		return out
+	case bson.M:
+		return plain(map[string]any(v))
+	case bson.A:
+		return plain([]any(v))
+	case map[string]any:
+		out := make(map[string]any)
+		for k, e := range v {
+			out[k] = plain(e)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = plain(e)
+		}
+		return out
+	}
+	return val
+}
+
+// helper function to check that records are equal to expected ones
+func checkRecords(t *testing.T, name string, records []map[string]any, expect []map[string]any) {
+	t.Helper()
+	if len(records) != len(expect) {
+		t.Fatalf("%s: expected %d records, got %d: %v", name, len(expect), len(records), records)
+	}
+	for i, rec := range records {
+		if query.Compare(plain(rec), expect[i]) != 0 || len(rec) != len(expect[i]) {
+			t.Fatalf("%s: record %d, expected %v, got %v", name, i, expect[i], plain(rec))
+		}
+	}
+}
+
+// TestConformance runs the same DocDB test cases against all backends
+func TestConformance(t *testing.T) {
+	for _, backend := range conformanceBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			db, err := Open(context.Background(), backend.uri(t))
+			if err != nil {
+				t.Fatalf("Open error: %v", err)
+			}
+			testConformance(t, NewDocDB(db))
+		})
+	}
+}
+
+func testConformance(t *testing.T, db DocDB) {
+	dbname, collname := "chess", "conformance"
+	if err := db.Remove(dbname, collname, map[string]any{}); err != nil {
+		t.Fatalf("Remove error: %v", err)
+	}
+	records := []map[string]any{
+		{"did": "/a", "energy": 10, "sample": map[string]any{"name": "Fe", "mass": 1},
+			"scans": []any{map[string]any{"id": 1, "n": 10}, map[string]any{"id": 2, "n": 20}}},
+		{"did": "/b", "energy": 30, "sample": map[string]any{"name": "Cu", "mass": 2}},
+		{"did": "/c", "energy": 20},
+	}
+	if err := db.Upsert(dbname, collname, "did", records); err != nil {
+		t.Fatalf("Upsert error: %v", err)
+	}
+	if nrec := db.Count(dbname, collname, map[string]any{}); nrec != 3 {
+		t.Fatalf("Count: expected 3 records, got %d", nrec)
+	}
+	sortRecords := func(records []map[string]any) []map[string]any {
+		sort.Slice(records, func(i, j int) bool {
+			return fmt.Sprint(records[i]["did"]) < fmt.Sprint(records[j]["did"])
+		})
+		return records
+	}
+
+	// projection tests
+	tests := []struct {
+		projection map[string]int
+		expect     []map[string]any
+	}{
+		{map[string]int{"did": 1}, []map[string]any{{"did": "/a"}, {"did": "/b"}, {"did": "/c"}}},
+		{map[string]int{"did": 1, "sample.name": 1}, []map[string]any{
+			{"did": "/a", "sample": map[string]any{"name": "Fe"}},
+			{"did": "/b", "sample": map[string]any{"name": "Cu"}},
+			{"did": "/c"},
+		}},
+		{map[string]int{"did": 1, "scans.id": 1, "_id": 0}, []map[string]any{
+			{"did": "/a", "scans": []any{map[string]any{"id": 1}, map[string]any{"id": 2}}},
+			{"did": "/b"},
+			{"did": "/c"},
+		}},
+		{map[string]int{"sample": 0, "scans": 0}, []map[string]any{
+			{"did": "/a", "energy": 10},
+			{"did": "/b", "energy": 30},
+			{"did": "/c", "energy": 20},
+		}},
+		{map[string]int{"sample.mass": 0, "scans.n": 0, "energy": 0}, []map[string]any{
+			{"did": "/a", "sample": map[string]any{"name": "Fe"},
+				"scans": []any{map[string]any{"id": 1}, map[string]any{"id": 2}}},
+			{"did": "/b", "sample": map[string]any{"name": "Cu"}},
+			{"did": "/c"},
+		}},
+	}
+	for _, test := range tests {
+		results := db.GetProjection(dbname, collname, map[string]any{}, test.projection, 0, 0)
+		checkRecords(t, fmt.Sprintf("GetProjection %v", test.projection), sortRecords(results), test.expect)
+	}
+
+	// sorted look-ups
+	results := db.GetSorted(dbname, collname, map[string]any{}, []string{"energy"}, -1, 1, 1)
+	checkRecords(t, "GetSorted", results, []map[string]any{records[2]})
+
+	// upsert of existing records
+	err := db.Upsert(dbname, collname, "did", []map[string]any{{"did": "/c", "energy": 40}, {"did": "/b", "energy": 50}})
+	if err != nil {
+		t.Fatalf("Upsert error: %v", err)
+	}
+	results = db.GetProjection(dbname, collname, map[string]any{"energy": map[string]any{"$gte": 40}}, map[string]int{"did": 1, "energy": 1}, 0, 0)
+	checkRecords(t, "Upsert", sortRecords(results), []map[string]any{
+		{"did": "/b", "energy": 50},
+		{"did": "/c", "energy": 40},
+	})
+
+	// update with operators
+	err = db.Update(dbname, collname, map[string]any{"did": "/b"}, map[string]any{
+		"$set": map[string]any{"sample.name": "Ag"}, "$inc": map[string]any{"energy": 5}})
+	if err != nil {
+		t.Fatalf("Update error: %v", err)
+	}
+	results = db.GetProjection(dbname, collname, map[string]any{"did": "/b"}, map[string]int{"sample": 1, "energy": 1}, 0, 0)
+	checkRecords(t, "Update", results, []map[string]any{
+		{"energy": 55, "sample": map[string]any{"name": "Ag", "mass": 2}},
+	})
+
+	// distinct values and removal of records
+	values, err := db.Distinct(dbname, collname, "did")
+	if err != nil || len(values) != 3 {
+		t.Fatalf("Distinct: expected 3 values, got %v, error %v", values, err)
+	}
+	if err := db.Remove(dbname, collname, map[string]any{"did": "/a"}); err != nil {
+		t.Fatalf("Remove error: %v", err)
+	}
+	if nrec := db.Count(dbname, collname, map[string]any{}); nrec != 2 {
+		t.Fatalf("Count: expected 2 records, got %d", nrec)
+	}
+}
@@ -0,0 +1,103 @@
+package docdb
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	embed "github.com/CHESSComputing/golib/embed/badger"
+	clover "github.com/CHESSComputing/golib/embed/clover"
+	memory "github.com/CHESSComputing/golib/embed/memory"
+	tiedot "github.com/CHESSComputing/golib/embed/tiedot"
+	mongo "github.com/CHESSComputing/golib/mongo"
+)
+
+// Factory creates and initializes DocDBV2 object for given URI
+type Factory func(ctx context.Context, uri string) (DocDBV2, error)
+
+// factories holds DocDB factories for URI schemes
+var factories = make(map[string]Factory)
+var factoryMutex sync.RWMutex
+
+func init() {
+	Register("mongodb", openMongo)
+	Register("mongodb+srv", openMongo)
+	Register("badger", openBadger)
+	Register("clover", openClover)
+	Register("tiedot", openTiedot)
+	Register("memory", openMemory)
+}
+
+// Register registers DocDB factory for given URI scheme, out-of-tree backends
+// can use it to add themselves, e.g. from init function of their package
+func Register(scheme string, factory Factory) {
+	factoryMutex.Lock()
+	defer factoryMutex.Unlock()
+	factories[strings.ToLower(scheme)] = factory
+}
+
+// Schemes returns sorted list of registered URI schemes
+func Schemes() []string {
+	factoryMutex.RLock()
+	defer factoryMutex.RUnlock()
+	var schemes []string
+	for scheme := range factories {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Open creates DocDBV2 object for given URI using factory registered for its
+// scheme, e.g. mongodb://host:port, badger://path, clover://path,
+// tiedot://path or memory://
+func Open(ctx context.Context, uri string) (DocDBV2, error) {
+	scheme, _, ok := strings.Cut(uri, "://")
+	if !ok {
+		return nil, fmt.Errorf("[golib.docdb.Open] missing scheme in uri %s", uri)
+	}
+	factoryMutex.RLock()
+	factory, ok := factories[strings.ToLower(scheme)]
+	factoryMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("[golib.docdb.Open] unsupported scheme %s, supported schemes %v", scheme, Schemes())
+	}
+	log.Printf("Initializing DocDB with %s backend", scheme)
+	return factory(ctx, uri)
+}
+
+// helper function to return path part of given URI
+func uriPath(uri string) string {
+	_, path, _ := strings.Cut(uri, "://")
+	return path
+}
+
+// helper function to create MongoDB backend
+func openMongo(ctx context.Context, uri string) (DocDBV2, error) {
+	db := &mongo.MongoDBV2{}
+	return db, db.InitDB(ctx, uri)
+}
+
+// helper function to create BadgerDB backend
+func openBadger(ctx context.Context, uri string) (DocDBV2, error) {
+	return &embed.EmbedDBV2{}, embed.InitDB(uriPath(uri))
+}
+
+// helper function to create Clover backend
+func openClover(ctx context.Context, uri string) (DocDBV2, error) {
+	return &clover.EmbedDBV2{}, clover.InitDB(uriPath(uri))
+}
+
+// helper function to create TiedotDB backend
+func openTiedot(ctx context.Context, uri string) (DocDBV2, error) {
+	return &tiedot.EmbedDBV2{}, tiedot.InitDB(uriPath(uri))
+}
+
+// helper function to create in-memory backend, every call returns new
+// database instance
+func openMemory(ctx context.Context, uri string) (DocDBV2, error) {
+	return memory.NewMemoryDBV2(), nil
+}
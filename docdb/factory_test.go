@@ -0,0 +1,93 @@
+package docdb
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	embed "github.com/CHESSComputing/golib/embed/badger"
+	clover "github.com/CHESSComputing/golib/embed/clover"
+	memory "github.com/CHESSComputing/golib/embed/memory"
+	tiedot "github.com/CHESSComputing/golib/embed/tiedot"
+)
+
+// TestRegister tests registration of out-of-tree DocDB backend
+func TestRegister(t *testing.T) {
+	errFake := errors.New("fake backend")
+	var opened string
+	Register("fake", func(ctx context.Context, uri string) (DocDBV2, error) {
+		opened = uri
+		return nil, errFake
+	})
+	if _, err := Open(context.Background(), "FAKE://some/path"); !errors.Is(err, errFake) {
+		t.Fatalf("expected fake backend error, got %v", err)
+	}
+	if opened != "FAKE://some/path" {
+		t.Fatalf("wrong uri passed to factory: %s", opened)
+	}
+	found := false
+	for _, scheme := range Schemes() {
+		if scheme == "fake" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("fake scheme is not registered: %v", Schemes())
+	}
+}
+
+// TestOpen tests creation of DocDB backends from URIs
+func TestOpen(t *testing.T) {
+	for _, uri := range []string{"/some/path", "foo://some/path"} {
+		if _, err := Open(context.Background(), uri); err == nil {
+			t.Fatalf("expected error for uri %s", uri)
+		}
+	}
+	db, err := Open(context.Background(), "memory://")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, ok := db.(*memory.MemoryDBV2); !ok {
+		t.Fatalf("wrong DocDB type %T", db)
+	}
+
+	// Embed.DocDb may hold URI which takes precedence over configured engine
+	srvConfig.Config = &srvConfig.SrvConfig{}
+	srvConfig.Config.Embed.DocDb = "clover://" + dbDir(t)
+	srvConfig.Config.Embed.Engine = "badger"
+	db, err = InitializeDocDBV2(context.Background(), "")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, ok := db.(*clover.EmbedDBV2); !ok {
+		t.Fatalf("wrong DocDB type %T", db)
+	}
+}
+
+// TestEmbedInitDB tests that embedded backends are initialized with path of
+// given URI rather than with path of server configuration
+func TestEmbedInitDB(t *testing.T) {
+	ctx := context.Background()
+	backends := map[string]DocDBV2{
+		"badger": &embed.EmbedDBV2{},
+		"clover": &clover.EmbedDBV2{},
+		"tiedot": &tiedot.EmbedDBV2{},
+	}
+	for scheme, db := range backends {
+		srvConfig.Config = &srvConfig.SrvConfig{}
+		srvConfig.Config.Embed.DocDb = filepath.Join(t.TempDir(), "config")
+		dir := filepath.Join(dbDir(t), scheme)
+		if err := db.InitDB(ctx, scheme+"://"+dir); err != nil {
+			t.Fatalf("%s: InitDB error %v", scheme, err)
+		}
+		if _, err := os.Stat(dir); err != nil {
+			t.Fatalf("%s: database is not created in %s: %v", scheme, dir, err)
+		}
+		if _, err := os.Stat(srvConfig.Config.Embed.DocDb); err == nil {
+			t.Fatalf("%s: database is created in configuration path", scheme)
+		}
+	}
+}
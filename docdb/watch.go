@@ -0,0 +1,107 @@
+package docdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	bson "go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ErrWatchNotSupported is returned when DocDB backend does not support change streams
+var ErrWatchNotSupported = errors.New("change streams are not supported")
+
+// change event types
+const (
+	InsertEvent = "insert"
+	UpdateEvent = "update"
+	DeleteEvent = "delete"
+)
+
+// ChangeEvent represents insert, update or delete of DocDB record
+type ChangeEvent struct {
+	Type       string         // event type: insert, update or delete
+	DBName     string         // database name
+	Collection string         // collection name
+	ID         any            // _id of changed record
+	Record     map[string]any // record after insert or update, nil for delete events
+}
+
+// Watcher represents DocDB backend which supports change streams, it
+// delivers change events with structure of MongoDB change stream documents
+type Watcher interface {
+	Watch(ctx context.Context, dbname, collname string, spec map[string]any) (<-chan map[string]any, error)
+}
+
+// Watch returns channel of change events of given database/collection
+// records which match given spec. It is backed by MongoDB change streams or
+// by change log of embedded backends. Delete events carry only _id of the
+// deleted record and therefore are delivered regardless of the spec. The
+// channel is closed when context is done, e.g.
+//
+//	events, err := docdb.Watch(ctx, db, dbname, collname, spec)
+//	for event := range events {
+//		log.Println(event.Type, event.ID)
+//	}
+func Watch(ctx context.Context, db DocDBV2, dbname, collname string, spec map[string]any) (<-chan ChangeEvent, error) {
+	wdb, ok := db.(Watcher)
+	if !ok {
+		return nil, fmt.Errorf("[golib.docdb.Watch] %w by %T", ErrWatchNotSupported, db)
+	}
+	if spec == nil {
+		spec = map[string]any{}
+	}
+	in, err := wdb.Watch(ctx, dbname, collname, spec)
+	if err != nil {
+		return nil, fmt.Errorf("[golib.docdb.Watch] watch error: %w", err)
+	}
+	out := make(chan ChangeEvent)
+	go func() {
+		defer close(out)
+		for doc := range in {
+			select {
+			case out <- changeEvent(doc):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// helper function to convert change stream document into ChangeEvent,
+// like other APIs it does not return _id as part of the record
+func changeEvent(doc map[string]any) ChangeEvent {
+	event := ChangeEvent{}
+	event.Type, _ = doc["operationType"].(string)
+	ns := document(doc["ns"])
+	event.DBName, _ = ns["db"].(string)
+	event.Collection, _ = ns["coll"].(string)
+	event.ID = document(doc["documentKey"])["_id"]
+	if rec := document(doc["fullDocument"]); rec != nil {
+		event.Record = make(map[string]any, len(rec))
+		for k, v := range rec {
+			if k != "_id" {
+				event.Record[k] = v
+			}
+		}
+	}
+	return event
+}
+
+// helper function to convert MongoDB document into map
+func document(val any) map[string]any {
+	switch v := val.(type) {
+	case map[string]any:
+		return v
+	case bson.M:
+		return v
+	case bson.D:
+		out := make(map[string]any, len(v))
+		for _, e := range v {
+			out[e.Key] = e.Value
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package docdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// helper function to receive given number of events from a channel
+func receiveEvents(t *testing.T, events <-chan ChangeEvent, nevents int) []ChangeEvent {
+	t.Helper()
+	var out []ChangeEvent
+	timeout := time.After(5 * time.Second)
+	for len(out) < nevents {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatalf("events channel is closed, received %+v", out)
+			}
+			out = append(out, event)
+		case <-timeout:
+			t.Fatalf("timeout, received %+v", out)
+		}
+	}
+	return out
+}
+
+// TestWatch tests change events of all backends
+func TestWatch(t *testing.T) {
+	for _, backend := range conformanceBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			db, err := Open(ctx, backend.uri(t))
+			if err != nil {
+				t.Fatalf("Open error: %v", err)
+			}
+			dbname, collname := "chess", "watch"
+			if err := db.Remove(ctx, dbname, collname, map[string]any{}); err != nil {
+				t.Fatalf("Remove error: %v", err)
+			}
+			events, err := Watch(ctx, db, dbname, collname, map[string]any{"energy": map[string]any{"$gte": 10}})
+			if err != nil {
+				t.Fatalf("Watch error: %v", err)
+			}
+			// writes of rolled back transactions are not delivered
+			if _, ok := db.(Transactional); ok {
+				errFail := errors.New("rollback")
+				err := WithTransaction(ctx, db, func(ctx context.Context) error {
+					if err := db.Insert(ctx, dbname, collname, []map[string]any{{"did": "/x", "energy": 50}}); err != nil {
+						return err
+					}
+					return errFail
+				})
+				if !errors.Is(err, errFail) {
+					t.Fatalf("expected rollback error, got %v", err)
+				}
+			}
+			records := []map[string]any{{"did": "/a", "energy": 10}, {"did": "/b", "energy": 5}}
+			if err := db.Insert(ctx, dbname, collname, records); err != nil {
+				t.Fatalf("Insert error: %v", err)
+			}
+			err = db.Update(ctx, dbname, collname, map[string]any{"did": "/a"}, map[string]any{"$set": map[string]any{"energy": 20}})
+			if err != nil {
+				t.Fatalf("Update error: %v", err)
+			}
+			// deletes are delivered regardless of the spec and carry only _id
+			for _, did := range []string{"/a", "/b"} {
+				if err := db.Remove(ctx, dbname, collname, map[string]any{"did": did}); err != nil {
+					t.Fatalf("Remove error: %v", err)
+				}
+			}
+			received := receiveEvents(t, events, 4)
+			for i, etype := range []string{InsertEvent, UpdateEvent, DeleteEvent, DeleteEvent} {
+				event := received[i]
+				if event.Type != etype || event.DBName != dbname || event.Collection != collname || event.ID == nil {
+					t.Fatalf("wrong event %d: %+v", i, event)
+				}
+				if etype == DeleteEvent && event.Record != nil {
+					t.Fatalf("delete event %d carries record: %+v", i, event)
+				}
+			}
+			if received[0].ID != received[2].ID || received[2].ID == received[3].ID {
+				t.Fatalf("wrong ids of delete events %+v", received)
+			}
+			checkRecords(t, "Watch", []map[string]any{received[0].Record, received[1].Record}, []map[string]any{
+				{"did": "/a", "energy": 10},
+				{"did": "/a", "energy": 20},
+			})
+
+			cancel()
+			select {
+			case _, ok := <-events:
+				if ok {
+					t.Fatal("expected no more events")
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("events channel is not closed")
+			}
+		})
+	}
+}
@@ -1,17 +1,26 @@
 package embed
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 
+	changes "github.com/CHESSComputing/golib/embed/changes"
+	query "github.com/CHESSComputing/golib/embed/query"
 	"github.com/dgraph-io/badger/v4"
+	bson "go.mongodb.org/mongo-driver/v2/bson"
 )
 
 var db *badger.DB
 
-// InitDB initializes the Badger database
+// changeLog delivers change events of BadgerDB records to watchers
+var changeLog = changes.NewLog()
+
+// InitDB initializes the Badger database, records stored by previous versions
+// of the package with collname:id keys are moved into LegacyDBName database
 func InitDB(dbDir string) error {
 	// Ensure the directory exists
 	err := ensureDirExists(dbDir)
@@ -19,15 +28,133 @@ func InitDB(dbDir string) error {
 		return fmt.Errorf("failed to create directory: %v", err)
 	}
 
+	// close previously opened database to release its directory lock
+	if db != nil {
+		if err := db.Close(); err != nil {
+			return fmt.Errorf("failed to close BadgerDB: %v", err)
+		}
+		db = nil
+	}
+
 	// Open the Badger database
 	opts := badger.DefaultOptions(dbDir).WithLogger(nil)
 	db, err = badger.Open(opts)
 	if err != nil {
 		return fmt.Errorf("failed to open BadgerDB: %v", err)
 	}
+	if err := migrateKeys(); err != nil {
+		return fmt.Errorf("failed to migrate BadgerDB keys: %v", err)
+	}
+	if err := loadIndexes(); err != nil {
+		return fmt.Errorf("failed to load BadgerDB indexes: %v", err)
+	}
 	return nil
 }
 
+// entry represents BadgerDB record along with its key
+type entry struct {
+	key    []byte
+	record map[string]any
+}
+
+// ErrDuplicateKey is returned when inserted record has _id of existing record
+var ErrDuplicateKey = errors.New("duplicate key")
+
+// helper function to return key prefix of records of given database/collection
+func dataPrefix(dbname, collname string) []byte {
+	return []byte(fmt.Sprintf("%s:%s:", dbname, collname))
+}
+
+// helper function to return key of the record with given id in database/collection
+func dataKey(dbname, collname string, id any) []byte {
+	return []byte(fmt.Sprintf("%s:%s:%v", dbname, collname, id))
+}
+
+// helper function to generate new record id, we use the same format as
+// MongoDB object ids to keep ids time ordered
+func newID() string {
+	return bson.NewObjectID().Hex()
+}
+
+// helper function to return copy of records without _id attribute, as
+// MongoDB backend does not return it either
+func stripID(records []map[string]any) []map[string]any {
+	var out []map[string]any
+	for _, rec := range records {
+		nrec := make(map[string]any, len(rec))
+		for k, v := range rec {
+			if k != "_id" {
+				nrec[k] = v
+			}
+		}
+		out = append(out, nrec)
+	}
+	return out
+}
+
+// helper function to read record with given key within transaction, it
+// returns nil record if key does not exist
+func readRecord(txn *badger.Txn, key []byte) (map[string]any, error) {
+	item, err := txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var record map[string]any
+	err = item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &record)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal record: %v", err)
+	}
+	return record, nil
+}
+
+// helper function to write record and its index entries within transaction
+func setRecord(txn *badger.Txn, dbname, collname string, key []byte, record map[string]any) error {
+	oldRecord, err := readRecord(txn, key)
+	if err != nil {
+		return err
+	}
+	val, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %v", err)
+	}
+	if err := txn.Set(key, val); err != nil {
+		return err
+	}
+	return updateIndexes(txn, dbname, collname, key, oldRecord, record)
+}
+
+// helper function to insert new record within transaction, it assigns
+// generated _id to records without it and returns inserted record
+func insertRecord(txn *badger.Txn, dbname, collname string, record map[string]any) (map[string]any, error) {
+	nrec := make(map[string]any, len(record)+1)
+	for k, v := range record {
+		nrec[k] = v
+	}
+	if id, ok := nrec["_id"]; !ok || id == nil || id == "" {
+		nrec["_id"] = newID()
+	}
+	key := dataKey(dbname, collname, nrec["_id"])
+	if _, err := txn.Get(key); err == nil {
+		return nil, fmt.Errorf("%w: _id %v", ErrDuplicateKey, nrec["_id"])
+	} else if err != badger.ErrKeyNotFound {
+		return nil, err
+	}
+	return nrec, setRecord(txn, dbname, collname, key, nrec)
+}
+
+// helper function to delete record and its index entries within transaction
+func deleteRecord(txn *badger.Txn, dbname, collname string, key []byte, record map[string]any) error {
+	if err := txn.Delete(key); err != nil {
+		return err
+	}
+	return updateIndexes(txn, dbname, collname, key, record, nil)
+}
+
 // ensureDirExists checks if a directory exists, and creates it if it doesn't
 func ensureDirExists(dir string) error {
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
@@ -38,99 +165,165 @@ func ensureDirExists(dir string) error {
 
 // Insert records into BadgerDB
 func Insert(dbname, collname string, records []map[string]any) {
-	upsert(collname, records)
+	if err := InsertContext(context.TODO(), dbname, collname, records); err != nil {
+		log.Println("ERROR:", err)
+	}
+}
+
+// InsertContext inserts records into BadgerDB
+func InsertContext(ctx context.Context, dbname, collname string, records []map[string]any) error {
+	if err := upsertContext(ctx, dbname, collname, "", records); err != nil {
+		return fmt.Errorf("[golib.badger.Insert] upsert error: %w", err)
+	}
+	return nil
 }
 
 // Upsert records into BadgerDB
 func Upsert(dbname, collname, attr string, records []map[string]any) error {
-	return upsert(collname, records)
+	return UpsertContext(context.TODO(), dbname, collname, attr, records)
 }
 
-func upsert(collname string, records []map[string]interface{}) error {
-	return db.Update(func(txn *badger.Txn) error {
+// UpsertContext upserts records into BadgerDB. It follows MongoDB semantics:
+// existing record is looked up by given attribute value and new values are
+// merged into it, otherwise new record with generated _id is inserted.
+// Records without attribute value are skipped.
+func UpsertContext(ctx context.Context, dbname, collname, attr string, records []map[string]any) error {
+	if err := upsertContext(ctx, dbname, collname, attr, records); err != nil {
+		return fmt.Errorf("[golib.badger.Upsert] upsert error: %w", err)
+	}
+	return nil
+}
+
+func upsert(dbname, collname, attr string, records []map[string]interface{}) error {
+	return upsertContext(context.TODO(), dbname, collname, attr, records)
+}
+
+// helper function to upsert records using given attribute, empty attribute
+// means plain insert of records
+func upsertContext(ctx context.Context, dbname, collname, attr string, records []map[string]interface{}) error {
+	var events []map[string]any
+	err := withUpdate(ctx, func(txn *badger.Txn) error {
 		for _, record := range records {
-			key := fmt.Sprintf("%s:%v", collname, record["id"])
-			val, err := json.Marshal(record)
-			if err != nil {
-				return fmt.Errorf("failed to marshal record: %v", err)
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if attr == "" {
+				rec, err := insertRecord(txn, dbname, collname, record)
+				if err != nil {
+					return fmt.Errorf("failed to insert record: %w", err)
+				}
+				events = append(events, changes.Event(changes.Insert, dbname, collname, rec))
+				continue
+			}
+			value, ok := record[attr]
+			if !ok || value == nil || value == "" {
+				continue
 			}
-			err = txn.Set([]byte(key), val)
+			entries, err := find(ctx, txn, dbname, collname, map[string]any{attr: value})
 			if err != nil {
+				return fmt.Errorf("failed to find record: %v", err)
+			}
+			if len(entries) == 0 {
+				rec, err := insertRecord(txn, dbname, collname, record)
+				if err != nil {
+					return fmt.Errorf("failed to insert record: %w", err)
+				}
+				events = append(events, changes.Event(changes.Insert, dbname, collname, rec))
+				continue
+			}
+			// update first matched record as MongoDB UpdateOne does
+			e := entries[0]
+			for k, v := range record {
+				if k != "_id" {
+					e.record[k] = v
+				}
+			}
+			if err := setRecord(txn, dbname, collname, e.key, e.record); err != nil {
 				return fmt.Errorf("failed to upsert record: %v", err)
 			}
+			events = append(events, changes.Event(changes.Update, dbname, collname, e.record))
 		}
 		return nil
 	})
+	if err == nil {
+		publish(ctx, events)
+	}
+	return err
 }
 
 // GetProjection records from BadgerDB
 func GetProjection(dbname, collname string, spec map[string]any, projection map[string]int, idx, limit int) []map[string]any {
-	records := Get(dbname, collname, spec, idx, limit)
-	// extract projections
-	var out []map[string]any
-	for _, rec := range records {
-		for k, v := range projection {
-			if v == 1 {
-				if _, ok := rec[k]; ok {
-					nrec := make(map[string]any)
-					nrec[k] = rec[k]
-					out = append(out, nrec)
-				}
-			}
-		}
+	out, err := GetProjectionContext(context.TODO(), dbname, collname, spec, projection, idx, limit)
+	if err != nil {
+		log.Println("ERROR: ", err)
 	}
 	return out
 }
 
+// GetProjectionContext fetches records with given projection from BadgerDB
+func GetProjectionContext(ctx context.Context, dbname, collname string, spec map[string]any, projection map[string]int, idx, limit int) ([]map[string]any, error) {
+	results, err := getContext(ctx, dbname, collname, spec)
+	if err != nil {
+		return nil, fmt.Errorf("[golib.badger.GetProjection] get error: %w", err)
+	}
+	// like other APIs do not return _id unless it is explicitly requested
+	proj := map[string]int{"_id": 0}
+	for k, v := range projection {
+		proj[k] = v
+	}
+	out, err := query.Project(paginate(results, idx, limit), proj)
+	if err != nil {
+		return nil, fmt.Errorf("[golib.badger.GetProjection] projection error: %w", err)
+	}
+	return out, nil
+}
+
 // Get records from BadgerDB
 func Get(dbname, collname string, spec map[string]any, idx, limit int) []map[string]any {
-	var out []map[string]any
-	results, err := get(collname, spec)
+	out, err := GetContext(context.TODO(), dbname, collname, spec, idx, limit)
 	if err != nil {
 		log.Println("ERROR: ", err)
-		return out
 	}
-	for i := idx; i < limit; i++ {
-		if i < len(results) {
-			out = append(out, results[i])
+	return out
+}
+
+// GetContext fetches records from BadgerDB for given spec and pagination
+func GetContext(ctx context.Context, dbname, collname string, spec map[string]any, idx, limit int) ([]map[string]any, error) {
+	results, err := getContext(ctx, dbname, collname, spec)
+	if err != nil {
+		return nil, err
+	}
+	return stripID(paginate(results, idx, limit)), nil
+}
+
+// helper function to return slice of records within [idx, idx+limit) window,
+// limit=0 means no limit as in MongoDB
+func paginate(records []map[string]any, idx, limit int) []map[string]any {
+	var out []map[string]any
+	if idx < 0 {
+		idx = 0
+	}
+	for i := idx; i < len(records); i++ {
+		if limit > 0 && len(out) == limit {
+			break
 		}
+		out = append(out, records[i])
 	}
 	return out
 }
-func get(collname string, spec map[string]interface{}) ([]map[string]interface{}, error) {
+
+func get(dbname, collname string, spec map[string]interface{}) ([]map[string]interface{}, error) {
+	return getContext(context.TODO(), dbname, collname, spec)
+}
+
+func getContext(ctx context.Context, dbname, collname string, spec map[string]interface{}) ([]map[string]interface{}, error) {
 	var results []map[string]interface{}
-	err := db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchValues = true
-		it := txn.NewIterator(opts)
-		defer it.Close()
-
-		prefix := []byte(collname + ":")
-		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-			item := it.Item()
-			err := item.Value(func(val []byte) error {
-				var record map[string]interface{}
-				err := json.Unmarshal(val, &record)
-				if err != nil {
-					return fmt.Errorf("failed to unmarshal record: %v", err)
-				}
-				match := true
-				for k, v := range spec {
-					if record[k] != v {
-						match = false
-						break
-					}
-				}
-				if match {
-					results = append(results, record)
-				}
-				return nil
-			})
-			if err != nil {
-				return fmt.Errorf("error reading value: %v", err)
-			}
+	err := withView(ctx, func(txn *badger.Txn) error {
+		entries, err := find(ctx, txn, dbname, collname, spec)
+		for _, e := range entries {
+			results = append(results, e.record)
 		}
-		return nil
+		return err
 	})
 	if err != nil {
 		return results, fmt.Errorf("[golib.badger.Get] db.View error: %w", err)
@@ -138,51 +331,162 @@ func get(collname string, spec map[string]interface{}) ([]map[string]interface{}
 	return results, nil
 }
 
+// helper function to find records matching given spec within transaction
+func find(ctx context.Context, txn *badger.Txn, dbname, collname string, spec map[string]any) ([]entry, error) {
+	var entries []entry
+	err := scan(ctx, txn, dbname, collname, spec, func(e entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	return entries, err
+}
+
+// helper function to pass records matching given spec to given function,
+// it uses secondary index when spec has indexed equality or range term and
+// falls back to full collection scan otherwise
+func scan(ctx context.Context, txn *badger.Txn, dbname, collname string, spec map[string]any, fn func(e entry) error) error {
+	matcher, err := query.Compile(spec)
+	if err != nil {
+		return fmt.Errorf("query.Compile error: %w", err)
+	}
+	if plan := planIndex(dbname, collname, spec); plan != nil {
+		keys, err := plan.keys(txn, dbname, collname)
+		if err != nil {
+			return fmt.Errorf("index look-up error: %v", err)
+		}
+		for _, key := range keys {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			record, err := readRecord(txn, key)
+			if err != nil {
+				return fmt.Errorf("error reading value: %v", err)
+			}
+			if record != nil && matcher.Match(record) {
+				if err := fn(entry{key: key, record: record}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = true
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	prefix := dataPrefix(dbname, collname)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		item := it.Item()
+		var record map[string]interface{}
+		err := item.Value(func(val []byte) error {
+			if err := json.Unmarshal(val, &record); err != nil {
+				return fmt.Errorf("failed to unmarshal record: %v", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("error reading value: %v", err)
+		}
+		if matcher.Match(record) {
+			if err := fn(entry{key: item.KeyCopy(nil), record: record}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // Update records in BadgerDB
 func Update(dbname, collname string, spec, newdata map[string]any) error {
-	err := update(collname, spec, newdata)
+	err := UpdateContext(context.TODO(), dbname, collname, spec, newdata)
 	if err != nil {
 		log.Println("ERROR:", err)
-		return fmt.Errorf("[golib.badger.Update] update error: %w", err)
 	}
-	return nil
+	return err
+}
+
+// UpdateContext updates first record matching given spec in BadgerDB
+func UpdateContext(ctx context.Context, dbname, collname string, spec, newdata map[string]any) error {
+	_, _, err := UpdateCountContext(ctx, dbname, collname, spec, newdata)
+	return err
+}
+
+// UpdateCountContext updates first record matching given spec in BadgerDB,
+// as MongoDB UpdateOne does, and returns number of matched and modified records
+func UpdateCountContext(ctx context.Context, dbname, collname string, spec, newdata map[string]any) (int, int, error) {
+	matched, modified, err := updateContext(ctx, dbname, collname, spec, newdata)
+	if err != nil {
+		return 0, 0, fmt.Errorf("[golib.badger.Update] update error: %w", err)
+	}
+	return matched, modified, nil
+}
+
+func update(dbname, collname string, spec map[string]interface{}, newdata map[string]interface{}) error {
+	_, _, err := updateContext(context.TODO(), dbname, collname, spec, newdata)
+	return err
 }
-func update(collname string, spec map[string]interface{}, newdata map[string]interface{}) error {
-	return db.Update(func(txn *badger.Txn) error {
-		results, err := get(collname, spec)
+
+func updateContext(ctx context.Context, dbname, collname string, spec map[string]interface{}, newdata map[string]interface{}) (int, int, error) {
+	var events []map[string]any
+	var matched, modified int
+	err := withUpdate(ctx, func(txn *badger.Txn) error {
+		entries, err := find(ctx, txn, dbname, collname, spec)
 		if err != nil {
 			return fmt.Errorf("failed to get records for update: %v", err)
 		}
-
-		for _, record := range results {
-			for k, v := range newdata {
-				record[k] = v
-			}
-			key := fmt.Sprintf("%s:%v", collname, record["id"])
-			val, err := json.Marshal(record)
-			if err != nil {
-				return fmt.Errorf("failed to marshal updated record: %v", err)
-			}
-			err = txn.Set([]byte(key), val)
-			if err != nil {
-				return fmt.Errorf("failed to update record: %v", err)
-			}
+		if len(entries) == 0 {
+			return nil
+		}
+		// update first matched record as MongoDB UpdateOne does
+		e := entries[0]
+		matched = 1
+		record, err := query.ApplyUpdate(e.record, newdata)
+		if err != nil {
+			return err
+		}
+		if query.Equal(e.record, record) {
+			return nil
+		}
+		modified = 1
+		err = setRecord(txn, dbname, collname, e.key, record)
+		if err != nil {
+			return fmt.Errorf("failed to update record: %v", err)
 		}
+		events = append(events, changes.Event(changes.Update, dbname, collname, record))
 		return nil
 	})
+	if err != nil {
+		return 0, 0, err
+	}
+	publish(ctx, events)
+	return matched, modified, nil
 }
 
 // Count records in BadgerDB
 func Count(dbname, collname string, spec map[string]any) int {
-	nres, err := count(collname, spec)
+	nres, err := CountContext(context.TODO(), dbname, collname, spec)
 	if err != nil {
 		log.Println("ERROR:", err)
 	}
 	return nres
 }
 
-func count(collname string, spec map[string]interface{}) (int, error) {
-	results, err := get(collname, spec)
+// CountContext counts records matching given spec in BadgerDB
+func CountContext(ctx context.Context, dbname, collname string, spec map[string]any) (int, error) {
+	return countContext(ctx, dbname, collname, spec)
+}
+
+func count(dbname, collname string, spec map[string]interface{}) (int, error) {
+	return countContext(context.TODO(), dbname, collname, spec)
+}
+
+func countContext(ctx context.Context, dbname, collname string, spec map[string]interface{}) (int, error) {
+	results, err := getContext(ctx, dbname, collname, spec)
 	if err != nil {
 		return 0, fmt.Errorf("[golib.embed.count] get error: %w", err)
 	}
@@ -191,61 +495,156 @@ func count(collname string, spec map[string]interface{}) (int, error) {
 
 // Remove records from BadgerDB
 func Remove(dbname, collname string, spec map[string]any) error {
-	return remove(collname, spec)
+	return RemoveContext(context.TODO(), dbname, collname, spec)
 }
 
-func remove(collname string, spec map[string]interface{}) error {
-	return db.Update(func(txn *badger.Txn) error {
-		results, err := get(collname, spec)
+// RemoveContext removes records matching given spec from BadgerDB
+func RemoveContext(ctx context.Context, dbname, collname string, spec map[string]any) error {
+	return removeContext(ctx, dbname, collname, spec)
+}
+
+func remove(dbname, collname string, spec map[string]interface{}) error {
+	return removeContext(context.TODO(), dbname, collname, spec)
+}
+
+func removeContext(ctx context.Context, dbname, collname string, spec map[string]interface{}) error {
+	var events []map[string]any
+	err := withUpdate(ctx, func(txn *badger.Txn) error {
+		entries, err := find(ctx, txn, dbname, collname, spec)
 		if err != nil {
 			return fmt.Errorf("failed to get records for deletion: %v", err)
 		}
 
-		for _, record := range results {
-			key := fmt.Sprintf("%s:%v", collname, record["id"])
-			err := txn.Delete([]byte(key))
+		for _, e := range entries {
+			err := deleteRecord(txn, dbname, collname, e.key, e.record)
 			if err != nil {
 				return fmt.Errorf("failed to delete record: %v", err)
 			}
+			events = append(events, changes.Event(changes.Delete, dbname, collname, e.record))
 		}
 		return nil
 	})
+	if err == nil {
+		publish(ctx, events)
+	}
+	return err
+}
+
+// Watch returns channel of change events of BadgerDB records which belong to
+// given database/collection and match given spec, the channel is closed when
+// context is done. Events are delivered once their writes are committed.
+func Watch(ctx context.Context, dbname, collname string, spec map[string]any) (<-chan map[string]any, error) {
+	return changeLog.Watch(ctx, dbname, collname, spec)
 }
 
 // Distinct gets number records from document-oriented db
 func Distinct(dbname, collname, field string) ([]any, error) {
-	spec := make(map[string]any)
-	results, err := get(collname, spec)
+	return DistinctContext(context.TODO(), dbname, collname, field)
+}
+
+// DistinctContext returns distinct values of given field from BadgerDB
+func DistinctContext(ctx context.Context, dbname, collname, field string) ([]any, error) {
 	var out []any
-	// loop over records and check if record contains the field key
+	spec := make(map[string]any)
+	results, err := getContext(ctx, dbname, collname, spec)
+	if err != nil {
+		return out, fmt.Errorf("[golib.badger.Distinct] get error: %w", err)
+	}
+	// loop over records and collect unique values of the field
+	seen := make(map[string]bool)
 	for _, rec := range results {
-		if _, ok := rec[field]; ok {
-			out = append(out, rec)
+		if val, ok := rec[field]; ok {
+			key := fmt.Sprintf("%v", val)
+			if !seen[key] {
+				seen[key] = true
+				out = append(out, val)
+			}
 		}
 	}
-	return out, fmt.Errorf("[golib.badger.Distinct] get error: %w", err)
+	return out, nil
 }
 
 // InsertRecord insert record with given spec to document-oriented db
 func InsertRecord(dbname, collname string, rec map[string]any) error {
+	return InsertRecordContext(context.TODO(), dbname, collname, rec)
+}
+
+// InsertRecordContext inserts single record into BadgerDB
+func InsertRecordContext(ctx context.Context, dbname, collname string, rec map[string]any) error {
 	var records []map[string]any
 	records = append(records, rec)
-	return upsert(collname, records)
+	return upsertContext(ctx, dbname, collname, "", records)
 }
 
 // GetSorted fetches records from document-oriented db sorted by given key with specific order
 func GetSorted(dbname, collname string, spec map[string]any, skeys []string, sortOrder, idx, limit int) []map[string]any {
-	var out []map[string]any
-	results, err := get(collname, spec)
+	out, err := GetSortedContext(context.TODO(), dbname, collname, spec, skeys, sortOrder, idx, limit)
 	if err != nil {
 		log.Println("ERROR: ", err)
-		return out
 	}
-	// TODO: implement how to properly sort records
-	for i := idx; i < limit; i++ {
-		if i < len(results) {
-			out = append(out, results[i])
+	return out
+}
+
+// GetSortedContext fetches records from BadgerDB sorted by given keys with
+// specific order. It keeps at most idx+limit records in memory and walks
+// secondary index of the sort key in order when it is available.
+func GetSortedContext(ctx context.Context, dbname, collname string, spec map[string]any, skeys []string, sortOrder, idx, limit int) ([]map[string]any, error) {
+	var results []map[string]any
+	err := withView(ctx, func(txn *badger.Txn) error {
+		if useSortIndex(dbname, collname, spec, skeys) {
+			var err error
+			results, err = sortByIndex(ctx, txn, dbname, collname, spec, skeys[0], sortOrder, idx, limit)
+			return err
 		}
+		topk := query.NewTopK(skeys, sortOrder, idx, limit)
+		err := scan(ctx, txn, dbname, collname, spec, func(e entry) error {
+			topk.Push(e.record)
+			return nil
+		})
+		results = topk.Records()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("[golib.badger.GetSorted] db.View error: %w", err)
 	}
-	return out
+	return stripID(results), nil
+}
+
+// helper function to check if records should be sorted by walking secondary
+// index, we prefer equality index look-ups since they select few records
+func useSortIndex(dbname, collname string, spec map[string]any, skeys []string) bool {
+	if len(skeys) != 1 || !indexed(dbname, collname, skeys[0]) {
+		return false
+	}
+	plan := planIndex(dbname, collname, spec)
+	return plan == nil || !isEquality(plan)
+}
+
+// helper function to fetch records within [idx, idx+limit) window by walking
+// secondary index of given sort key within transaction
+func sortByIndex(ctx context.Context, txn *badger.Txn, dbname, collname string, spec map[string]any, skey string, sortOrder, idx, limit int) ([]map[string]any, error) {
+	var results []map[string]any
+	matcher, err := query.Compile(spec)
+	if err != nil {
+		return nil, fmt.Errorf("query.Compile error: %w", err)
+	}
+	nmatch := 0
+	err = walkIndex(txn, dbname, collname, skey, sortOrder < 0, func(key []byte) (bool, error) {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		record, err := readRecord(txn, key)
+		if err != nil {
+			return false, fmt.Errorf("error reading value: %v", err)
+		}
+		if record == nil || !matcher.Match(record) {
+			return true, nil
+		}
+		nmatch++
+		if nmatch > idx {
+			results = append(results, record)
+		}
+		return limit <= 0 || len(results) < limit, nil
+	})
+	return results, err
 }
@@ -1,8 +1,13 @@
 package embed
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"testing"
+
+	badger "github.com/dgraph-io/badger/v4"
 )
 
 func setupBadgerDB(t *testing.T) string {
@@ -29,12 +34,12 @@ func TestUpsertAndGet(t *testing.T) {
 		{"id": 2, "name": "Bob", "age": 30},
 	}
 
-	err := upsert("users", records)
+	err := upsert("test", "users", "id", records)
 	if err != nil {
 		t.Fatalf("Failed to upsert records: %v", err)
 	}
 
-	results, err := get("users", map[string]interface{}{"name": "Alice"})
+	results, err := get("test", "users", map[string]interface{}{"name": "Alice"})
 	if err != nil {
 		t.Fatalf("Failed to get records: %v", err)
 	}
@@ -56,17 +61,17 @@ func TestUpdate(t *testing.T) {
 		{"id": 1, "name": "Alice", "age": 25},
 	}
 
-	err := upsert("users", records)
+	err := upsert("test", "users", "id", records)
 	if err != nil {
 		t.Fatalf("Failed to upsert records: %v", err)
 	}
 
-	err = update("users", map[string]interface{}{"name": "Alice"}, map[string]interface{}{"age": 26})
+	err = update("test", "users", map[string]interface{}{"name": "Alice"}, map[string]interface{}{"age": 26})
 	if err != nil {
 		t.Fatalf("Failed to update records: %v", err)
 	}
 
-	results, err := get("users", map[string]interface{}{"name": "Alice"})
+	results, err := get("test", "users", map[string]interface{}{"name": "Alice"})
 	if err != nil {
 		t.Fatalf("Failed to get records: %v", err)
 	}
@@ -90,12 +95,12 @@ func TestCount(t *testing.T) {
 		{"id": 2, "name": "Bob", "age": 30},
 	}
 
-	err := upsert("users", records)
+	err := upsert("test", "users", "id", records)
 	if err != nil {
 		t.Fatalf("Failed to upsert records: %v", err)
 	}
 
-	count, err := count("users", map[string]interface{}{})
+	count, err := count("test", "users", map[string]interface{}{})
 	if err != nil {
 		t.Fatalf("Failed to count records: %v", err)
 	}
@@ -113,17 +118,17 @@ func TestRemove(t *testing.T) {
 		{"id": 1, "name": "Alice", "age": 25},
 	}
 
-	err := upsert("users", records)
+	err := upsert("test", "users", "id", records)
 	if err != nil {
 		t.Fatalf("Failed to upsert records: %v", err)
 	}
 
-	err = remove("users", map[string]interface{}{"name": "Alice"})
+	err = remove("test", "users", map[string]interface{}{"name": "Alice"})
 	if err != nil {
 		t.Fatalf("Failed to remove records: %v", err)
 	}
 
-	results, err := get("users", map[string]interface{}{"name": "Alice"})
+	results, err := get("test", "users", map[string]interface{}{"name": "Alice"})
 	if err != nil {
 		t.Fatalf("Failed to get records: %v", err)
 	}
@@ -132,3 +137,348 @@ func TestRemove(t *testing.T) {
 		t.Fatalf("Expected 0 records, got %d", len(results))
 	}
 }
+
+func TestContextAPIs(t *testing.T) {
+	defer teardownBadgerDB()
+	setupBadgerDB(t)
+
+	records := []map[string]interface{}{
+		{"id": 1, "name": "Alice", "age": 25},
+		{"id": 2, "name": "Bob", "age": 30},
+		{"id": 3, "name": "Bob", "age": 35},
+	}
+	ctx := context.Background()
+	err := InsertContext(ctx, "test", "users", records)
+	if err != nil {
+		t.Fatalf("Failed to insert records: %v", err)
+	}
+
+	// limit=0 should return all records starting from given index
+	results, err := GetContext(ctx, "test", "users", map[string]any{}, 1, 0)
+	if err != nil {
+		t.Fatalf("Failed to get records: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(results))
+	}
+
+	values, err := DistinctContext(ctx, "test", "users", "name")
+	if err != nil {
+		t.Fatalf("Failed to get distinct values: %v", err)
+	}
+	if len(values) != 2 {
+		t.Fatalf("Expected 2 distinct values, got %v", values)
+	}
+
+	// cancelled context should be reported back to the caller
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := CountContext(cctx, "test", "users", map[string]any{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled error, got %v", err)
+	}
+}
+
+func TestQueryOperators(t *testing.T) {
+	defer teardownBadgerDB()
+	setupBadgerDB(t)
+
+	records := []map[string]interface{}{
+		{"id": 1, "did": "/beamline=3a/cycle=2024-1", "energy": 10, "sample": map[string]any{"name": "Fe"}},
+		{"id": 2, "did": "/beamline=3a/cycle=2024-2", "energy": 20, "sample": map[string]any{"name": "Cu"}},
+		{"id": 3, "did": "/beamline=4b/cycle=2024-2", "energy": 30},
+	}
+	err := upsert("test", "meta", "id", records)
+	if err != nil {
+		t.Fatalf("Failed to upsert records: %v", err)
+	}
+
+	tests := []struct {
+		spec map[string]any
+		nres int
+	}{
+		{map[string]any{"did": map[string]any{"$regex": "BEAMLINE=3A", "$options": "i"}}, 2},
+		{map[string]any{"energy": map[string]any{"$gt": 10, "$lt": 30}}, 1},
+		{map[string]any{"$or": []any{
+			map[string]any{"energy": 10},
+			map[string]any{"sample.name": map[string]any{"$exists": false}},
+		}}, 2},
+	}
+	for _, test := range tests {
+		results, err := get("test", "meta", test.spec)
+		if err != nil {
+			t.Fatalf("Failed to get records: %v", err)
+		}
+		if len(results) != test.nres {
+			t.Fatalf("spec %v, expected %d records, got %d", test.spec, test.nres, len(results))
+		}
+	}
+}
+
+func TestIndexes(t *testing.T) {
+	defer teardownBadgerDB()
+	setupBadgerDB(t)
+
+	records := []map[string]interface{}{
+		{"id": 1, "btr": "abc", "cycle": "2024-1", "energy": 10},
+		{"id": 2, "btr": "abc", "cycle": "2024-2", "energy": 20},
+		{"id": 3, "btr": "xyz", "cycle": "2024-2", "energy": 30},
+	}
+	if err := upsert("test", "meta", "id", records); err != nil {
+		t.Fatalf("Failed to upsert records: %v", err)
+	}
+	for _, field := range []string{"btr", "energy"} {
+		if err := CreateIndex("test", "meta", field); err != nil {
+			t.Fatalf("Failed to create index: %v", err)
+		}
+	}
+	if fields := Indexes("test", "meta"); len(fields) != 2 {
+		t.Fatalf("Expected 2 indexes, got %v", fields)
+	}
+	// insert record after index creation
+	if err := upsert("test", "meta", "id", []map[string]any{{"id": 4, "btr": "abc", "energy": 40}}); err != nil {
+		t.Fatalf("Failed to upsert records: %v", err)
+	}
+
+	tests := []struct {
+		spec map[string]any
+		nres int
+	}{
+		{map[string]any{"btr": "abc"}, 3},
+		{map[string]any{"btr": "abc", "cycle": "2024-2"}, 1},
+		{map[string]any{"btr": map[string]any{"$in": []any{"xyz", "foo"}}}, 1},
+		{map[string]any{"energy": map[string]any{"$gt": 10, "$lte": 30}}, 2},
+		{map[string]any{"energy": map[string]any{"$gte": 40}}, 1},
+	}
+	check := func() {
+		for _, test := range tests {
+			if planIndex("test", "meta", test.spec) == nil {
+				t.Fatalf("spec %v does not use index", test.spec)
+			}
+			results, err := get("test", "meta", test.spec)
+			if err != nil {
+				t.Fatalf("Failed to get records: %v", err)
+			}
+			if len(results) != test.nres {
+				t.Fatalf("spec %v, expected %d records, got %d", test.spec, test.nres, len(results))
+			}
+		}
+	}
+	check()
+
+	// update and remove records and check that indexes are in sync
+	if err := update("test", "meta", map[string]any{"id": 3}, map[string]any{"btr": "abc"}); err != nil {
+		t.Fatalf("Failed to update records: %v", err)
+	}
+	if err := remove("test", "meta", map[string]any{"id": 4}); err != nil {
+		t.Fatalf("Failed to remove records: %v", err)
+	}
+	tests[1].nres = 2
+	tests[2].nres = 0
+	tests[4].nres = 0
+	check()
+
+	// index definitions should survive database re-open
+	dir := db.Opts().Dir
+	teardownBadgerDB()
+	if err := InitDB(dir); err != nil {
+		t.Fatalf("Failed to re-open BadgerDB: %v", err)
+	}
+	check()
+	if err := DropIndex("test", "meta", "btr"); err != nil {
+		t.Fatalf("Failed to drop index: %v", err)
+	}
+	if planIndex("test", "meta", map[string]any{"btr": "abc"}) != nil {
+		t.Fatalf("dropped index is still used")
+	}
+}
+
+func TestUpsertAttribute(t *testing.T) {
+	defer teardownBadgerDB()
+	setupBadgerDB(t)
+
+	records := []map[string]interface{}{
+		{"did": "/beamline=3a/btr=abc", "energy": 10},
+		{"did": "/beamline=3a/btr=xyz", "energy": 20},
+		{"energy": 30},
+	}
+	if err := upsert("test", "meta", "did", records); err != nil {
+		t.Fatalf("Failed to upsert records: %v", err)
+	}
+	// records without upsert attribute are skipped
+	if nrec, err := count("test", "meta", map[string]any{}); err != nil || nrec != 2 {
+		t.Fatalf("Expected 2 records, got %d, error %v", nrec, err)
+	}
+	results, err := get("test", "meta", map[string]any{"did": "/beamline=3a/btr=abc"})
+	if err != nil || len(results) != 1 {
+		t.Fatalf("Expected 1 record, got %v, error %v", results, err)
+	}
+	oid, ok := results[0]["_id"].(string)
+	if !ok || oid == "" {
+		t.Fatalf("Expected generated _id, got %v", results[0]["_id"])
+	}
+
+	// upsert of existing record merges new values and keeps its _id
+	records = []map[string]interface{}{{"did": "/beamline=3a/btr=abc", "energy": 15, "cycle": "2024-3"}}
+	if err := upsert("test", "meta", "did", records); err != nil {
+		t.Fatalf("Failed to upsert records: %v", err)
+	}
+	results, err = get("test", "meta", map[string]any{"did": "/beamline=3a/btr=abc"})
+	if err != nil || len(results) != 1 {
+		t.Fatalf("Expected 1 record, got %v, error %v", results, err)
+	}
+	if results[0]["_id"] != oid || results[0]["energy"] != 15.0 || results[0]["cycle"] != "2024-3" {
+		t.Fatalf("Wrong upserted record %v", results[0])
+	}
+
+	// public APIs do not expose _id
+	records, err = GetContext(context.Background(), "test", "meta", map[string]any{}, 0, 0)
+	if err != nil || len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %v, error %v", records, err)
+	}
+	if _, ok := records[0]["_id"]; ok {
+		t.Fatalf("Record should not contain _id, got %v", records[0])
+	}
+
+	// records are stored per database
+	if nrec, err := count("other", "meta", map[string]any{}); err != nil || nrec != 0 {
+		t.Fatalf("Expected 0 records in other database, got %d, error %v", nrec, err)
+	}
+
+	// insert of record with existing _id fails
+	err = InsertRecordContext(context.Background(), "test", "meta", map[string]any{"_id": oid})
+	if !errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("Expected ErrDuplicateKey error, got %v", err)
+	}
+}
+
+func TestGetSorted(t *testing.T) {
+	defer teardownBadgerDB()
+	setupBadgerDB(t)
+
+	records := []map[string]interface{}{
+		{"id": 1, "btr": "abc", "energy": 30},
+		{"id": 2, "btr": "abc", "energy": 10},
+		{"id": 3, "btr": "xyz", "energy": []any{5, 50}},
+		{"id": 4, "btr": "abc"},
+		{"id": 5, "btr": "abc", "energy": 20},
+	}
+	if err := upsert("test", "meta", "id", records); err != nil {
+		t.Fatalf("Failed to upsert records: %v", err)
+	}
+	tests := []struct {
+		spec      map[string]any
+		sortOrder int
+		idx       int
+		limit     int
+		ids       []float64
+	}{
+		{map[string]any{}, 1, 0, 0, []float64{4, 3, 2, 5, 1}},
+		{map[string]any{}, -1, 0, 2, []float64{3, 1}},
+		{map[string]any{}, 1, 1, 2, []float64{3, 2}},
+		{map[string]any{"btr": "abc"}, -1, 1, 0, []float64{5, 2, 4}},
+		{map[string]any{"energy": map[string]any{"$gte": 10}}, 1, 0, 2, []float64{3, 2}},
+	}
+	check := func() {
+		for _, test := range tests {
+			results, err := GetSortedContext(context.Background(), "test", "meta", test.spec, []string{"energy"}, test.sortOrder, test.idx, test.limit)
+			if err != nil {
+				t.Fatalf("Failed to get sorted records: %v", err)
+			}
+			var ids []float64
+			for _, rec := range results {
+				ids = append(ids, rec["id"].(float64))
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(test.ids) {
+				t.Fatalf("spec %v order %d, expected %v, got %v", test.spec, test.sortOrder, test.ids, ids)
+			}
+		}
+	}
+	check()
+
+	// index walk should return the same records
+	if err := CreateIndex("test", "meta", "energy"); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	if !useSortIndex("test", "meta", map[string]any{}, []string{"energy"}) {
+		t.Fatalf("sort does not use index")
+	}
+	check()
+}
+
+func TestMigrateKeys(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// store records with legacy collname:id keys
+	legacyDB, err := badger.Open(badger.DefaultOptions(tempDir).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open BadgerDB: %v", err)
+	}
+	err = legacyDB.Update(func(txn *badger.Txn) error {
+		for i, name := range []string{"Alice", "Bob"} {
+			val, _ := json.Marshal(map[string]any{"id": i, "name": name})
+			if err := txn.Set([]byte(fmt.Sprintf("users:%d", i)), val); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to store legacy records: %v", err)
+	}
+	legacyDB.Close()
+
+	// records are migrated once and keep their data
+	for i := 0; i < 2; i++ {
+		if err := InitDB(tempDir); err != nil {
+			t.Fatalf("Failed to initialize BadgerDB: %v", err)
+		}
+		if i == 0 {
+			if err := InsertRecord("test", "users", map[string]any{"name": "Eve"}); err != nil {
+				t.Fatalf("Failed to insert record: %v", err)
+			}
+		}
+		if nrec := Count(LegacyDBName, "users", map[string]any{}); nrec != 2 {
+			t.Fatalf("Expected 2 migrated records, got %d", nrec)
+		}
+		results := Get(LegacyDBName, "users", map[string]any{"name": "Bob"}, 0, 0)
+		if len(results) != 1 || fmt.Sprint(results[0]["id"]) != "1" {
+			t.Fatalf("Wrong migrated records %v", results)
+		}
+		if nrec := Count("test", "users", map[string]any{}); nrec != 1 {
+			t.Fatalf("Expected 1 record in test database, got %d", nrec)
+		}
+		db.Close()
+	}
+
+	// keys of dbname:collname:_id layout are not migrated
+	if _, ok := legacyKey("test:users:abc", map[string]any{"_id": "abc"}); ok {
+		t.Fatal("Key of current layout is reported as legacy one")
+	}
+	if coll, ok := legacyKey("users:abc", map[string]any{"_id": "abc"}); !ok || coll != "users" {
+		t.Fatalf("Legacy key is not detected, collection %q", coll)
+	}
+}
+
+func TestReopenDB(t *testing.T) {
+	defer teardownBadgerDB()
+	dir := setupBadgerDB(t)
+
+	Insert("test", "users", []map[string]any{{"name": "Alice"}})
+	// re-opening the same directory should release its lock first
+	if err := InitDB(dir); err != nil {
+		t.Fatalf("Failed to re-open BadgerDB: %v", err)
+	}
+	if n := Count("test", "users", map[string]any{}); n != 1 {
+		t.Fatalf("Expected 1 record after re-open, got %d", n)
+	}
+
+	// legacy API should use path of given URI
+	other := t.TempDir()
+	(&EmbedDB{}).InitDB("badger://" + other)
+	if db == nil || db.Opts().Dir != other {
+		t.Fatalf("Expected BadgerDB at %s", other)
+	}
+	if n := Count("test", "users", map[string]any{}); n != 0 {
+		t.Fatalf("Expected empty database, got %d records", n)
+	}
+}
@@ -0,0 +1,524 @@
+package embed
+
+// index module provides secondary indexes for BadgerDB collections. Each
+// index entry has the following key structure:
+// <index prefix><encoded field value><record key>, and its value holds the
+// record key. Encoded field values preserve MongoDB ordering of values, which
+// allows to use index entries for equality and range look-ups.
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	query "github.com/CHESSComputing/golib/embed/query"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// type brackets of encoded index values, they follow MongoDB type order
+const (
+	idxNull   byte = 0x01
+	idxNumber byte = 0x02
+	idxString byte = 0x03
+	idxObject byte = 0x04
+	idxArray  byte = 0x05
+	idxBool   byte = 0x06
+	idxTime   byte = 0x07
+)
+
+// indexes holds index definitions, i.e. indexed fields of each collection
+var indexes = make(map[string][]string)
+var indexMutex sync.RWMutex
+
+// helper function to return namespace of given database/collection
+func namespace(dbname, collname string) string {
+	return fmt.Sprintf("%s\x00%s", dbname, collname)
+}
+
+// helper function to return key of index definitions for given database/collection
+func indexMetaKey(dbname, collname string) []byte {
+	return []byte("\x00meta\x00index\x00" + namespace(dbname, collname))
+}
+
+// helper function to return key prefix of index entries for given database/collection and field
+func indexPrefix(dbname, collname, field string) []byte {
+	return []byte(fmt.Sprintf("\x00index\x00%s\x00%s\x00", namespace(dbname, collname), field))
+}
+
+// CreateIndex creates secondary index on given field of BadgerDB collection
+// and builds index entries for all existing records
+func CreateIndex(dbname, collname, field string) error {
+	indexMutex.Lock()
+	defer indexMutex.Unlock()
+	ns := namespace(dbname, collname)
+	fields := indexes[ns]
+	for _, f := range fields {
+		if f == field {
+			return nil
+		}
+	}
+	fields = append(fields, field)
+	err := db.Update(func(txn *badger.Txn) error {
+		data, err := json.Marshal(fields)
+		if err != nil {
+			return err
+		}
+		if err := txn.Set(indexMetaKey(dbname, collname), data); err != nil {
+			return err
+		}
+		// build index entries for existing records
+		prefix := dataPrefix(dbname, collname)
+		opts := badger.DefaultIteratorOptions
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			key := item.KeyCopy(nil)
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			var record map[string]any
+			if err := json.Unmarshal(val, &record); err != nil {
+				return fmt.Errorf("failed to unmarshal record: %v", err)
+			}
+			for _, ikey := range indexKeys(dbname, collname, field, record, key) {
+				if err := txn.Set(ikey, key); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("[golib.badger.CreateIndex] db.Update error: %w", err)
+	}
+	indexes[ns] = fields
+	return nil
+}
+
+// DropIndex removes secondary index on given field of BadgerDB collection
+func DropIndex(dbname, collname, field string) error {
+	indexMutex.Lock()
+	defer indexMutex.Unlock()
+	ns := namespace(dbname, collname)
+	var fields []string
+	for _, f := range indexes[ns] {
+		if f != field {
+			fields = append(fields, f)
+		}
+	}
+	err := db.Update(func(txn *badger.Txn) error {
+		data, err := json.Marshal(fields)
+		if err != nil {
+			return err
+		}
+		if err := txn.Set(indexMetaKey(dbname, collname), data); err != nil {
+			return err
+		}
+		return deletePrefix(txn, indexPrefix(dbname, collname, field))
+	})
+	if err != nil {
+		return fmt.Errorf("[golib.badger.DropIndex] db.Update error: %w", err)
+	}
+	indexes[ns] = fields
+	return nil
+}
+
+// Indexes returns list of indexed fields of BadgerDB collection
+func Indexes(dbname, collname string) []string {
+	indexMutex.RLock()
+	defer indexMutex.RUnlock()
+	return append([]string{}, indexes[namespace(dbname, collname)]...)
+}
+
+// helper function to load index definitions from BadgerDB
+func loadIndexes() error {
+	indexMutex.Lock()
+	defer indexMutex.Unlock()
+	indexes = make(map[string][]string)
+	return db.View(func(txn *badger.Txn) error {
+		prefix := []byte("\x00meta\x00index\x00")
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			ns := string(item.Key()[len(prefix):])
+			err := item.Value(func(val []byte) error {
+				var fields []string
+				if err := json.Unmarshal(val, &fields); err != nil {
+					return err
+				}
+				indexes[ns] = fields
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// helper function to delete all keys with given prefix within transaction
+func deletePrefix(txn *badger.Txn, prefix []byte) error {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	var keys [][]byte
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		keys = append(keys, it.Item().KeyCopy(nil))
+	}
+	it.Close()
+	for _, key := range keys {
+		if err := txn.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// helper function to build index keys of given record field
+func indexKeys(dbname, collname, field string, record map[string]any, key []byte) [][]byte {
+	var out [][]byte
+	nrec, _ := query.Normalize(record).(map[string]any)
+	values, _ := query.Lookup(nrec, field)
+	seen := make(map[string]bool)
+	var vals []any
+	for _, v := range values {
+		if arr, ok := v.([]any); ok {
+			// multikey index: index every element of array value
+			vals = append(vals, arr...)
+		} else {
+			vals = append(vals, v)
+		}
+	}
+	if len(vals) == 0 {
+		// like MongoDB index missing fields and empty arrays as null values,
+		// it keeps all records in the index and allows to use it for sorting
+		vals = append(vals, nil)
+	}
+	prefix := indexPrefix(dbname, collname, field)
+	for _, v := range vals {
+		enc, ok := encodeValue(v)
+		if !ok {
+			// sub-documents and nested arrays are indexed by their type bracket only
+			enc = []byte{idxObject}
+			if _, isArray := v.([]any); isArray {
+				enc = []byte{idxArray}
+			}
+		}
+		if seen[string(enc)] {
+			continue
+		}
+		seen[string(enc)] = true
+		ikey := append(append(append([]byte{}, prefix...), enc...), key...)
+		out = append(out, ikey)
+	}
+	return out
+}
+
+// helper function to update index entries of a record within transaction,
+// old record can be nil for new records and new record is nil for deletions
+func updateIndexes(txn *badger.Txn, dbname, collname string, key []byte, oldRecord, newRecord map[string]any) error {
+	indexMutex.RLock()
+	fields := indexes[namespace(dbname, collname)]
+	indexMutex.RUnlock()
+	for _, field := range fields {
+		if oldRecord != nil {
+			for _, ikey := range indexKeys(dbname, collname, field, oldRecord, key) {
+				if err := txn.Delete(ikey); err != nil {
+					return err
+				}
+			}
+		}
+		if newRecord != nil {
+			for _, ikey := range indexKeys(dbname, collname, field, newRecord, key) {
+				if err := txn.Set(ikey, key); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// encodeValue encodes scalar value into bytes preserving MongoDB order of values
+func encodeValue(val any) ([]byte, bool) {
+	switch v := query.Normalize(val).(type) {
+	case nil:
+		return []byte{idxNull}, true
+	case float64:
+		bits := math.Float64bits(v)
+		if v >= 0 {
+			bits ^= 1 << 63
+		} else {
+			bits = ^bits
+		}
+		out := make([]byte, 9)
+		out[0] = idxNumber
+		binary.BigEndian.PutUint64(out[1:], bits)
+		return out, true
+	case string:
+		// escape zero bytes and terminate string to keep encoding prefix-free
+		out := []byte{idxString}
+		out = append(out, bytes.ReplaceAll([]byte(v), []byte{0x00}, []byte{0x00, 0xff})...)
+		return append(out, 0x00, 0x00), true
+	case bool:
+		if v {
+			return []byte{idxBool, 1}, true
+		}
+		return []byte{idxBool, 0}, true
+	case time.Time:
+		out := make([]byte, 9)
+		out[0] = idxTime
+		binary.BigEndian.PutUint64(out[1:], uint64(v.UnixNano())^(1<<63))
+		return out, true
+	}
+	return nil, false
+}
+
+// indexBound represents single range of encoded index values
+type indexBound struct {
+	bracket      byte
+	lower, upper []byte
+	lowerIncl    bool
+	upperIncl    bool
+}
+
+// helper function to check if encoded value (followed by record key) is above lower bound
+func (b indexBound) aboveLower(rest []byte) bool {
+	if b.lower == nil {
+		return true
+	}
+	if bytes.HasPrefix(rest, b.lower) {
+		return b.lowerIncl
+	}
+	return bytes.Compare(rest, b.lower) > 0
+}
+
+// helper function to check if encoded value (followed by record key) is below upper bound
+func (b indexBound) belowUpper(rest []byte) bool {
+	if b.upper == nil {
+		return true
+	}
+	if bytes.HasPrefix(rest, b.upper) {
+		return b.upperIncl
+	}
+	return bytes.Compare(rest, b.upper) < 0
+}
+
+// indexPlan represents look-up of records via secondary index
+type indexPlan struct {
+	field  string
+	bounds []indexBound
+}
+
+// helper function to build index plan for given spec, it returns nil if
+// none of the indexed fields can be used for given spec
+func planIndex(dbname, collname string, spec map[string]any) *indexPlan {
+	indexMutex.RLock()
+	fields := indexes[namespace(dbname, collname)]
+	indexMutex.RUnlock()
+	var best *indexPlan
+	for _, field := range fields {
+		val, ok := spec[field]
+		if !ok {
+			continue
+		}
+		bounds, ok := fieldBounds(val)
+		if !ok {
+			continue
+		}
+		plan := &indexPlan{field: field, bounds: bounds}
+		// prefer equality look-ups over range scans
+		if best == nil || (isEquality(plan) && !isEquality(best)) {
+			best = plan
+		}
+	}
+	return best
+}
+
+// helper function to check if index plan consists of equality bounds
+func isEquality(p *indexPlan) bool {
+	for _, b := range p.bounds {
+		if b.lower == nil || !bytes.Equal(b.lower, b.upper) {
+			return false
+		}
+	}
+	return true
+}
+
+// helper function to convert field condition into index bounds
+func fieldBounds(val any) ([]indexBound, bool) {
+	doc, ok := query.Normalize(val).(map[string]any)
+	if !ok {
+		return equalityBounds(val)
+	}
+	if len(doc) == 0 {
+		return nil, false
+	}
+	var bound indexBound
+	for op, arg := range doc {
+		switch op {
+		case "$eq":
+			return equalityBounds(arg)
+		case "$in":
+			list, ok := arg.([]any)
+			if !ok || len(list) == 0 {
+				return nil, false
+			}
+			var bounds []indexBound
+			for _, v := range list {
+				b, ok := equalityBounds(v)
+				if !ok {
+					return nil, false
+				}
+				bounds = append(bounds, b...)
+			}
+			return bounds, true
+		case "$gt", "$gte", "$lt", "$lte":
+			enc, ok := encodeValue(arg)
+			if !ok || arg == nil {
+				return nil, false
+			}
+			if bound.bracket != 0 && bound.bracket != enc[0] {
+				return nil, false
+			}
+			bound.bracket = enc[0]
+			switch op {
+			case "$gt", "$gte":
+				bound.lower = enc
+				bound.lowerIncl = op == "$gte"
+			case "$lt", "$lte":
+				bound.upper = enc
+				bound.upperIncl = op == "$lte"
+			}
+		default:
+			// other operators are evaluated by query matcher over all records
+			return nil, false
+		}
+	}
+	return []indexBound{bound}, true
+}
+
+// helper function to create equality index bound for scalar value
+func equalityBounds(val any) ([]indexBound, bool) {
+	enc, ok := encodeValue(val)
+	if !ok {
+		return nil, false
+	}
+	return []indexBound{{bracket: enc[0], lower: enc, upper: enc, lowerIncl: true, upperIncl: true}}, true
+}
+
+// keys returns sorted list of unique record keys found via index look-up
+func (p *indexPlan) keys(txn *badger.Txn, dbname, collname string) ([][]byte, error) {
+	prefix := indexPrefix(dbname, collname, p.field)
+	opts := badger.DefaultIteratorOptions
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	seen := make(map[string]bool)
+	var keys [][]byte
+	for _, b := range p.bounds {
+		bracket := append(append([]byte{}, prefix...), b.bracket)
+		seek := bracket
+		if b.lower != nil {
+			seek = append(append([]byte{}, prefix...), b.lower...)
+		}
+		for it.Seek(seek); it.ValidForPrefix(bracket); it.Next() {
+			item := it.Item()
+			rest := item.Key()[len(prefix):]
+			if !b.belowUpper(rest) {
+				break
+			}
+			if !b.aboveLower(rest) {
+				continue
+			}
+			key, err := item.ValueCopy(nil)
+			if err != nil {
+				return nil, err
+			}
+			if !seen[string(key)] {
+				seen[string(key)] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	// keep the same order of records as full collection scan
+	sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+	return keys, nil
+}
+
+// helper function to check if given field of database/collection is indexed
+func indexed(dbname, collname, field string) bool {
+	indexMutex.RLock()
+	defer indexMutex.RUnlock()
+	for _, f := range indexes[namespace(dbname, collname)] {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// helper function to walk index of given field in MongoDB sort order and pass
+// unique record keys to given function, the walk stops when function returns
+// false. Records with equal field values are passed in order of their keys.
+func walkIndex(txn *badger.Txn, dbname, collname, field string, desc bool, fn func(key []byte) (bool, error)) error {
+	prefix := indexPrefix(dbname, collname, field)
+	opts := badger.DefaultIteratorOptions
+	opts.Reverse = desc
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	seen := make(map[string]bool)
+	var group [][]byte
+	var groupValue []byte
+	// helper function to pass group of keys with equal field values
+	flush := func() (bool, error) {
+		if desc {
+			for i, j := 0, len(group)-1; i < j; i, j = i+1, j-1 {
+				group[i], group[j] = group[j], group[i]
+			}
+		}
+		for _, key := range group {
+			if seen[string(key)] {
+				continue
+			}
+			seen[string(key)] = true
+			if next, err := fn(key); err != nil || !next {
+				return false, err
+			}
+		}
+		group = group[:0]
+		return true, nil
+	}
+
+	seek := prefix
+	if desc {
+		seek = append(append([]byte{}, prefix...), 0xff)
+	}
+	for it.Seek(seek); it.ValidForPrefix(prefix); it.Next() {
+		item := it.Item()
+		key, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		rest := item.Key()[len(prefix):]
+		value := rest[:len(rest)-len(key)]
+		if !bytes.Equal(value, groupValue) {
+			if next, err := flush(); err != nil || !next {
+				return err
+			}
+			groupValue = append(groupValue[:0], value...)
+		}
+		group = append(group, key)
+	}
+	_, err := flush()
+	return err
+}
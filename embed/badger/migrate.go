@@ -0,0 +1,118 @@
+package embed
+
+// migrate module migrates records stored by previous versions of the package
+// with collname:id keys into dbname:collname:_id key layout
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// LegacyDBName defines database name of records stored by previous versions
+// of the package, which ignored database names and used collname:id keys.
+// Such records are moved into this database by InitDB.
+var LegacyDBName = "chess"
+
+// keyLayoutVersion defines version of key layout of BadgerDB records
+const keyLayoutVersion = "2"
+
+// helper function to return key of key layout version
+func versionKey() []byte {
+	return []byte("\x00meta\x00version")
+}
+
+// helper function to migrate records with legacy collname:id keys into
+// dbname:collname:_id key layout, it is done once per database
+func migrateKeys() error {
+	var version string
+	err := db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(versionKey())
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		val, err := item.ValueCopy(nil)
+		version = string(val)
+		return err
+	})
+	if err != nil || version == keyLayoutVersion {
+		return err
+	}
+	wb := db.NewWriteBatch()
+	defer wb.Cancel()
+	nrec := 0
+	err = db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			key := item.KeyCopy(nil)
+			// skip meta-data and index keys
+			if len(key) == 0 || key[0] == 0 {
+				continue
+			}
+			var record map[string]any
+			err := item.Value(func(val []byte) error {
+				return json.Unmarshal(val, &record)
+			})
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal record %s: %v", key, err)
+			}
+			collname, ok := legacyKey(string(key), record)
+			if !ok {
+				continue
+			}
+			if id, ok := record["_id"]; !ok || id == nil || id == "" {
+				record["_id"] = newID()
+			}
+			val, err := json.Marshal(record)
+			if err != nil {
+				return fmt.Errorf("failed to marshal record: %v", err)
+			}
+			if err := wb.Set(dataKey(LegacyDBName, collname, record["_id"]), val); err != nil {
+				return err
+			}
+			if err := wb.Delete(key); err != nil {
+				return err
+			}
+			nrec++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := wb.Set(versionKey(), []byte(keyLayoutVersion)); err != nil {
+		return err
+	}
+	if err := wb.Flush(); err != nil {
+		return err
+	}
+	if nrec > 0 {
+		log.Printf("migrated %d BadgerDB records into %s database", nrec, LegacyDBName)
+	}
+	return nil
+}
+
+// helper function to check if key of the record has legacy collname:id
+// layout, it returns collection name of legacy key
+func legacyKey(key string, record map[string]any) (string, bool) {
+	idx := strings.Index(key, ":")
+	if idx <= 0 {
+		return "", false
+	}
+	// keys of dbname:collname:_id layout end with _id of their records
+	if id, ok := record["_id"]; ok {
+		sid := fmt.Sprintf(":%v", id)
+		if strings.HasSuffix(key, sid) && strings.Contains(key[:len(key)-len(sid)], ":") {
+			return "", false
+		}
+	}
+	return key[:idx], true
+}
@@ -0,0 +1,71 @@
+package embed
+
+// txn module provides BadgerDB transactions which span several DocDB calls,
+// the transaction is carried by context passed to these calls
+
+import (
+	"context"
+	"fmt"
+
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+// txnKey is context key of BadgerDB transaction
+type txnKey struct{}
+
+// txnState represents BadgerDB transaction along with change events which
+// are published once transaction is committed
+type txnState struct {
+	txn    *badger.Txn
+	events []map[string]any
+}
+
+// WithTransaction runs given function within BadgerDB transaction, all
+// operations which use context passed to the function are part of it. The
+// transaction is committed if function returns no error and discarded
+// otherwise, nested calls join outer transaction. Badger transactions are
+// not safe for concurrent use, i.e. the function should not use its context
+// from several goroutines.
+func WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txnKey{}).(*txnState); ok {
+		return fn(ctx)
+	}
+	state := &txnState{txn: db.NewTransaction(true)}
+	defer state.txn.Discard()
+	if err := fn(context.WithValue(ctx, txnKey{}, state)); err != nil {
+		return err
+	}
+	if err := state.txn.Commit(); err != nil {
+		return fmt.Errorf("[golib.badger.WithTransaction] txn.Commit error: %w", err)
+	}
+	changeLog.Publish(state.events...)
+	return nil
+}
+
+// helper function to run read-write function within transaction of given
+// context or within new transaction
+func withUpdate(ctx context.Context, fn func(txn *badger.Txn) error) error {
+	if state, ok := ctx.Value(txnKey{}).(*txnState); ok {
+		return fn(state.txn)
+	}
+	return db.Update(fn)
+}
+
+// helper function to run read-only function within transaction of given
+// context or within new transaction
+func withView(ctx context.Context, fn func(txn *badger.Txn) error) error {
+	if state, ok := ctx.Value(txnKey{}).(*txnState); ok {
+		return fn(state.txn)
+	}
+	return db.View(fn)
+}
+
+// helper function to publish change events of committed write, events of
+// transaction are published once it is committed
+func publish(ctx context.Context, events []map[string]any) {
+	if state, ok := ctx.Value(txnKey{}).(*txnState); ok {
+		state.events = append(state.events, events...)
+		return
+	}
+	changeLog.Publish(events...)
+}
@@ -0,0 +1,174 @@
+package changes
+
+// changes module provides in-process change log used by embedded
+// document-oriented databases to implement change streams. Change events
+// have the same structure as MongoDB change stream documents, e.g.
+//
+//	{"operationType": "insert",
+//	 "ns": {"db": "chess", "coll": "meta"},
+//	 "documentKey": {"_id": "..."},
+//	 "fullDocument": {...}}
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	query "github.com/CHESSComputing/golib/embed/query"
+)
+
+// change event operation types
+const (
+	Insert = "insert"
+	Update = "update"
+	Delete = "delete"
+)
+
+// Event creates change event for given operation and record, the record is
+// copied and should contain record _id. Like in MongoDB change streams
+// delete events carry only _id of deleted record.
+func Event(op, dbname, collname string, record map[string]any) map[string]any {
+	event := map[string]any{
+		"operationType": op,
+		"ns":            map[string]any{"db": dbname, "coll": collname},
+		"documentKey":   map[string]any{"_id": record["_id"]},
+	}
+	if op != Delete {
+		event["fullDocument"] = copyValue(record)
+	}
+	return event
+}
+
+// Log represents change log which delivers published events to watchers
+type Log struct {
+	mutex       sync.RWMutex
+	seq         int
+	subscribers map[int]*subscriber
+}
+
+// subscriber represents watcher of database/collection events, it queues
+// events to never block publishers
+type subscriber struct {
+	dbname   string
+	collname string
+	matcher  *query.Matcher
+	mutex    sync.Mutex
+	queue    []map[string]any
+	notify   chan struct{}
+}
+
+// NewLog creates new change log
+func NewLog() *Log {
+	return &Log{subscribers: make(map[int]*subscriber)}
+}
+
+// Publish delivers given change events to watchers of their collections
+func (l *Log) Publish(events ...map[string]any) {
+	if len(events) == 0 {
+		return
+	}
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	for _, sub := range l.subscribers {
+		sub.push(events)
+	}
+}
+
+// Watch returns channel of change events of given database/collection whose
+// records match given spec, the channel is closed when context is done
+func (l *Log) Watch(ctx context.Context, dbname, collname string, spec map[string]any) (<-chan map[string]any, error) {
+	matcher, err := query.Compile(spec)
+	if err != nil {
+		return nil, fmt.Errorf("[golib.embed.changes.Watch] query.Compile error: %w", err)
+	}
+	sub := &subscriber{
+		dbname:   dbname,
+		collname: collname,
+		matcher:  matcher,
+		notify:   make(chan struct{}, 1),
+	}
+	l.mutex.Lock()
+	l.seq++
+	id := l.seq
+	l.subscribers[id] = sub
+	l.mutex.Unlock()
+
+	out := make(chan map[string]any)
+	go func() {
+		defer close(out)
+		defer func() {
+			l.mutex.Lock()
+			delete(l.subscribers, id)
+			l.mutex.Unlock()
+		}()
+		for {
+			sub.mutex.Lock()
+			queue := sub.queue
+			sub.queue = nil
+			sub.mutex.Unlock()
+			for _, event := range queue {
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if len(queue) > 0 {
+				continue
+			}
+			select {
+			case <-sub.notify:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// helper function to queue events which belong to subscriber collection
+// and match its spec, delete events do not carry records and, like in
+// MongoDB change streams, are queued regardless of the spec
+func (s *subscriber) push(events []map[string]any) {
+	var queued bool
+	s.mutex.Lock()
+	for _, event := range events {
+		ns, _ := event["ns"].(map[string]any)
+		if ns["db"] != s.dbname || ns["coll"] != s.collname {
+			continue
+		}
+		if event["operationType"] != Delete {
+			if doc, ok := event["fullDocument"].(map[string]any); !ok || !s.matcher.Match(doc) {
+				continue
+			}
+		}
+		s.queue = append(s.queue, event)
+		queued = true
+	}
+	s.mutex.Unlock()
+	if queued {
+		select {
+		case s.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// helper function to make deep copy of documents and arrays
+func copyValue(val any) any {
+	switch v := val.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[k] = copyValue(e)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = copyValue(e)
+		}
+		return out
+	}
+	return val
+}
@@ -0,0 +1,50 @@
+package changes
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	log := NewLog()
+	events, err := log.Watch(ctx, "test", "meta", map[string]any{"energy": map[string]any{"$gt": 1}})
+	if err != nil {
+		t.Fatalf("Watch error: %v", err)
+	}
+	// publishers are not blocked by watchers which do not read events
+	rec := map[string]any{"_id": "1", "energy": 2}
+	for i := 0; i < 100; i++ {
+		log.Publish(Event(Update, "test", "meta", rec))
+	}
+	log.Publish(
+		Event(Insert, "test", "other", rec),
+		Event(Insert, "test", "meta", map[string]any{"_id": "2", "energy": 1}),
+		Event(Delete, "test", "meta", map[string]any{"_id": "2", "energy": 1}),
+	)
+	rec["energy"] = 3
+	for i := 0; i < 101; i++ {
+		select {
+		case event := <-events:
+			// delete events carry only _id and do not depend on the spec
+			if i == 100 {
+				key := event["documentKey"].(map[string]any)
+				if _, ok := event["fullDocument"]; ok || event["operationType"] != Delete || key["_id"] != "2" {
+					t.Fatalf("wrong event %d: %v", i, event)
+				}
+				continue
+			}
+			doc := event["fullDocument"].(map[string]any)
+			if event["operationType"] != Update || doc["energy"] != 2 {
+				t.Fatalf("wrong event %d: %v", i, event)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for event %d", i)
+		}
+	}
+	cancel()
+	if _, ok := <-events; ok {
+		t.Fatal("expected closed channel")
+	}
+}
@@ -0,0 +1,149 @@
+package embed
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+)
+
+// EmbedDB represent embedded database
+type EmbedDB struct {
+}
+
+// InitDB initialize embedded database with path of given URI, see
+// EmbedDBV2.InitDB
+func (d *EmbedDB) InitDB(uri string) {
+	if err := (&EmbedDBV2{}).InitDB(context.Background(), uri); err != nil {
+		log.Println("ERROR:", err)
+	}
+}
+
+// Insert inserts records into provided database/collection
+func (d *EmbedDB) Insert(dbname, collname string, records []map[string]any) {
+	Insert(dbname, collname, records)
+}
+
+// Upsert inserts records into provided database/collection and attribute
+func (d *EmbedDB) Upsert(dbname, collname, attr string, records []map[string]any) error {
+	return Upsert(dbname, collname, attr, records)
+}
+
+// Get fetches data from underlying database/collection
+func (d *EmbedDB) Get(dbname, collname string, spec map[string]any, idx, limit int) []map[string]any {
+	return Get(dbname, collname, spec, idx, limit)
+}
+
+// GetProjection fetches data from underlying database/collection
+func (d *EmbedDB) GetProjection(dbname, collname string, spec map[string]any, projection map[string]int, idx, limit int) []map[string]any {
+	return GetProjection(dbname, collname, spec, projection, idx, limit)
+}
+
+// Update updates data into given database/collection
+func (d *EmbedDB) Update(dbname, collname string, spec, newdata map[string]any) error {
+	return Update(dbname, collname, spec, newdata)
+}
+
+// Count returns total number of records within database/collection and given spec
+func (d *EmbedDB) Count(dbname, collname string, spec map[string]any) int {
+	return Count(dbname, collname, spec)
+}
+
+// Remove deletes records in given database/collection using given spec
+func (d *EmbedDB) Remove(dbname, collname string, spec map[string]any) error {
+	return Remove(dbname, collname, spec)
+}
+
+// Distinct returns distinct collection of records
+func (d *EmbedDB) Distinct(dbname, collname, field string) ([]any, error) {
+	return Distinct(dbname, collname, field)
+}
+
+// InsertRecord inserts single record into given database/collection
+func (d *EmbedDB) InsertRecord(dbname, collname string, rec map[string]any) error {
+	return InsertRecord(dbname, collname, rec)
+}
+
+// GetSorted returns sorted records from given database/collection using provided spec, sorted keys, order and limits
+func (d *EmbedDB) GetSorted(dbname, collname string, spec map[string]any, skeys []string, sortOrder, idx, limit int) []map[string]any {
+	return GetSorted(dbname, collname, spec, skeys, sortOrder, idx, limit)
+}
+
+// EmbedDBV2 represent context-aware embedded database
+type EmbedDBV2 struct {
+}
+
+// InitDB initialize embedded database with path of given URI, e.g.
+// badger:///data/docdb or /data/docdb, empty URI means embedded DocDB of
+// server configuration
+func (d *EmbedDBV2) InitDB(ctx context.Context, uri string) error {
+	if uri == "" {
+		uri = srvConfig.Config.Embed.DocDb
+	}
+	if _, path, ok := strings.Cut(uri, "://"); ok {
+		uri = path
+	}
+	return InitDB(uri)
+}
+
+// Insert inserts records into provided database/collection
+func (d *EmbedDBV2) Insert(ctx context.Context, dbname, collname string, records []map[string]any) error {
+	return InsertContext(ctx, dbname, collname, records)
+}
+
+// Upsert inserts records into provided database/collection and attribute
+func (d *EmbedDBV2) Upsert(ctx context.Context, dbname, collname, attr string, records []map[string]any) error {
+	return UpsertContext(ctx, dbname, collname, attr, records)
+}
+
+// Get fetches data from underlying database/collection
+func (d *EmbedDBV2) Get(ctx context.Context, dbname, collname string, spec map[string]any, idx, limit int) ([]map[string]any, error) {
+	return GetContext(ctx, dbname, collname, spec, idx, limit)
+}
+
+// GetProjection fetches data from underlying database/collection
+func (d *EmbedDBV2) GetProjection(ctx context.Context, dbname, collname string, spec map[string]any, projection map[string]int, idx, limit int) ([]map[string]any, error) {
+	return GetProjectionContext(ctx, dbname, collname, spec, projection, idx, limit)
+}
+
+// Update updates data into given database/collection
+func (d *EmbedDBV2) Update(ctx context.Context, dbname, collname string, spec, newdata map[string]any) error {
+	return UpdateContext(ctx, dbname, collname, spec, newdata)
+}
+
+// UpdateCount updates data into given database/collection and returns number
+// of matched and modified records
+func (d *EmbedDBV2) UpdateCount(ctx context.Context, dbname, collname string, spec, newdata map[string]any) (int, int, error) {
+	return UpdateCountContext(ctx, dbname, collname, spec, newdata)
+}
+
+// Count returns total number of records within database/collection and given spec
+func (d *EmbedDBV2) Count(ctx context.Context, dbname, collname string, spec map[string]any) (int, error) {
+	return CountContext(ctx, dbname, collname, spec)
+}
+
+// Remove deletes records in given database/collection using given spec
+func (d *EmbedDBV2) Remove(ctx context.Context, dbname, collname string, spec map[string]any) error {
+	return RemoveContext(ctx, dbname, collname, spec)
+}
+
+// Distinct returns distinct collection of records
+func (d *EmbedDBV2) Distinct(ctx context.Context, dbname, collname, field string) ([]any, error) {
+	return DistinctContext(ctx, dbname, collname, field)
+}
+
+// InsertRecord inserts single record into given database/collection
+func (d *EmbedDBV2) InsertRecord(ctx context.Context, dbname, collname string, rec map[string]any) error {
+	return InsertRecordContext(ctx, dbname, collname, rec)
+}
+
+// GetSorted returns sorted records from given database/collection using provided spec, sorted keys, order and limits
+func (d *EmbedDBV2) GetSorted(ctx context.Context, dbname, collname string, spec map[string]any, skeys []string, sortOrder, idx, limit int) ([]map[string]any, error) {
+	return GetSortedContext(ctx, dbname, collname, spec, skeys, sortOrder, idx, limit)
+}
+
+// Watch returns channel of change events of given database/collection
+func (d *EmbedDBV2) Watch(ctx context.Context, dbname, collname string, spec map[string]any) (<-chan map[string]any, error) {
+	return Watch(ctx, dbname, collname, spec)
+}
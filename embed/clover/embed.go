@@ -1,10 +1,14 @@
 package embed
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 
+	changes "github.com/CHESSComputing/golib/embed/changes"
+	embedQ "github.com/CHESSComputing/golib/embed/query"
+	"github.com/google/uuid"
 	clover "github.com/ostafen/clover/v2"
 	cloverD "github.com/ostafen/clover/v2/document"
 	cloverQ "github.com/ostafen/clover/v2/query"
@@ -12,8 +16,14 @@ import (
 
 var db *clover.DB
 
+// changeLog delivers change events of Clover records to watchers
+var changeLog = changes.NewLog()
+
+// ErrDuplicateKey is returned when inserted record has _id of existing record
+var ErrDuplicateKey = clover.ErrDuplicateKey
+
 // InitDB initializes document-oriented db connection object
-func InitDB(dbDir string) {
+func InitDB(dbDir string) error {
 	var err error
 
 	// check if dbDir exist
@@ -21,170 +31,430 @@ func InitDB(dbDir string) {
 	if os.IsNotExist(err) {
 		err := os.MkdirAll(dbDir, os.ModePerm) // Create all parent directories if necessary
 		if err != nil {
-			log.Fatal(err)
+			return fmt.Errorf("failed to create directory: %v", err)
+		}
+	}
+
+	// close previously opened database to release its files
+	if db != nil {
+		if err := db.Close(); err != nil {
+			return fmt.Errorf("failed to close Clover database: %v", err)
 		}
+		db = nil
 	}
 
 	// Initialize Clover database
 	db, err = clover.Open(dbDir)
 	if err != nil {
-		log.Fatalf("Failed to open Clover database: %v", err)
+		return fmt.Errorf("failed to open Clover database: %v", err)
 	}
 
 	log.Printf("Clover database initialized at %s", dbDir)
+	return nil
+}
+
+// helper function to return clover collection name of given
+// database/collection, the collection is created if it does not exist
+func collection(dbname, collname string) (string, error) {
+	name := fmt.Sprintf("%s.%s", dbname, collname)
+	if err := db.CreateCollection(name); err != nil && err != clover.ErrCollectionExist {
+		return name, err
+	}
+	return name, nil
+}
+
+// helper function to generate new record id, clover requires UUID ids and
+// we use time ordered UUIDs to keep documents in order of their insertion
+func newID() string {
+	if id, err := uuid.NewV7(); err == nil {
+		return id.String()
+	}
+	return clover.NewObjectId()
+}
+
+// helper function to return copy of records without _id attribute, as
+// MongoDB backend does not return it either
+func stripID(records []map[string]any) []map[string]any {
+	var out []map[string]any
+	for _, rec := range records {
+		nrec := make(map[string]any, len(rec))
+		for k, v := range rec {
+			if k != "_id" {
+				nrec[k] = v
+			}
+		}
+		out = append(out, nrec)
+	}
+	return out
+}
+
+// helper function to pass documents matching given spec to given function,
+// documents are iterated in order of their ids and iteration stops if
+// function returns false
+func scan(ctx context.Context, name string, spec map[string]any, fn func(rec map[string]any) bool) error {
+	matcher, err := embedQ.Compile(spec)
+	if err != nil {
+		return fmt.Errorf("query.Compile error: %w", err)
+	}
+	var ctxErr error
+	err = db.ForEach(cloverQ.NewQuery(name), func(doc *cloverD.Document) bool {
+		if ctxErr = ctx.Err(); ctxErr != nil {
+			return false
+		}
+		rec := doc.AsMap()
+		if matcher.Match(rec) {
+			return fn(rec)
+		}
+		return true
+	})
+	if ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// helper function to insert new record, it assigns generated _id to records
+// without it, clover requires UUID string ids. It returns inserted record.
+func insertRecord(name string, record map[string]any) (map[string]any, error) {
+	nrec := make(map[string]any, len(record)+1)
+	for k, v := range record {
+		nrec[k] = v
+	}
+	if id, ok := nrec["_id"]; !ok || id == nil || id == "" {
+		nrec["_id"] = newID()
+	}
+	_, err := db.InsertOne(name, cloverD.NewDocumentOf(nrec))
+	return nrec, err
 }
 
 // Insert records into document-oriented db
 func Insert(dbname, collname string, records []map[string]any) {
-	Upsert(dbname, collname, "", records)
+	if err := InsertContext(context.TODO(), dbname, collname, records); err != nil {
+		log.Println("ERROR:", err)
+	}
+}
+
+// InsertContext inserts records into document-oriented db
+func InsertContext(ctx context.Context, dbname, collname string, records []map[string]any) error {
+	if err := upsertContext(ctx, dbname, collname, "", records); err != nil {
+		return fmt.Errorf("[golib.embed.Insert] upsert error: %w", err)
+	}
+	return nil
 }
 
 // Upsert records into document-oriented db
 func Upsert(dbname, collname, attr string, records []map[string]any) error {
-	if err := db.CreateCollection(collname); err != nil && err != clover.ErrCollectionExist {
-		log.Fatalf("Failed to create collection: %v", err)
+	return UpsertContext(context.TODO(), dbname, collname, attr, records)
+}
+
+// UpsertContext upserts records into document-oriented db. It follows
+// MongoDB semantics: existing record is looked up by given attribute value
+// and new values are merged into it, otherwise new record with generated _id
+// is inserted. Records without attribute value are skipped.
+func UpsertContext(ctx context.Context, dbname, collname, attr string, records []map[string]any) error {
+	if err := upsertContext(ctx, dbname, collname, attr, records); err != nil {
+		return fmt.Errorf("[golib.embed.Upsert] upsert error: %w", err)
+	}
+	return nil
+}
+
+// helper function to upsert records using given attribute, empty attribute
+// means plain insert of records
+func upsertContext(ctx context.Context, dbname, collname, attr string, records []map[string]any) error {
+	name, err := collection(dbname, collname)
+	if err != nil {
+		return fmt.Errorf("failed to create collection: %v", err)
 	}
 	for _, record := range records {
-		// Search for an existing record with the same attribute value
-		query := cloverQ.NewQuery(collname).Where(cloverQ.Field(attr).Eq(record[attr]))
-		existingDocs, err := db.FindAll(query)
-		if err != nil {
-			return fmt.Errorf("[golib.embed.Upsert] db.FindAll error: %w", err)
+		if err := ctx.Err(); err != nil {
+			return err
 		}
-
-		if len(existingDocs) > 0 {
-			// Update the existing record
-			doc := existingDocs[0]
-			for k, v := range record {
-				doc.Set(k, v)
+		if attr == "" {
+			rec, err := insertRecord(name, record)
+			if err != nil {
+				return fmt.Errorf("failed to insert record: %w", err)
 			}
-			updater := func(_ *cloverD.Document) *cloverD.Document {
-				return doc
-			}
-			if err := db.UpdateById(collname, doc.ObjectId(), updater); err != nil {
-				return fmt.Errorf("[golib.embed.Upsert] db.UpdateById error: %w", err)
+			changeLog.Publish(changes.Event(changes.Insert, dbname, collname, rec))
+			continue
+		}
+		value, ok := record[attr]
+		if !ok || value == nil || value == "" {
+			continue
+		}
+		// update first matched record as MongoDB UpdateOne does
+		var docID string
+		err := scan(ctx, name, map[string]any{attr: value}, func(rec map[string]any) bool {
+			docID, _ = rec["_id"].(string)
+			return false
+		})
+		if err != nil {
+			return fmt.Errorf("failed to find record: %v", err)
+		}
+		if docID == "" {
+			rec, err := insertRecord(name, record)
+			if err != nil {
+				return fmt.Errorf("failed to insert record: %w", err)
 			}
-		} else {
-			// Insert a new record
-			doc := cloverD.NewDocumentOf(record)
-			if _, err := db.InsertOne(collname, doc); err != nil {
-				return fmt.Errorf("[golib.embed.Upsert] db.InsertOne error: %w", err)
+			changeLog.Publish(changes.Event(changes.Insert, dbname, collname, rec))
+			continue
+		}
+		var updated map[string]any
+		updater := func(doc *cloverD.Document) *cloverD.Document {
+			for k, v := range record {
+				if k != "_id" {
+					doc.Set(k, v)
+				}
 			}
+			updated = doc.AsMap()
+			return doc
+		}
+		if err := db.UpdateById(name, docID, updater); err != nil {
+			return fmt.Errorf("failed to upsert record: %v", err)
 		}
+		changeLog.Publish(changes.Event(changes.Update, dbname, collname, updated))
 	}
 	return nil
 }
 
 // Get records from document-oriented db
 func Get(dbname, collname string, spec map[string]any, idx, limit int) []map[string]any {
-	var results []map[string]any
-	if err := db.CreateCollection(collname); err != nil && err != clover.ErrCollectionExist {
-		log.Fatalf("Failed to create collection: %v", err)
+	results, err := GetContext(context.TODO(), dbname, collname, spec, idx, limit)
+	if err != nil {
+		log.Printf("Failed to query documents: %v", err)
 	}
+	return results
+}
 
-	// Build query based on the spec
-	query := cloverQ.NewQuery(collname)
-	for k, v := range spec {
-		query = query.Where(cloverQ.Field(k).Eq(v))
+// GetContext fetches records from document-oriented db for given spec and pagination
+func GetContext(ctx context.Context, dbname, collname string, spec map[string]any, idx, limit int) ([]map[string]any, error) {
+	results, err := getSorted(ctx, dbname, collname, spec, nil, 1, idx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("[golib.embed.Get] scan error: %w", err)
 	}
+	return stripID(results), nil
+}
 
-	// Set offset and limit
-	if idx > 0 {
-		query = query.Skip(idx)
-	}
-	if limit > 0 {
-		query = query.Limit(limit)
+// GetProjection records from document-oriented db
+func GetProjection(dbname, collname string, spec map[string]any, projection map[string]int, idx, limit int) []map[string]any {
+	out, err := GetProjectionContext(context.TODO(), dbname, collname, spec, projection, idx, limit)
+	if err != nil {
+		log.Printf("Failed to project documents: %v", err)
 	}
+	return out
+}
 
-	// Execute the query
-	docs, err := db.FindAll(query)
+// GetProjectionContext fetches records with given projection from document-oriented db
+func GetProjectionContext(ctx context.Context, dbname, collname string, spec map[string]any, projection map[string]int, idx, limit int) ([]map[string]any, error) {
+	results, err := getSorted(ctx, dbname, collname, spec, nil, 1, idx, limit)
 	if err != nil {
-		log.Printf("Failed to query documents: %v", err)
-		return results
+		return nil, fmt.Errorf("[golib.embed.GetProjection] scan error: %w", err)
 	}
-
-	// Convert documents to map[string]any
-	for _, doc := range docs {
-		results = append(results, doc.AsMap())
+	// like other APIs do not return _id unless it is explicitly requested
+	proj := map[string]int{"_id": 0}
+	for k, v := range projection {
+		proj[k] = v
 	}
-	return results
+	out, err := embedQ.Project(results, proj)
+	if err != nil {
+		return nil, fmt.Errorf("[golib.embed.GetProjection] projection error: %w", err)
+	}
+	return out, nil
 }
 
 // Update inplace for given spec
 func Update(dbname, collname string, spec, newdata map[string]any) error {
-	if err := db.CreateCollection(collname); err != nil && err != clover.ErrCollectionExist {
-		log.Fatalf("Failed to create collection: %v", err)
-	}
-	// Build query based on the spec
-	query := cloverQ.NewQuery(collname)
-	for k, v := range spec {
-		query = query.Where(cloverQ.Field(k).Eq(v))
+	err := UpdateContext(context.TODO(), dbname, collname, spec, newdata)
+	if err != nil {
+		log.Printf("Failed to update document: %v", err)
 	}
+	return err
+}
 
-	// update document
-	err := db.Update(query, newdata)
+// UpdateContext updates first record matching given spec in document-oriented db
+func UpdateContext(ctx context.Context, dbname, collname string, spec, newdata map[string]any) error {
+	_, _, err := UpdateCountContext(ctx, dbname, collname, spec, newdata)
+	return err
+}
+
+// UpdateCountContext updates first record matching given spec in
+// document-oriented db, as MongoDB UpdateOne does, and returns number of
+// matched and modified records
+func UpdateCountContext(ctx context.Context, dbname, collname string, spec, newdata map[string]any) (int, int, error) {
+	name, err := collection(dbname, collname)
 	if err != nil {
-		log.Printf("Failed to update document: %v", err)
-		return fmt.Errorf("[golib.embed.Update] db.Update error: %w", err)
+		return 0, 0, fmt.Errorf("[golib.embed.Update] collection error: %w", err)
 	}
-	return nil
+	var doc map[string]any
+	err = scan(ctx, name, spec, func(rec map[string]any) bool {
+		doc = rec
+		return false
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("[golib.embed.Update] scan error: %w", err)
+	}
+	id, ok := doc["_id"].(string)
+	if !ok {
+		return 0, 0, nil
+	}
+	updated, err := embedQ.ApplyUpdate(doc, newdata)
+	if err != nil {
+		return 0, 0, fmt.Errorf("[golib.embed.Update] update error: %w", err)
+	}
+	if embedQ.Equal(doc, updated) {
+		return 1, 0, nil
+	}
+	updater := func(*cloverD.Document) *cloverD.Document {
+		return cloverD.NewDocumentOf(updated)
+	}
+	if err := db.UpdateById(name, id, updater); err != nil {
+		return 0, 0, fmt.Errorf("[golib.embed.Update] db.UpdateById error: %w", err)
+	}
+	changeLog.Publish(changes.Event(changes.Update, dbname, collname, updated))
+	return 1, 1, nil
 }
 
 // Count gets number of records from document-oriented db
 func Count(dbname, collname string, spec map[string]any) int {
-	if err := db.CreateCollection(collname); err != nil && err != clover.ErrCollectionExist {
-		log.Fatalf("Failed to create collection: %v", err)
-	}
-	// Build query based on the spec
-	query := cloverQ.NewQuery(collname)
-	for k, v := range spec {
-		query = query.Where(cloverQ.Field(k).Eq(v))
+	nrec, err := CountContext(context.TODO(), dbname, collname, spec)
+	if err != nil {
+		log.Printf("Failed to count documents: %v", err)
 	}
+	return nrec
+}
 
-	// Count documents
-	docs, err := db.FindAll(query)
+// CountContext counts records matching given spec in document-oriented db
+func CountContext(ctx context.Context, dbname, collname string, spec map[string]any) (int, error) {
+	name, err := collection(dbname, collname)
 	if err != nil {
-		log.Printf("Failed to count documents: %v", err)
-		return 0
+		return 0, fmt.Errorf("[golib.embed.Count] collection error: %w", err)
+	}
+	nrec := 0
+	err = scan(ctx, name, spec, func(rec map[string]any) bool {
+		nrec++
+		return true
+	})
+	if err != nil {
+		return 0, fmt.Errorf("[golib.embed.Count] scan error: %w", err)
 	}
-	return len(docs)
+	return nrec, nil
 }
 
 // Remove records from document-oriented db
 func Remove(dbname, collname string, spec map[string]any) error {
-	if err := db.CreateCollection(collname); err != nil && err != clover.ErrCollectionExist {
-		log.Fatalf("Failed to create collection: %v", err)
-	}
-	// Build query based on the spec
-	query := cloverQ.NewQuery(collname)
-	for k, v := range spec {
-		query = query.Where(cloverQ.Field(k).Eq(v))
+	err := RemoveContext(context.TODO(), dbname, collname, spec)
+	if err != nil {
+		log.Printf("Failed to remove documents: %v", err)
 	}
+	return err
+}
 
-	// Execute the query and delete documents
-	err := db.Delete(query)
+// RemoveContext removes records matching given spec from document-oriented db
+func RemoveContext(ctx context.Context, dbname, collname string, spec map[string]any) error {
+	name, err := collection(dbname, collname)
 	if err != nil {
-		log.Printf("Failed to remove documents: %v", err)
-		return fmt.Errorf("[golib.embed.Remove] db.Delete error: %w", err)
+		return fmt.Errorf("[golib.embed.Remove] collection error: %w", err)
+	}
+	var records []map[string]any
+	err = scan(ctx, name, spec, func(rec map[string]any) bool {
+		records = append(records, rec)
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("[golib.embed.Remove] scan error: %w", err)
+	}
+	for _, rec := range records {
+		id, _ := rec["_id"].(string)
+		if err := db.DeleteById(name, id); err != nil {
+			return fmt.Errorf("[golib.embed.Remove] db.DeleteById error: %w", err)
+		}
+		changeLog.Publish(changes.Event(changes.Delete, dbname, collname, rec))
 	}
 	return nil
 }
 
+// Watch returns channel of change events of Clover records which belong to
+// given database/collection and match given spec, the channel is closed when
+// context is done
+func Watch(ctx context.Context, dbname, collname string, spec map[string]any) (<-chan map[string]any, error) {
+	return changeLog.Watch(ctx, dbname, collname, spec)
+}
+
 // Distinct gets number records from document-oriented db
 func Distinct(dbname, collname, field string) ([]any, error) {
+	return DistinctContext(context.TODO(), dbname, collname, field)
+}
+
+// DistinctContext returns unique values of given field in document-oriented db collection
+func DistinctContext(ctx context.Context, dbname, collname, field string) ([]any, error) {
 	var out []any
-	// Not implemented yet
+	name, err := collection(dbname, collname)
+	if err != nil {
+		return out, fmt.Errorf("[golib.embed.Distinct] collection error: %w", err)
+	}
+	// loop over records and collect unique values of the field
+	seen := make(map[string]bool)
+	err = scan(ctx, name, map[string]any{}, func(rec map[string]any) bool {
+		if val, ok := rec[field]; ok {
+			key := fmt.Sprintf("%v", val)
+			if !seen[key] {
+				seen[key] = true
+				out = append(out, val)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return out, fmt.Errorf("[golib.embed.Distinct] scan error: %w", err)
+	}
 	return out, nil
 }
 
 // InsertRecord insert record with given spec to document-oriented db
 func InsertRecord(dbname, collname string, rec map[string]any) error {
+	return InsertRecordContext(context.TODO(), dbname, collname, rec)
+}
+
+// InsertRecordContext inserts single record into document-oriented db
+func InsertRecordContext(ctx context.Context, dbname, collname string, rec map[string]any) error {
 	var records []map[string]any
 	records = append(records, rec)
-	return Upsert(dbname, collname, "", records)
+	return upsertContext(ctx, dbname, collname, "", records)
 }
 
-// GetSorted fetches records from document-oriented db sorted by given key with specific order
+// GetSorted fetches records from document-oriented db sorted by given key with specific order,
+// it keeps at most idx+limit records in memory
 func GetSorted(dbname, collname string, spec map[string]any, skeys []string, sortOrder, idx, limit int) []map[string]any {
-	return Get(dbname, collname, spec, idx, limit)
+	out, err := GetSortedContext(context.TODO(), dbname, collname, spec, skeys, sortOrder, idx, limit)
+	if err != nil {
+		log.Printf("Failed to query documents: %v", err)
+	}
+	return out
+}
+
+// GetSortedContext fetches records from document-oriented db sorted by given
+// keys with specific order, it keeps at most idx+limit records in memory
+func GetSortedContext(ctx context.Context, dbname, collname string, spec map[string]any, skeys []string, sortOrder, idx, limit int) ([]map[string]any, error) {
+	results, err := getSorted(ctx, dbname, collname, spec, skeys, sortOrder, idx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("[golib.embed.GetSorted] scan error: %w", err)
+	}
+	return stripID(results), nil
+}
+
+// helper function to fetch records within [idx, idx+limit) window sorted by
+// given keys, documents are streamed through top-K sorter
+func getSorted(ctx context.Context, dbname, collname string, spec map[string]any, skeys []string, sortOrder, idx, limit int) ([]map[string]any, error) {
+	name, err := collection(dbname, collname)
+	if err != nil {
+		return nil, err
+	}
+	topk := embedQ.NewTopK(skeys, sortOrder, idx, limit)
+	err = scan(ctx, name, spec, func(rec map[string]any) bool {
+		topk.Push(rec)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return topk.Records(), nil
 }
@@ -154,3 +154,22 @@ func TestRemove(t *testing.T) {
 		t.Fatalf("Remove failed to delete all records: %+v", results)
 	}
 }
+
+func TestGetSorted(t *testing.T) {
+	InitDB(testDBPath)
+	defer os.RemoveAll(testDBPath)
+
+	collname := "runs"
+	records := []map[string]any{
+		{"name": "Alice", "age": 30},
+		{"name": "Bob", "age": 25},
+		{"name": "Charlie", "age": 40},
+		{"name": "Daisy", "age": 20},
+	}
+	_ = Upsert(testDBPath, collname, "name", records)
+
+	results := GetSorted(testDBPath, collname, map[string]any{}, []string{"age"}, -1, 1, 2)
+	if len(results) != 2 || results[0]["name"] != "Alice" || results[1]["name"] != "Bob" {
+		t.Fatalf("GetSorted failed to retrieve sorted records: %+v", results)
+	}
+}
@@ -0,0 +1,105 @@
+package embed
+
+import (
+	"context"
+	"log"
+)
+
+// MemoryDB represent in-memory database with legacy DocDB interface
+type MemoryDB struct {
+	db *MemoryDBV2
+}
+
+// NewMemoryDB creates new empty in-memory database
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{db: NewMemoryDBV2()}
+}
+
+// Snapshot returns deep copy of database content
+func (d *MemoryDB) Snapshot() Snapshot {
+	return d.db.Snapshot()
+}
+
+// Restore replaces database content with deep copy of given snapshot
+func (d *MemoryDB) Restore(snapshot Snapshot) {
+	d.db.Restore(snapshot)
+}
+
+// InitDB initialize in-memory database
+func (d *MemoryDB) InitDB(uri string) {
+	if err := d.db.InitDB(context.Background(), uri); err != nil {
+		log.Println("ERROR:", err)
+	}
+}
+
+// Insert inserts records into provided database/collection
+func (d *MemoryDB) Insert(dbname, collname string, records []map[string]any) {
+	if err := d.db.Insert(context.Background(), dbname, collname, records); err != nil {
+		log.Println("ERROR:", err)
+	}
+}
+
+// Upsert inserts records into provided database/collection and attribute
+func (d *MemoryDB) Upsert(dbname, collname, attr string, records []map[string]any) error {
+	return d.db.Upsert(context.Background(), dbname, collname, attr, records)
+}
+
+// Get fetches data from underlying database/collection
+func (d *MemoryDB) Get(dbname, collname string, spec map[string]any, idx, limit int) []map[string]any {
+	records, err := d.db.Get(context.Background(), dbname, collname, spec, idx, limit)
+	if err != nil {
+		log.Println("ERROR:", err)
+	}
+	return records
+}
+
+// GetProjection fetches data from underlying database/collection
+func (d *MemoryDB) GetProjection(dbname, collname string, spec map[string]any, projection map[string]int, idx, limit int) []map[string]any {
+	records, err := d.db.GetProjection(context.Background(), dbname, collname, spec, projection, idx, limit)
+	if err != nil {
+		log.Println("ERROR:", err)
+	}
+	return records
+}
+
+// Update updates data into given database/collection
+func (d *MemoryDB) Update(dbname, collname string, spec, newdata map[string]any) error {
+	err := d.db.Update(context.Background(), dbname, collname, spec, newdata)
+	if err != nil {
+		log.Println("ERROR:", err)
+	}
+	return err
+}
+
+// Count returns total number of records within database/collection and given spec
+func (d *MemoryDB) Count(dbname, collname string, spec map[string]any) int {
+	nrec, err := d.db.Count(context.Background(), dbname, collname, spec)
+	if err != nil {
+		log.Println("ERROR:", err)
+	}
+	return nrec
+}
+
+// Remove deletes records in given database/collection using given spec
+func (d *MemoryDB) Remove(dbname, collname string, spec map[string]any) error {
+	return d.db.Remove(context.Background(), dbname, collname, spec)
+}
+
+// Distinct returns distinct collection of records
+func (d *MemoryDB) Distinct(dbname, collname, field string) ([]any, error) {
+	return d.db.Distinct(context.Background(), dbname, collname, field)
+}
+
+// InsertRecord inserts given record into database/collection
+func (d *MemoryDB) InsertRecord(dbname, collname string, rec map[string]any) error {
+	return d.db.InsertRecord(context.Background(), dbname, collname, rec)
+}
+
+// GetSorted returns sorted records from database/collection
+func (d *MemoryDB) GetSorted(dbname, collname string, spec map[string]any, skeys []string, sortOrder, idx, limit int) []map[string]any {
+	records, err := d.db.GetSorted(context.Background(), dbname, collname, spec, skeys, sortOrder, idx, limit)
+	if err != nil {
+		log.Println("ERROR:", err)
+	}
+	return records
+}
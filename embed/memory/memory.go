@@ -0,0 +1,478 @@
+package embed
+
+// memory module provides document-oriented database which keeps all records
+// in memory. It has the same query, sort and projection semantics as other
+// embedded backends and is intended for fast, hermetic tests.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	changes "github.com/CHESSComputing/golib/embed/changes"
+	query "github.com/CHESSComputing/golib/embed/query"
+	bson "go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ErrDuplicateKey is returned when inserted record has _id of existing record
+var ErrDuplicateKey = errors.New("duplicate key")
+
+// ErrConflict is returned when transaction can't be committed since database
+// was modified after its start
+var ErrConflict = errors.New("transaction conflict")
+
+// Snapshot represents content of in-memory database, i.e. records of every
+// database and collection in order of their insertion
+type Snapshot map[string]map[string][]map[string]any
+
+// MemoryDBV2 represents context-aware in-memory document-oriented database,
+// unlike other embedded backends every instance holds its own data
+type MemoryDBV2 struct {
+	mutex     sync.RWMutex
+	data      Snapshot
+	version   int
+	changeLog *changes.Log
+	pending   []map[string]any // change events of transaction
+}
+
+// txKey is context key of in-memory database transaction
+type txKey struct {
+	db *MemoryDBV2
+}
+
+// NewMemoryDBV2 creates new empty in-memory database
+func NewMemoryDBV2() *MemoryDBV2 {
+	return &MemoryDBV2{data: make(Snapshot), changeLog: changes.NewLog()}
+}
+
+// InitDB initializes in-memory database, it removes all existing records
+// and ignores given uri
+func (m *MemoryDBV2) InitDB(ctx context.Context, uri string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.data = make(Snapshot)
+	m.version++
+	return nil
+}
+
+// Snapshot returns deep copy of database content
+func (m *MemoryDBV2) Snapshot() Snapshot {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return copySnapshot(m.data)
+}
+
+// Restore replaces database content with deep copy of given snapshot
+func (m *MemoryDBV2) Restore(snapshot Snapshot) {
+	data := copySnapshot(snapshot)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.data = data
+	m.version++
+}
+
+// WithTransaction runs given function within transaction, all operations
+// which use context passed to the function work with private copy of the
+// database. The copy replaces database content if function returns no error
+// and database was not modified in the meantime, otherwise ErrConflict is
+// returned. Nested calls join outer transaction.
+func (m *MemoryDBV2) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{m}).(*MemoryDBV2); ok {
+		return fn(ctx)
+	}
+	m.mutex.RLock()
+	tx := &MemoryDBV2{data: copySnapshot(m.data)}
+	version := m.version
+	m.mutex.RUnlock()
+	if err := fn(context.WithValue(ctx, txKey{m}, tx)); err != nil {
+		return err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.version != version {
+		return fmt.Errorf("[golib.memory.WithTransaction] error: %w", ErrConflict)
+	}
+	m.data = tx.data
+	m.version++
+	m.changeLog.Publish(tx.pending...)
+	return nil
+}
+
+// Watch returns channel of change events of records which belong to given
+// database/collection and match given spec, the channel is closed when
+// context is done. Events of transaction are delivered once it is committed.
+func (m *MemoryDBV2) Watch(ctx context.Context, dbname, collname string, spec map[string]any) (<-chan map[string]any, error) {
+	return m.changeLog.Watch(ctx, dbname, collname, spec)
+}
+
+// helper function to publish change events, events of transaction are kept
+// until it is committed. It should be called with acquired lock.
+func (m *MemoryDBV2) publish(events ...map[string]any) {
+	if m.changeLog == nil {
+		m.pending = append(m.pending, events...)
+		return
+	}
+	m.changeLog.Publish(events...)
+}
+
+// helper function to return database used by given context, i.e. copy of
+// the database within transaction or database itself
+func (m *MemoryDBV2) store(ctx context.Context) *MemoryDBV2 {
+	if tx, ok := ctx.Value(txKey{m}).(*MemoryDBV2); ok {
+		return tx
+	}
+	return m
+}
+
+// helper function to make deep copy of a snapshot
+func copySnapshot(snapshot Snapshot) Snapshot {
+	out := make(Snapshot, len(snapshot))
+	for dbname, colls := range snapshot {
+		out[dbname] = make(map[string][]map[string]any, len(colls))
+		for collname, records := range colls {
+			out[dbname][collname] = copyRecords(records)
+		}
+	}
+	return out
+}
+
+// helper function to make deep copy of records
+func copyRecords(records []map[string]any) []map[string]any {
+	out := make([]map[string]any, 0, len(records))
+	for _, rec := range records {
+		out = append(out, copyValue(rec).(map[string]any))
+	}
+	return out
+}
+
+// helper function to make deep copy of a value, it converts MongoDB
+// documents and arrays into plain maps and slices
+func copyValue(val any) any {
+	switch v := val.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[k] = copyValue(e)
+		}
+		return out
+	case bson.M:
+		return copyValue(map[string]any(v))
+	case bson.D:
+		out := make(map[string]any, len(v))
+		for _, e := range v {
+			out[e.Key] = copyValue(e.Value)
+		}
+		return out
+	case []map[string]any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = copyValue(e)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = copyValue(e)
+		}
+		return out
+	case bson.A:
+		return copyValue([]any(v))
+	}
+	return val
+}
+
+// helper function to generate new record id, we use the same format as
+// MongoDB object ids to keep ids time ordered
+func newID() string {
+	return bson.NewObjectID().Hex()
+}
+
+// helper function to remove _id attribute from records, as MongoDB backend
+// does not return it either
+func stripID(records []map[string]any) []map[string]any {
+	for _, rec := range records {
+		delete(rec, "_id")
+	}
+	return records
+}
+
+// helper function to pass positions of records matching given spec to given
+// function, iteration stops if function returns false. It should be called
+// with acquired lock.
+func (m *MemoryDBV2) scan(ctx context.Context, dbname, collname string, spec map[string]any, fn func(pos int, rec map[string]any) bool) error {
+	matcher, err := query.Compile(spec)
+	if err != nil {
+		return fmt.Errorf("query.Compile error: %w", err)
+	}
+	for pos, rec := range m.data[dbname][collname] {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if matcher.Match(rec) && !fn(pos, rec) {
+			break
+		}
+	}
+	return nil
+}
+
+// helper function to insert new record, it assigns generated _id to records
+// without it and publishes insert event. It should be called with acquired
+// lock.
+func (m *MemoryDBV2) insertRecord(dbname, collname string, record map[string]any) error {
+	nrec := copyValue(record).(map[string]any)
+	if id, ok := nrec["_id"]; !ok || id == nil || id == "" {
+		nrec["_id"] = newID()
+	} else {
+		for _, rec := range m.data[dbname][collname] {
+			if query.Compare(rec["_id"], id) == 0 {
+				return fmt.Errorf("%w: _id %v", ErrDuplicateKey, id)
+			}
+		}
+	}
+	if _, ok := m.data[dbname]; !ok {
+		m.data[dbname] = make(map[string][]map[string]any)
+	}
+	m.data[dbname][collname] = append(m.data[dbname][collname], nrec)
+	m.publish(changes.Event(changes.Insert, dbname, collname, nrec))
+	return nil
+}
+
+// Insert inserts records into in-memory database
+func (m *MemoryDBV2) Insert(ctx context.Context, dbname, collname string, records []map[string]any) error {
+	m = m.store(ctx)
+	if err := m.upsert(ctx, dbname, collname, "", records); err != nil {
+		return fmt.Errorf("[golib.memory.Insert] upsert error: %w", err)
+	}
+	return nil
+}
+
+// Upsert upserts records into in-memory database. It follows MongoDB
+// semantics: existing record is looked up by given attribute value and new
+// values are merged into it, otherwise new record with generated _id is
+// inserted. Records without attribute value are skipped.
+func (m *MemoryDBV2) Upsert(ctx context.Context, dbname, collname, attr string, records []map[string]any) error {
+	m = m.store(ctx)
+	if err := m.upsert(ctx, dbname, collname, attr, records); err != nil {
+		return fmt.Errorf("[golib.memory.Upsert] upsert error: %w", err)
+	}
+	return nil
+}
+
+// helper function to upsert records using given attribute, empty attribute
+// means plain insert of records
+func (m *MemoryDBV2) upsert(ctx context.Context, dbname, collname, attr string, records []map[string]any) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.version++
+	for _, record := range records {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if attr == "" {
+			if err := m.insertRecord(dbname, collname, record); err != nil {
+				return fmt.Errorf("failed to insert record: %w", err)
+			}
+			continue
+		}
+		value, ok := record[attr]
+		if !ok || value == nil || value == "" {
+			continue
+		}
+		// update first matched record as MongoDB UpdateOne does
+		var doc map[string]any
+		err := m.scan(ctx, dbname, collname, map[string]any{attr: value}, func(pos int, rec map[string]any) bool {
+			doc = rec
+			return false
+		})
+		if err != nil {
+			return fmt.Errorf("failed to find record: %w", err)
+		}
+		if doc == nil {
+			if err := m.insertRecord(dbname, collname, record); err != nil {
+				return fmt.Errorf("failed to insert record: %w", err)
+			}
+			continue
+		}
+		for k, v := range record {
+			if k != "_id" {
+				doc[k] = copyValue(v)
+			}
+		}
+		m.publish(changes.Event(changes.Update, dbname, collname, doc))
+	}
+	return nil
+}
+
+// Get fetches records from in-memory database for given spec and
+// pagination, records are returned in order of their insertion
+func (m *MemoryDBV2) Get(ctx context.Context, dbname, collname string, spec map[string]any, idx, limit int) ([]map[string]any, error) {
+	m = m.store(ctx)
+	results, err := m.getSorted(ctx, dbname, collname, spec, nil, 1, idx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("[golib.memory.Get] scan error: %w", err)
+	}
+	return stripID(results), nil
+}
+
+// GetProjection fetches records with given projection from in-memory database
+func (m *MemoryDBV2) GetProjection(ctx context.Context, dbname, collname string, spec map[string]any, projection map[string]int, idx, limit int) ([]map[string]any, error) {
+	m = m.store(ctx)
+	results, err := m.getSorted(ctx, dbname, collname, spec, nil, 1, idx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("[golib.memory.GetProjection] scan error: %w", err)
+	}
+	// like other APIs do not return _id unless it is explicitly requested
+	proj := map[string]int{"_id": 0}
+	for k, v := range projection {
+		proj[k] = v
+	}
+	out, err := query.Project(results, proj)
+	if err != nil {
+		return nil, fmt.Errorf("[golib.memory.GetProjection] projection error: %w", err)
+	}
+	return out, nil
+}
+
+// Update updates first record matching given spec in in-memory database
+func (m *MemoryDBV2) Update(ctx context.Context, dbname, collname string, spec, newdata map[string]any) error {
+	_, _, err := m.UpdateCount(ctx, dbname, collname, spec, newdata)
+	return err
+}
+
+// UpdateCount updates first record matching given spec in in-memory
+// database, as MongoDB UpdateOne does, and returns number of matched and
+// modified records
+func (m *MemoryDBV2) UpdateCount(ctx context.Context, dbname, collname string, spec, newdata map[string]any) (int, int, error) {
+	m = m.store(ctx)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	position := -1
+	var doc map[string]any
+	err := m.scan(ctx, dbname, collname, spec, func(pos int, rec map[string]any) bool {
+		position, doc = pos, rec
+		return false
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("[golib.memory.Update] scan error: %w", err)
+	}
+	if position < 0 {
+		return 0, 0, nil
+	}
+	rec, err := query.ApplyUpdate(doc, newdata)
+	if err != nil {
+		return 0, 0, fmt.Errorf("[golib.memory.Update] update error: %w", err)
+	}
+	if query.Equal(doc, rec) {
+		return 1, 0, nil
+	}
+	m.data[dbname][collname][position] = copyValue(rec).(map[string]any)
+	m.publish(changes.Event(changes.Update, dbname, collname, rec))
+	m.version++
+	return 1, 1, nil
+}
+
+// Count counts records matching given spec in in-memory database
+func (m *MemoryDBV2) Count(ctx context.Context, dbname, collname string, spec map[string]any) (int, error) {
+	m = m.store(ctx)
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	nrec := 0
+	err := m.scan(ctx, dbname, collname, spec, func(pos int, rec map[string]any) bool {
+		nrec++
+		return true
+	})
+	if err != nil {
+		return 0, fmt.Errorf("[golib.memory.Count] scan error: %w", err)
+	}
+	return nrec, nil
+}
+
+// Remove removes records matching given spec from in-memory database
+func (m *MemoryDBV2) Remove(ctx context.Context, dbname, collname string, spec map[string]any) error {
+	m = m.store(ctx)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	removed := make(map[int]bool)
+	err := m.scan(ctx, dbname, collname, spec, func(pos int, rec map[string]any) bool {
+		removed[pos] = true
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("[golib.memory.Remove] scan error: %w", err)
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	var records []map[string]any
+	for pos, rec := range m.data[dbname][collname] {
+		if removed[pos] {
+			m.publish(changes.Event(changes.Delete, dbname, collname, rec))
+		} else {
+			records = append(records, rec)
+		}
+	}
+	m.data[dbname][collname] = records
+	m.version++
+	return nil
+}
+
+// Distinct returns unique values of given field in in-memory database collection
+func (m *MemoryDBV2) Distinct(ctx context.Context, dbname, collname, field string) ([]any, error) {
+	m = m.store(ctx)
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	var out []any
+	// loop over records and collect unique values of the field
+	seen := make(map[string]bool)
+	err := m.scan(ctx, dbname, collname, map[string]any{}, func(pos int, rec map[string]any) bool {
+		if val, ok := rec[field]; ok {
+			key := fmt.Sprintf("%v", val)
+			if !seen[key] {
+				seen[key] = true
+				out = append(out, copyValue(val))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return out, fmt.Errorf("[golib.memory.Distinct] scan error: %w", err)
+	}
+	return out, nil
+}
+
+// InsertRecord inserts single record into in-memory database
+func (m *MemoryDBV2) InsertRecord(ctx context.Context, dbname, collname string, rec map[string]any) error {
+	m = m.store(ctx)
+	var records []map[string]any
+	records = append(records, rec)
+	return m.upsert(ctx, dbname, collname, "", records)
+}
+
+// GetSorted fetches records from in-memory database sorted by given keys
+// with specific order
+func (m *MemoryDBV2) GetSorted(ctx context.Context, dbname, collname string, spec map[string]any, skeys []string, sortOrder, idx, limit int) ([]map[string]any, error) {
+	m = m.store(ctx)
+	results, err := m.getSorted(ctx, dbname, collname, spec, skeys, sortOrder, idx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("[golib.memory.GetSorted] scan error: %w", err)
+	}
+	return stripID(results), nil
+}
+
+// helper function to fetch records within [idx, idx+limit) window sorted by
+// given keys, records with equal keys keep their insertion order. It returns
+// copies of stored records.
+func (m *MemoryDBV2) getSorted(ctx context.Context, dbname, collname string, spec map[string]any, skeys []string, sortOrder, idx, limit int) ([]map[string]any, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	topk := query.NewTopK(skeys, sortOrder, idx, limit)
+	err := m.scan(ctx, dbname, collname, spec, func(pos int, rec map[string]any) bool {
+		topk.Push(rec)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return copyRecords(topk.Records()), nil
+}
@@ -0,0 +1,90 @@
+package embed
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	db := NewMemoryDB()
+	records := []map[string]any{
+		{"did": "/a", "energy": 10, "tags": []any{"x"}},
+		{"did": "/b", "energy": 20},
+	}
+	if err := db.Upsert("test", "meta", "did", records); err != nil {
+		t.Fatalf("Failed to upsert records: %v", err)
+	}
+	// stored records should not share data with inserted and returned ones
+	records[0]["energy"] = 100
+	results := db.Get("test", "meta", map[string]any{"did": "/a"}, 0, 0)
+	if len(results) != 1 || results[0]["energy"] != 10 {
+		t.Fatalf("Wrong records %v", results)
+	}
+	results[0]["tags"].([]any)[0] = "y"
+
+	snapshot := db.Snapshot()
+	if err := db.Remove("test", "meta", map[string]any{"did": "/a"}); err != nil {
+		t.Fatalf("Failed to remove records: %v", err)
+	}
+	db.Insert("test", "meta", []map[string]any{{"did": "/c"}})
+	if nrec := db.Count("test", "meta", map[string]any{}); nrec != 2 {
+		t.Fatalf("Expected 2 records, got %d", nrec)
+	}
+	db.Restore(snapshot)
+	results = db.GetSorted("test", "meta", map[string]any{}, []string{"energy"}, -1, 0, 0)
+	if len(results) != 2 || results[0]["did"] != "/b" || results[1]["did"] != "/a" {
+		t.Fatalf("Wrong restored records %v", results)
+	}
+	if tags := results[1]["tags"].([]any); tags[0] != "x" {
+		t.Fatalf("Wrong restored tags %v", tags)
+	}
+
+	// other instances do not share data
+	if nrec := NewMemoryDB().Count("test", "meta", map[string]any{}); nrec != 0 {
+		t.Fatalf("Expected 0 records in new database, got %d", nrec)
+	}
+}
+
+func TestInsertRecord(t *testing.T) {
+	db := NewMemoryDBV2()
+	ctx := context.Background()
+	if err := db.InsertRecord(ctx, "test", "meta", map[string]any{"_id": "abc", "did": "/a"}); err != nil {
+		t.Fatalf("Failed to insert record: %v", err)
+	}
+	err := db.InsertRecord(ctx, "test", "meta", map[string]any{"_id": "abc", "did": "/b"})
+	if !errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("Expected ErrDuplicateKey error, got %v", err)
+	}
+	results, err := db.GetProjection(ctx, "test", "meta", map[string]any{}, map[string]int{"_id": 1}, 0, 0)
+	if err != nil || len(results) != 1 || results[0]["_id"] != "abc" || len(results[0]) != 1 {
+		t.Fatalf("Wrong projected records %v, error %v", results, err)
+	}
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := db.Count(cctx, "test", "meta", map[string]any{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled error, got %v", err)
+	}
+}
+
+func TestWithTransaction(t *testing.T) {
+	db := NewMemoryDBV2()
+	ctx := context.Background()
+	err := db.WithTransaction(ctx, func(tctx context.Context) error {
+		if err := db.Insert(tctx, "test", "meta", []map[string]any{{"did": "/a"}}); err != nil {
+			return err
+		}
+		// records inserted within transaction are invisible outside of it
+		if nrec, _ := db.Count(ctx, "test", "meta", map[string]any{}); nrec != 0 {
+			t.Fatalf("Expected 0 records outside of transaction, got %d", nrec)
+		}
+		return db.Insert(ctx, "test", "meta", []map[string]any{{"did": "/b"}})
+	})
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("Expected ErrConflict error, got %v", err)
+	}
+	results, err := db.Get(ctx, "test", "meta", map[string]any{}, 0, 0)
+	if err != nil || len(results) != 1 || results[0]["did"] != "/b" {
+		t.Fatalf("Wrong records %v, error %v", results, err)
+	}
+}
@@ -0,0 +1,613 @@
+package query
+
+// aggregate module provides evaluator of MongoDB aggregation pipelines used
+// by embedded document-oriented databases. It supports $match, $project,
+// $group, $sort, $skip, $limit, $count, $unwind, $facet, $bucket and
+// $sortByCount stages along with $sum, $avg, $min, $max, $first, $last,
+// $push, $addToSet and $count accumulators. Expressions are limited to field
+// paths, e.g. "$sample.name", $$ROOT, literals and documents of expressions.
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Aggregate evaluates given aggregation pipeline over given records
+func Aggregate(records []map[string]any, pipeline []map[string]any) ([]map[string]any, error) {
+	out, err := aggregate(records, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("[golib.embed.query.Aggregate] error: %w", err)
+	}
+	return out, nil
+}
+
+// helper function to evaluate aggregation pipeline
+func aggregate(records []map[string]any, pipeline []map[string]any) ([]map[string]any, error) {
+	for _, stage := range pipeline {
+		if len(stage) != 1 {
+			return nil, fmt.Errorf("pipeline stage must have single field, got %v", stage)
+		}
+		for name, arg := range stage {
+			var err error
+			records, err = aggregateStage(records, name, arg)
+			if err != nil {
+				return nil, fmt.Errorf("%s stage: %w", name, err)
+			}
+		}
+	}
+	return records, nil
+}
+
+// helper function to evaluate single pipeline stage
+func aggregateStage(records []map[string]any, name string, arg any) ([]map[string]any, error) {
+	switch name {
+	case "$match":
+		spec, ok := asDoc(arg)
+		if !ok {
+			return nil, fmt.Errorf("expects document, got %v", arg)
+		}
+		matcher, err := Compile(spec)
+		if err != nil {
+			return nil, err
+		}
+		var out []map[string]any
+		for _, rec := range records {
+			if matcher.Match(rec) {
+				out = append(out, rec)
+			}
+		}
+		return out, nil
+	case "$project":
+		return projectStage(records, arg)
+	case "$group":
+		spec, ok := asDoc(arg)
+		if !ok {
+			return nil, fmt.Errorf("expects document, got %v", arg)
+		}
+		idExpr, ok := spec["_id"]
+		if !ok {
+			return nil, fmt.Errorf("_id field is required")
+		}
+		fields := make(map[string]any)
+		for k, v := range spec {
+			if k != "_id" {
+				fields[k] = v
+			}
+		}
+		if err := checkExpr(idExpr); err != nil {
+			return nil, err
+		}
+		return accumulate(records, func(rec map[string]any) (any, error) {
+			val, _ := evalExpr(rec, idExpr)
+			return val, nil
+		}, fields)
+	case "$sort":
+		return sortStage(records, arg)
+	case "$skip", "$limit":
+		num, ok := Normalize(arg).(float64)
+		if !ok || num < 0 {
+			return nil, fmt.Errorf("expects non-negative number, got %v", arg)
+		}
+		n := int(num)
+		if name == "$skip" {
+			if n > len(records) {
+				n = len(records)
+			}
+			return records[n:], nil
+		}
+		if n < len(records) {
+			return records[:n], nil
+		}
+		return records, nil
+	case "$count":
+		field, ok := arg.(string)
+		if !ok || field == "" || strings.HasPrefix(field, "$") {
+			return nil, fmt.Errorf("expects field name, got %v", arg)
+		}
+		if len(records) == 0 {
+			return nil, nil
+		}
+		return []map[string]any{{field: len(records)}}, nil
+	case "$unwind":
+		return unwindStage(records, arg)
+	case "$facet":
+		return facetStage(records, arg)
+	case "$bucket":
+		return bucketStage(records, arg)
+	case "$sortByCount":
+		if err := checkExpr(arg); err != nil {
+			return nil, err
+		}
+		out, err := accumulate(records, func(rec map[string]any) (any, error) {
+			val, _ := evalExpr(rec, arg)
+			return val, nil
+		}, map[string]any{"count": map[string]any{"$sum": 1}})
+		if err != nil {
+			return nil, err
+		}
+		sort.SliceStable(out, func(i, j int) bool {
+			return out[i]["count"].(int) > out[j]["count"].(int)
+		})
+		return out, nil
+	}
+	return nil, fmt.Errorf("unsupported stage")
+}
+
+// helper function to check that expression contains only supported
+// constructs: field paths, literals and documents of expressions
+func checkExpr(expr any) error {
+	if doc, ok := asDoc(expr); ok {
+		for k, v := range doc {
+			if strings.HasPrefix(k, "$") {
+				return fmt.Errorf("unsupported expression operator %s", k)
+			}
+			if err := checkExpr(v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// helper function to evaluate expression over given record, it reports
+// whether field path exists in the record
+func evalExpr(rec map[string]any, expr any) (any, bool) {
+	if path, ok := expr.(string); ok {
+		if path == "$$ROOT" {
+			return rec, true
+		}
+		if !strings.HasPrefix(path, "$") {
+			return path, true
+		}
+		values, found := Lookup(rec, path[1:])
+		if !found {
+			return nil, false
+		}
+		if len(values) == 1 {
+			return values[0], true
+		}
+		return values, true
+	}
+	if doc, ok := asDoc(expr); ok {
+		out := make(map[string]any, len(doc))
+		for k, e := range doc {
+			if val, found := evalExpr(rec, e); found {
+				out[k] = val
+			}
+		}
+		return out, true
+	}
+	return expr, true
+}
+
+// helper function to compute key of a group with given _id value
+func groupKey(val any) string {
+	return fmt.Sprintf("%#v", Normalize(val))
+}
+
+// helper function to group records by key returned by given function and
+// compute given accumulator fields of every group, groups are returned in
+// order of their first record
+func accumulate(records []map[string]any, keyFn func(rec map[string]any) (any, error), fields map[string]any) ([]map[string]any, error) {
+	type group struct {
+		id   any
+		accs map[string]*accumulator
+	}
+	exprs := make(map[string]any)
+	for field, val := range fields {
+		spec, ok := asDoc(val)
+		if !ok || len(spec) != 1 {
+			return nil, fmt.Errorf("field %s must be accumulator document, got %v", field, val)
+		}
+		for op, expr := range spec {
+			if _, err := newAccumulator(op); err != nil {
+				return nil, err
+			}
+			if err := checkExpr(expr); err != nil {
+				return nil, err
+			}
+			exprs[field] = expr
+		}
+	}
+	var order []*group
+	groups := make(map[string]*group)
+	for _, rec := range records {
+		id, err := keyFn(rec)
+		if err != nil {
+			return nil, err
+		}
+		key := groupKey(id)
+		g, ok := groups[key]
+		if !ok {
+			g = &group{id: id, accs: make(map[string]*accumulator)}
+			for field, val := range fields {
+				spec, _ := asDoc(val)
+				for op := range spec {
+					g.accs[field], _ = newAccumulator(op)
+				}
+			}
+			groups[key] = g
+			order = append(order, g)
+		}
+		for field, acc := range g.accs {
+			acc.add(evalExpr(rec, exprs[field]))
+		}
+	}
+	var out []map[string]any
+	for _, g := range order {
+		doc := map[string]any{"_id": g.id}
+		for field, acc := range g.accs {
+			doc[field] = acc.result()
+		}
+		out = append(out, doc)
+	}
+	return out, nil
+}
+
+// accumulator represents state of group accumulator
+type accumulator struct {
+	op     string
+	sum    float64
+	isum   int64
+	float  bool
+	count  int
+	value  any
+	set    bool
+	values []any
+	seen   map[string]bool
+}
+
+// helper function to create accumulator for given operator
+func newAccumulator(op string) (*accumulator, error) {
+	switch op {
+	case "$sum", "$avg", "$min", "$max", "$first", "$last", "$push", "$addToSet", "$count":
+		return &accumulator{op: op, seen: make(map[string]bool)}, nil
+	}
+	return nil, fmt.Errorf("unsupported accumulator %s", op)
+}
+
+// helper function to add value to accumulator, like MongoDB most
+// accumulators ignore missing values
+func (a *accumulator) add(val any, found bool) {
+	switch a.op {
+	case "$sum", "$avg":
+		num, ok := Normalize(val).(float64)
+		if !ok {
+			return
+		}
+		if i, ok := asInt(val); ok {
+			a.isum += i
+		} else {
+			a.float = true
+		}
+		a.sum += num
+		a.count++
+	case "$min", "$max":
+		if !found || val == nil {
+			return
+		}
+		c := Compare(val, a.value)
+		if !a.set || (a.op == "$min" && c < 0) || (a.op == "$max" && c > 0) {
+			a.value, a.set = val, true
+		}
+	case "$first":
+		if !a.set {
+			a.value, a.set = val, true
+		}
+	case "$last":
+		a.value = val
+	case "$push", "$addToSet":
+		if !found {
+			return
+		}
+		if a.op == "$addToSet" {
+			key := groupKey(val)
+			if a.seen[key] {
+				return
+			}
+			a.seen[key] = true
+		}
+		a.values = append(a.values, val)
+	case "$count":
+		a.count++
+	}
+}
+
+// helper function to return accumulator result
+func (a *accumulator) result() any {
+	switch a.op {
+	case "$sum":
+		if a.float {
+			return a.sum
+		}
+		return int(a.isum)
+	case "$avg":
+		if a.count == 0 {
+			return nil
+		}
+		return a.sum / float64(a.count)
+	case "$push", "$addToSet":
+		if a.values == nil {
+			return []any{}
+		}
+		return a.values
+	case "$count":
+		return a.count
+	}
+	return a.value
+}
+
+// helper function to evaluate $project stage, numeric and boolean values
+// include or exclude fields while other values are evaluated as expressions
+// of computed fields
+func projectStage(records []map[string]any, arg any) ([]map[string]any, error) {
+	spec, ok := asDoc(arg)
+	if !ok {
+		return nil, fmt.Errorf("expects document, got %v", arg)
+	}
+	projection := make(map[string]int)
+	computed := make(map[string]any)
+	var nincl, nexcl int
+	for k, v := range spec {
+		switch val := Normalize(v).(type) {
+		case float64:
+			projection[k] = int(val)
+		case bool:
+			projection[k] = 0
+			if val {
+				projection[k] = 1
+			}
+		default:
+			if err := checkExpr(v); err != nil {
+				return nil, err
+			}
+			computed[k] = v
+			continue
+		}
+		if k != "_id" && projection[k] != 0 {
+			nincl++
+		} else if k != "_id" {
+			nexcl++
+		}
+	}
+	p, err := CompileProjection(projection)
+	if err != nil {
+		return nil, err
+	}
+	if len(computed) > 0 && nexcl > 0 {
+		return nil, fmt.Errorf("cannot mix computed fields and exclusion in projection %v", spec)
+	}
+	var out []map[string]any
+	for _, rec := range records {
+		var doc map[string]any
+		if len(computed) > 0 && nincl == 0 {
+			// computed fields turn projection into inclusion one
+			doc = make(map[string]any)
+			if id, ok := rec["_id"]; ok && p.withID {
+				doc["_id"] = id
+			}
+		} else {
+			doc = p.Apply(rec)
+		}
+		for k, expr := range computed {
+			if val, found := evalExpr(rec, expr); found {
+				if err := setPath(doc, strings.Split(k, "."), val); err != nil {
+					return nil, err
+				}
+			}
+		}
+		out = append(out, doc)
+	}
+	return out, nil
+}
+
+// helper function to evaluate $sort stage, sort document should be ordered
+// document, e.g. bson.D, if records are sorted by several keys
+func sortStage(records []map[string]any, arg any) ([]map[string]any, error) {
+	var keys []string
+	var orders []int
+	if doc, ok := asDoc(arg); ok {
+		if len(doc) != 1 {
+			return nil, fmt.Errorf("use ordered document to sort by several keys, got %v", arg)
+		}
+		for k, v := range doc {
+			keys = append(keys, k)
+			orders = append(orders, sortOrder(v))
+		}
+	} else if rv := reflect.ValueOf(arg); rv.Kind() == reflect.Slice {
+		for i := 0; i < rv.Len(); i++ {
+			elem := reflect.Indirect(rv.Index(i))
+			if elem.Kind() != reflect.Struct {
+				return nil, fmt.Errorf("wrong sort document %v", arg)
+			}
+			key, val := elem.FieldByName("Key"), elem.FieldByName("Value")
+			if !key.IsValid() || key.Kind() != reflect.String || !val.IsValid() {
+				return nil, fmt.Errorf("wrong sort document %v", arg)
+			}
+			keys = append(keys, key.String())
+			orders = append(orders, sortOrder(val.Interface()))
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("wrong sort document %v", arg)
+	}
+	for _, order := range orders {
+		if order == 0 {
+			return nil, fmt.Errorf("sort order must be 1 or -1, got %v", arg)
+		}
+	}
+	values := make([][]any, len(records))
+	for i, rec := range records {
+		values[i] = make([]any, len(keys))
+		for j, key := range keys {
+			values[i][j] = SortValues(rec, []string{key}, orders[j] < 0)[0]
+		}
+	}
+	idx := make([]int, len(records))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		for j := range keys {
+			c := compareNormalized(values[idx[a]][j], values[idx[b]][j]) * orders[j]
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	out := make([]map[string]any, len(records))
+	for i, j := range idx {
+		out[i] = records[j]
+	}
+	return out, nil
+}
+
+// helper function to convert sort order value to 1 or -1, zero means
+// wrong order
+func sortOrder(val any) int {
+	if num, ok := Normalize(val).(float64); ok {
+		if num == 1 {
+			return 1
+		} else if num == -1 {
+			return -1
+		}
+	}
+	return 0
+}
+
+// helper function to evaluate $unwind stage
+func unwindStage(records []map[string]any, arg any) ([]map[string]any, error) {
+	path, _ := arg.(string)
+	var preserve bool
+	if spec, ok := asDoc(arg); ok {
+		path, _ = spec["path"].(string)
+		preserve, _ = spec["preserveNullAndEmptyArrays"].(bool)
+	}
+	if !strings.HasPrefix(path, "$") || len(path) < 2 {
+		return nil, fmt.Errorf("expects field path, got %v", arg)
+	}
+	keys := strings.Split(path[1:], ".")
+	var out []map[string]any
+	for _, rec := range records {
+		val, found := getPath(rec, keys)
+		arr, isArray := asArray(val)
+		if !found || val == nil || (isArray && len(arr) == 0) {
+			if preserve {
+				out = append(out, rec)
+			}
+			continue
+		}
+		if !isArray {
+			out = append(out, rec)
+			continue
+		}
+		for _, elem := range arr {
+			doc := deepCopy(rec).(map[string]any)
+			if err := setPath(doc, keys, deepCopy(elem)); err != nil {
+				return nil, err
+			}
+			out = append(out, doc)
+		}
+	}
+	return out, nil
+}
+
+// helper function to evaluate $facet stage, it returns single document with
+// results of every sub-pipeline
+func facetStage(records []map[string]any, arg any) ([]map[string]any, error) {
+	spec, ok := asDoc(arg)
+	if !ok {
+		return nil, fmt.Errorf("expects document, got %v", arg)
+	}
+	out := make(map[string]any, len(spec))
+	for name, val := range spec {
+		stages, ok := asArray(val)
+		if !ok {
+			return nil, fmt.Errorf("facet %s expects pipeline, got %v", name, val)
+		}
+		var pipeline []map[string]any
+		for _, stage := range stages {
+			doc, ok := asDoc(stage)
+			if !ok {
+				return nil, fmt.Errorf("facet %s has wrong stage %v", name, stage)
+			}
+			pipeline = append(pipeline, doc)
+		}
+		results, err := aggregate(records, pipeline)
+		if err != nil {
+			return nil, fmt.Errorf("facet %s: %w", name, err)
+		}
+		docs := make([]any, 0, len(results))
+		for _, rec := range results {
+			docs = append(docs, rec)
+		}
+		out[name] = docs
+	}
+	return []map[string]any{out}, nil
+}
+
+// helper function to evaluate $bucket stage, buckets are returned in order
+// of their boundaries followed by default bucket
+func bucketStage(records []map[string]any, arg any) ([]map[string]any, error) {
+	spec, ok := asDoc(arg)
+	if !ok {
+		return nil, fmt.Errorf("expects document, got %v", arg)
+	}
+	groupBy, ok := spec["groupBy"]
+	if !ok {
+		return nil, fmt.Errorf("groupBy field is required")
+	}
+	if err := checkExpr(groupBy); err != nil {
+		return nil, err
+	}
+	boundaries, ok := asArray(spec["boundaries"])
+	if !ok || len(boundaries) < 2 {
+		return nil, fmt.Errorf("boundaries must be array of at least two values, got %v", spec["boundaries"])
+	}
+	for i := 1; i < len(boundaries); i++ {
+		if Compare(boundaries[i-1], boundaries[i]) >= 0 {
+			return nil, fmt.Errorf("boundaries must be in ascending order, got %v", boundaries)
+		}
+	}
+	defaultBucket, withDefault := spec["default"]
+	fields := map[string]any{"count": map[string]any{"$sum": 1}}
+	if output, ok := spec["output"]; ok {
+		if fields, ok = asDoc(output); !ok {
+			return nil, fmt.Errorf("output expects document, got %v", output)
+		}
+	}
+	results, err := accumulate(records, func(rec map[string]any) (any, error) {
+		val, _ := evalExpr(rec, groupBy)
+		for i := 0; i < len(boundaries)-1; i++ {
+			if Compare(val, boundaries[i]) >= 0 && Compare(val, boundaries[i+1]) < 0 {
+				return boundaries[i], nil
+			}
+		}
+		if withDefault {
+			return defaultBucket, nil
+		}
+		return nil, fmt.Errorf("value %v does not fall into any bucket and no default is specified", val)
+	}, fields)
+	if err != nil {
+		return nil, err
+	}
+	buckets := make(map[string]map[string]any, len(results))
+	for _, doc := range results {
+		buckets[groupKey(doc["_id"])] = doc
+	}
+	var out []map[string]any
+	keys := boundaries[:len(boundaries)-1]
+	if withDefault {
+		keys = append(append([]any{}, keys...), defaultBucket)
+	}
+	for _, key := range keys {
+		if doc, ok := buckets[groupKey(key)]; ok {
+			out = append(out, doc)
+			delete(buckets, groupKey(key))
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,184 @@
+package query
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// hexer represents types which provides hex representation, e.g. bson.ObjectID
+type hexer interface {
+	Hex() string
+}
+
+// Normalize converts given value into canonical form used by query evaluator:
+// all numbers become float64, all slices become []any, all maps with string
+// keys become map[string]any and object ids become their hex strings
+func Normalize(val any) any {
+	switch v := val.(type) {
+	case nil, bool, string, float64, time.Time, *regexp.Regexp:
+		return v
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = Normalize(e)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[k] = Normalize(e)
+		}
+		return out
+	case hexer:
+		return v.Hex()
+	}
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Slice, reflect.Array:
+		out := make([]any, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			out[i] = Normalize(rv.Index(i).Interface())
+		}
+		return out
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return val
+		}
+		out := make(map[string]any, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			out[iter.Key().String()] = Normalize(iter.Value().Interface())
+		}
+		return out
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return nil
+		}
+		return Normalize(rv.Elem().Interface())
+	}
+	return val
+}
+
+// typeOrder returns MongoDB type bracket order of normalized value, see
+// https://www.mongodb.com/docs/manual/reference/bson-type-comparison-order/
+func typeOrder(val any) int {
+	switch val.(type) {
+	case nil:
+		return 1
+	case float64:
+		return 2
+	case string:
+		return 3
+	case map[string]any:
+		return 4
+	case []any:
+		return 5
+	case bool:
+		return 6
+	case time.Time:
+		return 7
+	}
+	return 8
+}
+
+// Compare compares two values using MongoDB ordering rules, values of
+// different types are ordered by their type brackets. It returns -1, 0 or 1.
+func Compare(a, b any) int {
+	a = Normalize(a)
+	b = Normalize(b)
+	return compareNormalized(a, b)
+}
+
+func compareNormalized(a, b any) int {
+	ta, tb := typeOrder(a), typeOrder(b)
+	if ta != tb {
+		return sign(ta - tb)
+	}
+	switch av := a.(type) {
+	case nil:
+		return 0
+	case float64:
+		bv := b.(float64)
+		if av < bv {
+			return -1
+		} else if av > bv {
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(av, b.(string))
+	case bool:
+		bv := b.(bool)
+		if av == bv {
+			return 0
+		} else if !av {
+			return -1
+		}
+		return 1
+	case time.Time:
+		return av.Compare(b.(time.Time))
+	case []any:
+		bv := b.([]any)
+		for i := 0; i < len(av) && i < len(bv); i++ {
+			if c := compareNormalized(av[i], bv[i]); c != 0 {
+				return c
+			}
+		}
+		return sign(len(av) - len(bv))
+	case map[string]any:
+		bv := b.(map[string]any)
+		if reflect.DeepEqual(av, bv) {
+			return 0
+		}
+		return sign(len(av) - len(bv))
+	}
+	return 0
+}
+
+// helper function to return sign of integer value
+func sign(v int) int {
+	if v < 0 {
+		return -1
+	} else if v > 0 {
+		return 1
+	}
+	return 0
+}
+
+// Equal reports whether two values are equal after their normalization, e.g.
+// int and float64 numbers with the same value are equal
+func Equal(a, b any) bool {
+	return equal(Normalize(a), Normalize(b))
+}
+
+// helper function to compare two normalized values for equality
+func equal(a, b any) bool {
+	if typeOrder(a) != typeOrder(b) {
+		return false
+	}
+	switch a.(type) {
+	case []any, map[string]any:
+		return reflect.DeepEqual(a, b)
+	}
+	return compareNormalized(a, b) == 0
+}
@@ -0,0 +1,50 @@
+package query
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Lookup returns all values found in a record for given dotted path. Like
+// MongoDB it traverses nested documents and arrays, e.g. path "a.b" will
+// match both {"a": {"b": 1}} and {"a": [{"b": 1}, {"b": 2}]}, while numeric
+// path components, e.g. "a.0.b", refer to array elements. The boolean
+// return value reports if path exists in a record.
+func Lookup(rec map[string]any, path string) ([]any, bool) {
+	var out []any
+	found := lookup(rec, strings.Split(path, "."), &out)
+	return out, found
+}
+
+func lookup(val any, keys []string, out *[]any) bool {
+	if len(keys) == 0 {
+		*out = append(*out, val)
+		return true
+	}
+	key := keys[0]
+	switch v := val.(type) {
+	case map[string]any:
+		next, ok := v[key]
+		if !ok {
+			return false
+		}
+		return lookup(next, keys[1:], out)
+	case []any:
+		if idx, err := strconv.Atoi(key); err == nil {
+			if idx >= 0 && idx < len(v) {
+				return lookup(v[idx], keys[1:], out)
+			}
+			return false
+		}
+		found := false
+		for _, elem := range v {
+			if _, ok := elem.(map[string]any); ok {
+				if lookup(elem, keys, out) {
+					found = true
+				}
+			}
+		}
+		return found
+	}
+	return false
+}
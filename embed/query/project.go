@@ -0,0 +1,199 @@
+package query
+
+// project module provides MongoDB projection of records used by embedded
+// document-oriented databases
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Projection represents compiled MongoDB projection
+type Projection struct {
+	tree    projTree
+	include bool
+	withID  bool
+}
+
+// projTree represents tree of projected (dotted) paths, nil sub-tree marks
+// the end of projected path
+type projTree map[string]projTree
+
+// CompileProjection compiles given MongoDB projection. Like MongoDB it
+// supports either inclusion or exclusion of fields, dotted nested paths and
+// _id suppression in inclusion projection.
+func CompileProjection(projection map[string]int) (*Projection, error) {
+	p := &Projection{tree: make(projTree), withID: true}
+	var nincl, nexcl int
+	for path := range projection {
+		keys := strings.Split(path, ".")
+		for i := 1; i < len(keys); i++ {
+			if parent := strings.Join(keys[:i], "."); hasKey(projection, parent) {
+				return nil, fmt.Errorf("[golib.embed.query.CompileProjection] error: path collision between %s and %s", parent, path)
+			}
+		}
+		if path == "_id" {
+			p.withID = projection[path] != 0
+			continue
+		}
+		if projection[path] != 0 {
+			nincl++
+		} else {
+			nexcl++
+		}
+		p.tree.add(keys)
+	}
+	if nincl > 0 && nexcl > 0 {
+		return nil, fmt.Errorf("[golib.embed.query.CompileProjection] error: cannot mix inclusion and exclusion in projection %v", projection)
+	}
+	// projection {_id: 1} returns only _id field
+	p.include = nincl > 0 || (nexcl == 0 && hasKey(projection, "_id") && p.withID)
+	if !p.include && !p.withID {
+		p.tree["_id"] = nil
+	}
+	return p, nil
+}
+
+// helper function to check if projection has given path
+func hasKey(projection map[string]int, path string) bool {
+	_, ok := projection[path]
+	return ok
+}
+
+// helper function to add path to projection tree
+func (t projTree) add(keys []string) {
+	sub, ok := t[keys[0]]
+	if len(keys) == 1 {
+		t[keys[0]] = nil
+		return
+	}
+	if !ok || sub == nil {
+		sub = make(projTree)
+		t[keys[0]] = sub
+	}
+	sub.add(keys[1:])
+}
+
+// Apply returns new record with projected fields of given record
+func (p *Projection) Apply(rec map[string]any) map[string]any {
+	if p == nil || (!p.include && len(p.tree) == 0) {
+		return copyDoc(rec)
+	}
+	if !p.include {
+		return exclude(rec, p.tree)
+	}
+	out := include(rec, p.tree)
+	if id, ok := rec["_id"]; ok && p.withID {
+		out["_id"] = id
+	}
+	return out
+}
+
+// Project applies MongoDB projection to given records
+func Project(records []map[string]any, projection map[string]int) ([]map[string]any, error) {
+	p, err := CompileProjection(projection)
+	if err != nil {
+		return nil, err
+	}
+	var out []map[string]any
+	for _, rec := range records {
+		out = append(out, p.Apply(rec))
+	}
+	return out, nil
+}
+
+// helper function to create shallow copy of a document
+func copyDoc(doc map[string]any) map[string]any {
+	out := make(map[string]any, len(doc))
+	for k, v := range doc {
+		out[k] = v
+	}
+	return out
+}
+
+// helper function to keep only projected paths of a document
+func include(doc map[string]any, tree projTree) map[string]any {
+	out := make(map[string]any)
+	for key, sub := range tree {
+		val, ok := doc[key]
+		if !ok {
+			continue
+		}
+		if sub == nil {
+			out[key] = val
+			continue
+		}
+		switch v := val.(type) {
+		case map[string]any:
+			out[key] = include(v, sub)
+		case []any:
+			out[key] = includeArray(v, sub)
+		case []map[string]any:
+			arr := make([]any, 0, len(v))
+			for _, e := range v {
+				arr = append(arr, e)
+			}
+			out[key] = includeArray(arr, sub)
+		}
+	}
+	return out
+}
+
+// helper function to apply inclusion projection to array elements, like
+// MongoDB it drops scalar elements when nested path is projected
+func includeArray(arr []any, tree projTree) []any {
+	out := make([]any, 0, len(arr))
+	for _, e := range arr {
+		switch v := e.(type) {
+		case map[string]any:
+			out = append(out, include(v, tree))
+		case []any:
+			out = append(out, includeArray(v, tree))
+		}
+	}
+	return out
+}
+
+// helper function to remove projected paths from a document
+func exclude(doc map[string]any, tree projTree) map[string]any {
+	out := copyDoc(doc)
+	for key, sub := range tree {
+		val, ok := doc[key]
+		if !ok {
+			continue
+		}
+		if sub == nil {
+			delete(out, key)
+			continue
+		}
+		switch v := val.(type) {
+		case map[string]any:
+			out[key] = exclude(v, sub)
+		case []any:
+			out[key] = excludeArray(v, sub)
+		case []map[string]any:
+			arr := make([]any, 0, len(v))
+			for _, e := range v {
+				arr = append(arr, e)
+			}
+			out[key] = excludeArray(arr, sub)
+		}
+	}
+	return out
+}
+
+// helper function to apply exclusion projection to array elements
+func excludeArray(arr []any, tree projTree) []any {
+	out := make([]any, 0, len(arr))
+	for _, e := range arr {
+		switch v := e.(type) {
+		case map[string]any:
+			out = append(out, exclude(v, tree))
+		case []any:
+			out = append(out, excludeArray(v, tree))
+		default:
+			out = append(out, e)
+		}
+	}
+	return out
+}
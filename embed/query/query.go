@@ -0,0 +1,478 @@
+package query
+
+// query module provides in-process evaluator of MongoDB query specs used by
+// embedded document-oriented databases
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	bson "go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Matcher represents compiled MongoDB query spec
+type Matcher struct {
+	root node
+}
+
+// node represents single compiled query condition
+type node interface {
+	match(rec map[string]any) bool
+}
+
+// Compile compiles given MongoDB query spec into Matcher object. It supports
+// the following operators: $eq, $ne, $gt, $gte, $lt, $lte, $in, $nin,
+// $regex (with $options), $exists, $not, $or, $and, $nor and $text.
+func Compile(spec map[string]any) (*Matcher, error) {
+	root, err := compileDoc(spec)
+	if err != nil {
+		return nil, fmt.Errorf("[golib.embed.query.Compile] error: %w", err)
+	}
+	return &Matcher{root: root}, nil
+}
+
+// Match reports whether given record satisfies compiled query
+func (m *Matcher) Match(rec map[string]any) bool {
+	if m == nil || m.root == nil {
+		return true
+	}
+	nrec, _ := Normalize(rec).(map[string]any)
+	return m.root.match(nrec)
+}
+
+// Match reports whether given record satisfies MongoDB query spec
+func Match(rec, spec map[string]any) (bool, error) {
+	m, err := Compile(spec)
+	if err != nil {
+		return false, err
+	}
+	return m.Match(rec), nil
+}
+
+// logical nodes
+type andNode []node
+type orNode []node
+type norNode []node
+
+func (n andNode) match(rec map[string]any) bool {
+	for _, c := range n {
+		if !c.match(rec) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n orNode) match(rec map[string]any) bool {
+	for _, c := range n {
+		if c.match(rec) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n norNode) match(rec map[string]any) bool {
+	return !orNode(n).match(rec)
+}
+
+// textNode represents $text search over all string values of a record
+type textNode struct {
+	terms []string
+}
+
+func (n textNode) match(rec map[string]any) bool {
+	for _, term := range n.terms {
+		if containsText(rec, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// helper function to check if any string value of given object contains a term
+func containsText(val any, term string) bool {
+	switch v := val.(type) {
+	case string:
+		return strings.Contains(strings.ToLower(v), term)
+	case []any:
+		for _, e := range v {
+			if containsText(e, term) {
+				return true
+			}
+		}
+	case map[string]any:
+		for _, e := range v {
+			if containsText(e, term) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fieldNode represents list of conditions applied to a single (dotted) field
+type fieldNode struct {
+	path  string
+	conds []cond
+}
+
+func (n fieldNode) match(rec map[string]any) bool {
+	values, found := Lookup(rec, n.path)
+	for _, c := range n.conds {
+		if !c.match(values, found) {
+			return false
+		}
+	}
+	return true
+}
+
+// cond represents single operator condition on field values
+type cond interface {
+	match(values []any, found bool) bool
+}
+
+// helper function to compile query document, i.e. {key: cond, $or: [...]}
+func compileDoc(spec map[string]any) (node, error) {
+	var nodes andNode
+	// use sorted keys to make evaluation order deterministic
+	keys := make([]string, 0, len(spec))
+	for k := range spec {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		val := spec[key]
+		switch key {
+		case "$and", "$or", "$nor":
+			children, err := compileList(key, val)
+			if err != nil {
+				return nil, err
+			}
+			switch key {
+			case "$and":
+				nodes = append(nodes, andNode(children))
+			case "$or":
+				nodes = append(nodes, orNode(children))
+			case "$nor":
+				nodes = append(nodes, norNode(children))
+			}
+		case "$text":
+			n, err := compileText(val)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, n)
+		default:
+			if strings.HasPrefix(key, "$") {
+				return nil, fmt.Errorf("unsupported top-level operator %s", key)
+			}
+			conds, err := compileField(val)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %w", key, err)
+			}
+			nodes = append(nodes, fieldNode{path: key, conds: conds})
+		}
+	}
+	return nodes, nil
+}
+
+// helper function to compile list of query documents used by logical operators
+func compileList(op string, val any) ([]node, error) {
+	list, ok := Normalize(val).([]any)
+	if !ok || len(list) == 0 {
+		return nil, fmt.Errorf("%s requires non-empty array, got %v", op, val)
+	}
+	var nodes []node
+	for _, item := range list {
+		doc, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s requires array of documents, got %v", op, item)
+		}
+		n, err := compileDoc(doc)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+// helper function to compile $text operator
+func compileText(val any) (node, error) {
+	doc, ok := Normalize(val).(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("$text requires document, got %v", val)
+	}
+	search, ok := doc["$search"].(string)
+	if !ok {
+		return nil, fmt.Errorf("$text requires $search string, got %v", doc["$search"])
+	}
+	var terms []string
+	for _, term := range strings.Fields(search) {
+		terms = append(terms, strings.ToLower(strings.Trim(term, `"`)))
+	}
+	return textNode{terms: terms}, nil
+}
+
+// helper function to check if given document represents operator expression
+func isOperatorDoc(doc map[string]any) bool {
+	if len(doc) == 0 {
+		return false
+	}
+	for k := range doc {
+		if !strings.HasPrefix(k, "$") {
+			return false
+		}
+	}
+	return true
+}
+
+// helper function to compile field condition, i.e. value of {key: value} pair
+func compileField(val any) ([]cond, error) {
+	switch re := val.(type) {
+	case *regexp.Regexp:
+		return []cond{regexCond{re: re}}, nil
+	case bson.Regex:
+		cre, err := compileRegex(re.Pattern, re.Options)
+		if err != nil {
+			return nil, err
+		}
+		return []cond{regexCond{re: cre}}, nil
+	}
+	nval := Normalize(val)
+	doc, ok := nval.(map[string]any)
+	if !ok || !isOperatorDoc(doc) {
+		return []cond{eqCond{value: nval}}, nil
+	}
+	var conds []cond
+	ops := make([]string, 0, len(doc))
+	for k := range doc {
+		ops = append(ops, k)
+	}
+	sort.Strings(ops)
+	for _, op := range ops {
+		arg := doc[op]
+		switch op {
+		case "$eq":
+			conds = append(conds, eqCond{value: arg})
+		case "$ne":
+			conds = append(conds, notCond{eqCond{value: arg}})
+		case "$gt", "$gte", "$lt", "$lte":
+			conds = append(conds, rangeCond{op: op, value: arg})
+		case "$in", "$nin":
+			list, ok := arg.([]any)
+			if !ok {
+				return nil, fmt.Errorf("%s requires an array, got %v", op, arg)
+			}
+			values, err := inValues(list)
+			if err != nil {
+				return nil, err
+			}
+			var c cond = inCond{values: values}
+			if op == "$nin" {
+				c = notCond{c}
+			}
+			conds = append(conds, c)
+		case "$exists":
+			exists, ok := arg.(bool)
+			if !ok {
+				exists = arg != nil && arg != float64(0)
+			}
+			conds = append(conds, existsCond{exists: exists})
+		case "$regex":
+			options, _ := doc["$options"].(string)
+			re, err := compileRegex(arg, options)
+			if err != nil {
+				return nil, err
+			}
+			conds = append(conds, regexCond{re: re})
+		case "$options":
+			if _, ok := doc["$regex"]; !ok {
+				return nil, fmt.Errorf("$options requires $regex")
+			}
+		case "$not":
+			inner, err := compileField(arg)
+			if err != nil {
+				return nil, err
+			}
+			conds = append(conds, notCond{allCond(inner)})
+		default:
+			return nil, fmt.Errorf("unsupported operator %s", op)
+		}
+	}
+	return conds, nil
+}
+
+// helper function to compile BSON regular expressions of $in values
+func inValues(list []any) ([]any, error) {
+	values := make([]any, len(list))
+	for i, v := range list {
+		values[i] = v
+		if re, ok := v.(bson.Regex); ok {
+			cre, err := compileRegex(re.Pattern, re.Options)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = cre
+		}
+	}
+	return values, nil
+}
+
+// helper function to compile regex pattern with MongoDB options
+func compileRegex(pattern any, options string) (*regexp.Regexp, error) {
+	pat, ok := pattern.(string)
+	if !ok {
+		return nil, fmt.Errorf("$regex requires a string, got %v", pattern)
+	}
+	var flags string
+	for _, o := range options {
+		switch o {
+		case 'i', 'm', 's':
+			flags += string(o)
+		default:
+			return nil, fmt.Errorf("unsupported $regex option %q", o)
+		}
+	}
+	if flags != "" {
+		pat = fmt.Sprintf("(?%s)%s", flags, pat)
+	}
+	re, err := regexp.Compile(pat)
+	if err != nil {
+		return nil, fmt.Errorf("invalid $regex %v: %w", pattern, err)
+	}
+	return re, nil
+}
+
+// helper function to expand field values with elements of array values, as
+// MongoDB matches array fields if any of its elements satisfies the condition
+func expand(values []any) []any {
+	var out []any
+	for _, v := range values {
+		out = append(out, v)
+		if arr, ok := v.([]any); ok {
+			out = append(out, arr...)
+		}
+	}
+	return out
+}
+
+// eqCond implements $eq operator
+type eqCond struct {
+	value any
+}
+
+func (c eqCond) match(values []any, found bool) bool {
+	if !found {
+		// {key: null} matches records without a key
+		return c.value == nil
+	}
+	for _, v := range expand(values) {
+		if equal(v, c.value) {
+			return true
+		}
+	}
+	return false
+}
+
+// rangeCond implements $gt, $gte, $lt and $lte operators
+type rangeCond struct {
+	op    string
+	value any
+}
+
+func (c rangeCond) match(values []any, found bool) bool {
+	for _, v := range expand(values) {
+		// MongoDB compares only values within the same type bracket
+		if typeOrder(v) != typeOrder(c.value) {
+			continue
+		}
+		cmp := compareNormalized(v, c.value)
+		switch c.op {
+		case "$gt":
+			if cmp > 0 {
+				return true
+			}
+		case "$gte":
+			if cmp >= 0 {
+				return true
+			}
+		case "$lt":
+			if cmp < 0 {
+				return true
+			}
+		case "$lte":
+			if cmp <= 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// inCond implements $in operator
+type inCond struct {
+	values []any
+}
+
+func (c inCond) match(values []any, found bool) bool {
+	for _, v := range c.values {
+		if re, ok := v.(*regexp.Regexp); ok {
+			if (regexCond{re: re}).match(values, found) {
+				return true
+			}
+			continue
+		}
+		if (eqCond{value: v}).match(values, found) {
+			return true
+		}
+	}
+	return false
+}
+
+// existsCond implements $exists operator
+type existsCond struct {
+	exists bool
+}
+
+func (c existsCond) match(values []any, found bool) bool {
+	return found == c.exists
+}
+
+// regexCond implements $regex operator
+type regexCond struct {
+	re *regexp.Regexp
+}
+
+func (c regexCond) match(values []any, found bool) bool {
+	for _, v := range expand(values) {
+		if s, ok := v.(string); ok && c.re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// notCond negates given condition
+type notCond struct {
+	cond cond
+}
+
+func (c notCond) match(values []any, found bool) bool {
+	return !c.cond.match(values, found)
+}
+
+// allCond requires all conditions to match
+type allCond []cond
+
+func (c allCond) match(values []any, found bool) bool {
+	for _, e := range c {
+		if !e.match(values, found) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,336 @@
+package query
+
+import (
+	"testing"
+
+	bson "go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// TestMatch tests MongoDB query operators
+func TestMatch(t *testing.T) {
+	rec := map[string]any{
+		"did":      "/beamline=3a/btr=abc-123/cycle=2024-3",
+		"beamline": []string{"3a", "3b"},
+		"energy":   25,
+		"cycle":    "2024-3",
+		"sample":   map[string]any{"name": "Fe", "mass": 1.5},
+		"scans":    []any{map[string]any{"id": 1}, map[string]any{"id": 2}},
+		"public":   true,
+	}
+	tests := []struct {
+		spec   map[string]any
+		expect bool
+	}{
+		{map[string]any{}, true},
+		{map[string]any{"cycle": "2024-3"}, true},
+		{map[string]any{"cycle": "2024-2"}, false},
+		{map[string]any{"energy": 25.0}, true},
+		{map[string]any{"energy": map[string]any{"$eq": 25}}, true},
+		{map[string]any{"energy": map[string]any{"$ne": 25}}, false},
+		{map[string]any{"energy": map[string]any{"$gt": 10, "$lte": 25}}, true},
+		{map[string]any{"energy": map[string]any{"$gte": 10, "$lt": 25}}, false},
+		{map[string]any{"energy": map[string]any{"$gt": "10"}}, false},
+		{map[string]any{"beamline": "3b"}, true},
+		{map[string]any{"beamline": map[string]any{"$in": []any{"4b", "3a"}}}, true},
+		{map[string]any{"beamline": map[string]any{"$nin": []string{"4b", "3a"}}}, false},
+		{map[string]any{"did": map[string]any{"$regex": "^/BEAMLINE=3a", "$options": "i"}}, true},
+		{map[string]any{"did": map[string]any{"$regex": "^/BEAMLINE=3a"}}, false},
+		{map[string]any{"beamline": map[string]any{"$in": []any{bson.Regex{Pattern: "^3B$", Options: "i"}}}}, true},
+		{map[string]any{"beamline": map[string]any{"$nin": []any{bson.Regex{Pattern: "^3b$"}, "4b"}}}, false},
+		{map[string]any{"cycle": bson.Regex{Pattern: "^2024", Options: ""}}, true},
+		{map[string]any{"sample.name": "Fe"}, true},
+		{map[string]any{"sample.mass": map[string]any{"$lt": 2}}, true},
+		{map[string]any{"scans.id": 2}, true},
+		{map[string]any{"scans.1.id": 2}, true},
+		{map[string]any{"scans.0.id": 2}, false},
+		{map[string]any{"sample.name": map[string]any{"$exists": true}}, true},
+		{map[string]any{"sample.size": map[string]any{"$exists": true}}, false},
+		{map[string]any{"sample.size": map[string]any{"$exists": false}}, true},
+		{map[string]any{"missing": nil}, true},
+		{map[string]any{"$or": []any{
+			map[string]any{"cycle": "2023-1"},
+			map[string]any{"beamline": "3a"},
+		}}, true},
+		{map[string]any{"$and": []map[string]any{
+			{"cycle": "2024-3"},
+			{"public": false},
+		}}, false},
+		{map[string]any{"$nor": []any{map[string]any{"cycle": "2023-1"}}}, true},
+		{map[string]any{"energy": map[string]any{"$not": map[string]any{"$gt": 30}}}, true},
+		{map[string]any{"$text": map[string]any{"$search": "abc-123"}}, true},
+		{map[string]any{"$text": map[string]any{"$search": "xyz"}}, false},
+	}
+	for _, test := range tests {
+		match, err := Match(rec, test.spec)
+		if err != nil {
+			t.Errorf("spec %v, unexpected error %v", test.spec, err)
+			continue
+		}
+		if match != test.expect {
+			t.Errorf("spec %v, expect %v got %v", test.spec, test.expect, match)
+		}
+	}
+}
+
+// TestCompileErrors tests compilation of invalid specs
+func TestCompileErrors(t *testing.T) {
+	specs := []map[string]any{
+		{"$where": "this.a == 1"},
+		{"a": map[string]any{"$foo": 1}},
+		{"a": map[string]any{"$in": 1}},
+		{"a": map[string]any{"$regex": "("}},
+		{"$or": []any{}},
+	}
+	for _, spec := range specs {
+		if _, err := Compile(spec); err == nil {
+			t.Errorf("spec %v, expected error", spec)
+		}
+	}
+}
+
+// TestCompare tests MongoDB ordering of values
+func TestCompare(t *testing.T) {
+	if Compare(1, 2.5) != -1 {
+		t.Error("wrong order of numbers")
+	}
+	if Compare("b", "a") != 1 {
+		t.Error("wrong order of strings")
+	}
+	if Compare(nil, 1) != -1 || Compare(10, "1") != -1 {
+		t.Error("wrong order of type brackets")
+	}
+	if Compare([]int{1, 2}, []any{1.0, 2.0}) != 0 {
+		t.Error("wrong comparison of arrays")
+	}
+}
+
+// TestTopK tests bounded sorting of records
+func TestTopK(t *testing.T) {
+	var records []map[string]any
+	for _, v := range []int{5, 3, 9, 1, 7} {
+		records = append(records, map[string]any{"v": v, "tags": []any{v, 10 - v}})
+	}
+	records = append(records, map[string]any{"name": "missing"})
+	tests := []struct {
+		skeys  []string
+		order  int
+		idx    int
+		limit  int
+		expect []any
+	}{
+		{[]string{"v"}, 1, 0, 0, []any{nil, 1, 3, 5, 7, 9}},
+		{[]string{"v"}, -1, 0, 2, []any{9, 7}},
+		{[]string{"v"}, 1, 2, 2, []any{3, 5}},
+		{[]string{"v"}, 1, 5, 3, []any{9}},
+		// arrays are sorted by min element for ascending and max element for descending order
+		{[]string{"tags"}, 1, 1, 3, []any{9, 1, 3}},
+		{[]string{"tags"}, -1, 0, 2, []any{9, 1}},
+	}
+	for _, test := range tests {
+		topk := NewTopK(test.skeys, test.order, test.idx, test.limit)
+		for _, rec := range records {
+			topk.Push(rec)
+		}
+		if topk.items.Len() > test.idx+test.limit && test.limit > 0 {
+			t.Errorf("test %+v, TopK holds %d records", test, topk.items.Len())
+		}
+		var values []any
+		for _, rec := range topk.Records() {
+			values = append(values, rec["v"])
+		}
+		if Compare(values, test.expect) != 0 {
+			t.Errorf("test %+v, expect %v got %v", test, test.expect, values)
+		}
+	}
+}
+
+// TestProjection tests MongoDB projection of records
+func TestProjection(t *testing.T) {
+	rec := map[string]any{
+		"_id":    "abc",
+		"did":    "/beamline=3a",
+		"sample": map[string]any{"name": "Fe", "mass": 1.5},
+		"scans":  []any{map[string]any{"id": 1, "n": 10}, map[string]any{"id": 2, "n": 20}, 3},
+	}
+	tests := []struct {
+		projection map[string]int
+		expect     map[string]any
+	}{
+		{map[string]int{}, rec},
+		{map[string]int{"did": 1}, map[string]any{"_id": "abc", "did": "/beamline=3a"}},
+		{map[string]int{"did": 1, "_id": 0}, map[string]any{"did": "/beamline=3a"}},
+		{map[string]int{"_id": 1}, map[string]any{"_id": "abc"}},
+		{map[string]int{"sample.name": 1, "scans.id": 1, "_id": 0}, map[string]any{
+			"sample": map[string]any{"name": "Fe"},
+			"scans":  []any{map[string]any{"id": 1}, map[string]any{"id": 2}},
+		}},
+		{map[string]int{"sample.mass": 0, "scans.n": 0, "_id": 0}, map[string]any{
+			"did":    "/beamline=3a",
+			"sample": map[string]any{"name": "Fe"},
+			"scans":  []any{map[string]any{"id": 1}, map[string]any{"id": 2}, 3},
+		}},
+		{map[string]int{"_id": 0}, map[string]any{"did": rec["did"], "sample": rec["sample"], "scans": rec["scans"]}},
+	}
+	for _, test := range tests {
+		p, err := CompileProjection(test.projection)
+		if err != nil {
+			t.Errorf("projection %v, unexpected error %v", test.projection, err)
+			continue
+		}
+		if out := p.Apply(rec); Compare(out, test.expect) != 0 || len(out) != len(test.expect) {
+			t.Errorf("projection %v, expect %v got %v", test.projection, test.expect, out)
+		}
+	}
+	for _, projection := range []map[string]int{{"a": 1, "b": 0}, {"a": 1, "a.b": 1}} {
+		if _, err := CompileProjection(projection); err == nil {
+			t.Errorf("projection %v, expected error", projection)
+		}
+	}
+}
+
+func TestApplyUpdate(t *testing.T) {
+	rec := map[string]any{
+		"_id":    "abc",
+		"did":    "/beamline=3a",
+		"energy": 10,
+		"sample": map[string]any{"name": "Fe", "mass": 1.5},
+		"tags":   []any{"x"},
+	}
+	tests := []struct {
+		update map[string]any
+		expect map[string]any
+	}{
+		{map[string]any{"energy": 20, "_id": "xyz"}, map[string]any{"energy": 20}},
+		{map[string]any{"$set": map[string]any{"sample.name": "Cu", "beam.current": 5}},
+			map[string]any{"sample": map[string]any{"name": "Cu", "mass": 1.5}, "beam": map[string]any{"current": 5}}},
+		{map[string]any{"$unset": map[string]any{"sample.mass": "", "tags": ""}},
+			map[string]any{"sample": map[string]any{"name": "Fe"}, "tags": nil}},
+		{map[string]any{"$inc": map[string]any{"energy": 5, "sample.mass": 1, "runs": 1}},
+			map[string]any{"energy": 15, "sample": map[string]any{"name": "Fe", "mass": 2.5}, "runs": 1}},
+		{map[string]any{"$push": map[string]any{"tags": "y", "scans": map[string]any{"$each": []any{1, 2}}}},
+			map[string]any{"tags": []any{"x", "y"}, "scans": []any{1, 2}}},
+	}
+	for _, test := range tests {
+		out, err := ApplyUpdate(rec, test.update)
+		if err != nil {
+			t.Errorf("update %v, unexpected error %v", test.update, err)
+			continue
+		}
+		// expected fields override original ones, nil means removed field
+		expect := make(map[string]any)
+		for k, v := range rec {
+			expect[k] = v
+		}
+		for k, v := range test.expect {
+			if v == nil {
+				delete(expect, k)
+			} else {
+				expect[k] = v
+			}
+		}
+		if Compare(out, expect) != 0 || len(out) != len(expect) {
+			t.Errorf("update %v, expect %v got %v", test.update, expect, out)
+		}
+	}
+	if rec["energy"] != 10 || len(rec["tags"].([]any)) != 1 || rec["sample"].(map[string]any)["name"] != "Fe" {
+		t.Errorf("original record is modified %v", rec)
+	}
+	for _, update := range []map[string]any{
+		{"$set": map[string]any{"a": 1}, "b": 2},
+		{"$set": map[string]any{"_id": 1}},
+		{"$set": map[string]any{"did.a": 1}},
+		{"$inc": map[string]any{"did": 1}},
+		{"$push": map[string]any{"energy": 1}},
+		{"$rename": map[string]any{"a": "b"}},
+	} {
+		if _, err := ApplyUpdate(rec, update); err == nil {
+			t.Errorf("update %v, expected error", update)
+		}
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	records := []map[string]any{
+		{"did": "/a", "beamline": "3a", "energy": 10, "tags": []any{"x", "y"}},
+		{"did": "/b", "beamline": "3b", "energy": 30, "tags": []any{"y"}},
+		{"did": "/c", "beamline": "3a", "energy": 25.5},
+		{"did": "/d", "beamline": "3a", "energy": 70},
+	}
+	type sortKey struct {
+		Key   string
+		Value any
+	}
+	tests := []struct {
+		pipeline []map[string]any
+		expect   []map[string]any
+	}{
+		{[]map[string]any{
+			{"$match": map[string]any{"energy": map[string]any{"$gte": 20}}},
+			{"$group": map[string]any{"_id": "$beamline", "n": map[string]any{"$sum": 1},
+				"total": map[string]any{"$sum": "$energy"}, "dids": map[string]any{"$push": "$did"}}},
+		}, []map[string]any{
+			{"_id": "3b", "n": 1, "total": 30, "dids": []any{"/b"}},
+			{"_id": "3a", "n": 2, "total": 95.5, "dids": []any{"/c", "/d"}},
+		}},
+		{[]map[string]any{
+			{"$group": map[string]any{"_id": nil, "avg": map[string]any{"$avg": "$energy"},
+				"min": map[string]any{"$min": "$energy"}, "max": map[string]any{"$max": "$energy"}}},
+		}, []map[string]any{{"_id": nil, "avg": 33.875, "min": 10, "max": 70}}},
+		{[]map[string]any{
+			{"$sort": []sortKey{{"beamline", 1}, {"energy", -1}}},
+			{"$skip": 1},
+			{"$limit": 2},
+			{"$project": map[string]any{"did": 1, "_id": 0, "bl": "$beamline"}},
+		}, []map[string]any{{"did": "/c", "bl": "3a"}, {"did": "/a", "bl": "3a"}}},
+		{[]map[string]any{
+			{"$unwind": "$tags"},
+			{"$sortByCount": "$tags"},
+		}, []map[string]any{{"_id": "y", "count": 2}, {"_id": "x", "count": 1}}},
+		{[]map[string]any{
+			{"$bucket": map[string]any{"groupBy": "$energy", "boundaries": []any{0, 20, 50},
+				"default": "other", "output": map[string]any{"dids": map[string]any{"$push": "$did"}}}},
+		}, []map[string]any{
+			{"_id": 0, "dids": []any{"/a"}},
+			{"_id": 20, "dids": []any{"/b", "/c"}},
+			{"_id": "other", "dids": []any{"/d"}},
+		}},
+		{[]map[string]any{
+			{"$facet": map[string]any{
+				"total":     []map[string]any{{"$count": "n"}},
+				"beamlines": []any{map[string]any{"$group": map[string]any{"_id": "$beamline"}}},
+			}},
+		}, []map[string]any{{
+			"total":     []any{map[string]any{"n": 4}},
+			"beamlines": []any{map[string]any{"_id": "3a"}, map[string]any{"_id": "3b"}},
+		}}},
+	}
+	for _, test := range tests {
+		out, err := Aggregate(records, test.pipeline)
+		if err != nil {
+			t.Errorf("pipeline %v, unexpected error %v", test.pipeline, err)
+			continue
+		}
+		if len(out) != len(test.expect) {
+			t.Errorf("pipeline %v, expect %v got %v", test.pipeline, test.expect, out)
+			continue
+		}
+		for i, doc := range out {
+			if Compare(doc, test.expect[i]) != 0 || len(doc) != len(test.expect[i]) {
+				t.Errorf("pipeline %v, expect %v got %v", test.pipeline, test.expect, out)
+				break
+			}
+		}
+	}
+	for _, pipeline := range [][]map[string]any{
+		{{"$lookup": map[string]any{"from": "other"}}},
+		{{"$group": map[string]any{"n": map[string]any{"$sum": 1}}}},
+		{{"$group": map[string]any{"_id": map[string]any{"$toUpper": "$did"}}}},
+		{{"$sort": map[string]any{"did": 1, "energy": -1}}},
+		{{"$bucket": map[string]any{"groupBy": "$energy", "boundaries": []any{0, 20}}}},
+		{{"$match": map[string]any{}, "$limit": 1}},
+	} {
+		if _, err := Aggregate(records, pipeline); err == nil {
+			t.Errorf("pipeline %v, expected error", pipeline)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package query
+
+// sort module provides bounded top-K sorting of records used by embedded
+// document-oriented databases to implement sort, skip and limit without
+// loading all matched records into memory
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// TopK collects records sorted by given keys and keeps only records which
+// may fall into [idx, idx+limit) window, i.e. at most idx+limit records are
+// held in memory. Zero limit means no limit, like in MongoDB.
+type TopK struct {
+	keys  []string
+	desc  bool
+	tie   string
+	idx   int
+	size  int
+	seq   int
+	items topItems
+}
+
+// topItem represents record along with its sort values
+type topItem struct {
+	record map[string]any
+	values []any
+	tie    any
+	seq    int
+}
+
+// NewTopK creates new TopK object for given sort keys, sort order (negative
+// value means descending order) and idx/limit window
+func NewTopK(skeys []string, sortOrder, idx, limit int) *TopK {
+	if idx < 0 {
+		idx = 0
+	}
+	t := &TopK{keys: skeys, desc: sortOrder < 0, idx: idx}
+	if limit > 0 {
+		t.size = idx + limit
+	}
+	t.items.topk = t
+	return t
+}
+
+// SetTieBreaker sets key used to order records with equal sort values, such
+// records are always ordered by its value in ascending order. By default
+// records with equal sort values keep their insertion order.
+func (t *TopK) SetTieBreaker(key string) {
+	t.tie = key
+}
+
+// Push adds record to TopK, the record is dropped if it can't be part of
+// requested window
+func (t *TopK) Push(rec map[string]any) {
+	item := topItem{record: rec, values: SortValues(rec, t.keys, t.desc), seq: t.seq}
+	if t.tie != "" {
+		item.tie = SortValues(rec, []string{t.tie}, false)[0]
+	}
+	t.seq++
+	if t.size == 0 || t.items.Len() < t.size {
+		heap.Push(&t.items, item)
+		return
+	}
+	// root of the heap holds the last record within the window
+	if t.less(item, t.items.list[0]) {
+		t.items.list[0] = item
+		heap.Fix(&t.items, 0)
+	}
+}
+
+// Records returns sorted records within requested window
+func (t *TopK) Records() []map[string]any {
+	list := append([]topItem{}, t.items.list...)
+	sort.Slice(list, func(i, j int) bool { return t.less(list[i], list[j]) })
+	var out []map[string]any
+	for i := t.idx; i < len(list); i++ {
+		out = append(out, list[i].record)
+	}
+	return out
+}
+
+// helper function to compare two items, records with equal sort values
+// keep their insertion order
+func (t *TopK) less(a, b topItem) bool {
+	for i := range t.keys {
+		c := compareNormalized(a.values[i], b.values[i])
+		if t.desc {
+			c = -c
+		}
+		if c != 0 {
+			return c < 0
+		}
+	}
+	if c := compareNormalized(a.tie, b.tie); c != 0 {
+		return c < 0
+	}
+	return a.seq < b.seq
+}
+
+// topItems implements heap.Interface with the last sorted item at its root
+type topItems struct {
+	list []topItem
+	topk *TopK
+}
+
+func (h topItems) Len() int           { return len(h.list) }
+func (h topItems) Less(i, j int) bool { return h.topk.less(h.list[j], h.list[i]) }
+func (h topItems) Swap(i, j int)      { h.list[i], h.list[j] = h.list[j], h.list[i] }
+func (h *topItems) Push(x any)        { h.list = append(h.list, x.(topItem)) }
+func (h *topItems) Pop() any {
+	n := len(h.list)
+	item := h.list[n-1]
+	h.list = h.list[:n-1]
+	return item
+}
+
+// SortValues returns normalized values of given record used to sort it by
+// given keys. Like MongoDB it uses the smallest array element for ascending
+// and the largest one for descending order, and null for missing keys.
+func SortValues(rec map[string]any, skeys []string, desc bool) []any {
+	nrec, _ := Normalize(rec).(map[string]any)
+	out := make([]any, len(skeys))
+	for i, key := range skeys {
+		values, _ := Lookup(nrec, key)
+		var elems []any
+		for _, v := range values {
+			if arr, ok := v.([]any); ok {
+				elems = append(elems, arr...)
+			} else {
+				elems = append(elems, v)
+			}
+		}
+		for j, v := range elems {
+			c := compareNormalized(v, out[i])
+			if j == 0 || (desc && c > 0) || (!desc && c < 0) {
+				out[i] = v
+			}
+		}
+	}
+	return out
+}
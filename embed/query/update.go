@@ -0,0 +1,227 @@
+package query
+
+// update module applies MongoDB update operators to records of embedded
+// document-oriented databases
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ApplyUpdate returns copy of given record modified by given update document.
+// It supports $set, $unset, $inc and $push operators with dotted paths of
+// nested documents, while update document without operators is merged into
+// the record as embedded backends always did. Like in MongoDB the _id field
+// can't be modified.
+func ApplyUpdate(rec, update map[string]any) (map[string]any, error) {
+	out, _ := deepCopy(rec).(map[string]any)
+	if out == nil {
+		out = make(map[string]any)
+	}
+	var nops int
+	for key := range update {
+		if strings.HasPrefix(key, "$") {
+			nops++
+		}
+	}
+	if nops == 0 {
+		for k, v := range update {
+			if k != "_id" {
+				out[k] = deepCopy(v)
+			}
+		}
+		return out, nil
+	}
+	if nops != len(update) {
+		return nil, fmt.Errorf("[golib.embed.query.ApplyUpdate] error: cannot mix update operators and fields in %v", update)
+	}
+	for op, arg := range update {
+		fields, ok := asDoc(arg)
+		if !ok {
+			return nil, fmt.Errorf("[golib.embed.query.ApplyUpdate] error: %s expects document, got %v", op, arg)
+		}
+		for path, val := range fields {
+			if path == "_id" || strings.HasPrefix(path, "_id.") {
+				return nil, fmt.Errorf("[golib.embed.query.ApplyUpdate] error: field _id is immutable")
+			}
+			keys := strings.Split(path, ".")
+			var err error
+			switch op {
+			case "$set":
+				err = setPath(out, keys, deepCopy(val))
+			case "$unset":
+				unsetPath(out, keys)
+			case "$inc":
+				err = incPath(out, keys, val)
+			case "$push":
+				err = pushPath(out, keys, val)
+			default:
+				err = fmt.Errorf("unsupported update operator %s", op)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("[golib.embed.query.ApplyUpdate] %s error: %w", path, err)
+			}
+		}
+	}
+	return out, nil
+}
+
+// helper function to get value of nested document for given path
+func getPath(doc map[string]any, keys []string) (any, bool) {
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := doc[key].(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		doc = next
+	}
+	val, ok := doc[keys[len(keys)-1]]
+	return val, ok
+}
+
+// helper function to set value of nested document for given path, missing
+// intermediate documents are created
+func setPath(doc map[string]any, keys []string, val any) error {
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := doc[key]
+		if !ok || next == nil {
+			sub := make(map[string]any)
+			doc[key] = sub
+			doc = sub
+			continue
+		}
+		sub, ok := next.(map[string]any)
+		if !ok {
+			return fmt.Errorf("cannot create field in element {%s: %v}", key, next)
+		}
+		doc = sub
+	}
+	doc[keys[len(keys)-1]] = val
+	return nil
+}
+
+// helper function to remove value of nested document for given path
+func unsetPath(doc map[string]any, keys []string) {
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := doc[key].(map[string]any)
+		if !ok {
+			return
+		}
+		doc = next
+	}
+	delete(doc, keys[len(keys)-1])
+}
+
+// helper function to increment numeric value of given path, missing value
+// is set to the increment
+func incPath(doc map[string]any, keys []string, val any) error {
+	if _, ok := Normalize(val).(float64); !ok {
+		return fmt.Errorf("cannot increment with non-numeric value %v", val)
+	}
+	cur, ok := getPath(doc, keys)
+	if !ok {
+		return setPath(doc, keys, val)
+	}
+	if a, ok := asInt(cur); ok {
+		if b, ok := asInt(val); ok {
+			if _, ok := cur.(int); ok {
+				return setPath(doc, keys, int(a+b))
+			}
+			return setPath(doc, keys, a+b)
+		}
+	}
+	a, ok := Normalize(cur).(float64)
+	if !ok {
+		return fmt.Errorf("cannot increment non-numeric value %v", cur)
+	}
+	return setPath(doc, keys, a+Normalize(val).(float64))
+}
+
+// helper function to append value(s) to array of given path, missing array
+// is created. Like MongoDB it supports {$each: [...]} modifier.
+func pushPath(doc map[string]any, keys []string, val any) error {
+	values := []any{val}
+	if mod, ok := asDoc(val); ok {
+		if each, ok := mod["$each"]; ok {
+			if values, ok = asArray(each); !ok {
+				return fmt.Errorf("$each expects array, got %v", each)
+			}
+		}
+	}
+	var arr []any
+	if cur, ok := getPath(doc, keys); ok {
+		if arr, ok = asArray(cur); !ok {
+			return fmt.Errorf("cannot push to non-array value %v", cur)
+		}
+	}
+	for _, v := range values {
+		arr = append(arr, deepCopy(v))
+	}
+	return setPath(doc, keys, arr)
+}
+
+// helper function to convert integer value to int64
+func asInt(val any) (int64, bool) {
+	switch v := val.(type) {
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	}
+	return 0, false
+}
+
+// helper function to convert map with string keys, e.g. bson.M, into document
+func asDoc(val any) (map[string]any, bool) {
+	if doc, ok := val.(map[string]any); ok {
+		return doc, true
+	}
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+	out := make(map[string]any, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		out[iter.Key().String()] = iter.Value().Interface()
+	}
+	return out, true
+}
+
+// helper function to convert slice, e.g. bson.A, into array
+func asArray(val any) ([]any, bool) {
+	if arr, ok := val.([]any); ok {
+		return append([]any{}, arr...), true
+	}
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Slice {
+		return nil, false
+	}
+	out := make([]any, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		out[i] = rv.Index(i).Interface()
+	}
+	return out, true
+}
+
+// helper function to make deep copy of documents and arrays
+func deepCopy(val any) any {
+	switch v := val.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[k] = deepCopy(e)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = deepCopy(e)
+		}
+		return out
+	}
+	return val
+}
@@ -1,79 +1,458 @@
 package embed
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"sync"
 
+	changes "github.com/CHESSComputing/golib/embed/changes"
+	query "github.com/CHESSComputing/golib/embed/query"
 	tiedodb "github.com/HouzuoGuo/tiedot/db"
+	bson "go.mongodb.org/mongo-driver/v2/bson"
 )
 
 var db *tiedodb.DB
 
+// mutex serializes write operations which read and modify records
+var mutex sync.Mutex
+
+// changeLog delivers change events of TiedotDB records to watchers
+var changeLog = changes.NewLog()
+
+// ErrDuplicateKey is returned when inserted record has _id of existing record
+var ErrDuplicateKey = errors.New("duplicate key")
+
 // InitDB initializes document-oriented db connection object
-func InitDB(uri string) {
-	dbDir := "./tiedotDB"
+func InitDB(dbDir string) error {
 	var err error
+	// close previously opened database to release its files, database
+	// may already be closed by its user
+	if db != nil {
+		if err := db.Close(); err != nil {
+			log.Println("WARNING: unable to close TiedotDB", err)
+		}
+		db = nil
+	}
 	db, err = tiedodb.OpenDB(dbDir)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("failed to open TiedotDB: %v", err)
+	}
+	return nil
+}
+
+// helper function to return tiedot collection of given database/collection,
+// the collection is created along with _id index if it does not exist
+func collection(dbname, collname string) (*tiedodb.Col, error) {
+	name := fmt.Sprintf("%s.%s", dbname, collname)
+	if !db.ColExists(name) {
+		if err := db.Create(name); err != nil {
+			return nil, err
+		}
+		if err := db.Use(name).Index([]string{"_id"}); err != nil {
+			return nil, err
+		}
 	}
+	return db.Use(name), nil
+}
+
+// helper function to generate new record id, we use the same format as
+// MongoDB object ids to keep ids time ordered
+func newID() string {
+	return bson.NewObjectID().Hex()
+}
+
+// helper function to return copy of records without _id attribute, as
+// MongoDB backend does not return it either
+func stripID(records []map[string]any) []map[string]any {
+	var out []map[string]any
+	for _, rec := range records {
+		nrec := make(map[string]any, len(rec))
+		for k, v := range rec {
+			if k != "_id" {
+				nrec[k] = v
+			}
+		}
+		out = append(out, nrec)
+	}
+	return out
+}
+
+// helper function to pass documents matching given spec to given function
+// along with their tiedot ids, iteration stops if function returns false
+func scan(ctx context.Context, col *tiedodb.Col, spec map[string]any, fn func(id int, rec map[string]any) bool) error {
+	matcher, err := query.Compile(spec)
+	if err != nil {
+		return fmt.Errorf("query.Compile error: %w", err)
+	}
+	col.ForEachDoc(func(id int, doc []byte) bool {
+		if err = ctx.Err(); err != nil {
+			return false
+		}
+		var rec map[string]any
+		if err = json.Unmarshal(doc, &rec); err != nil {
+			err = fmt.Errorf("failed to unmarshal record: %v", err)
+			return false
+		}
+		if matcher.Match(rec) {
+			return fn(id, rec)
+		}
+		return true
+	})
+	return err
+}
+
+// helper function to insert new record, it assigns generated _id to records
+// without it and returns inserted record
+func insertRecord(col *tiedodb.Col, record map[string]any) (map[string]any, error) {
+	nrec := make(map[string]any, len(record)+1)
+	for k, v := range record {
+		nrec[k] = v
+	}
+	if id, ok := nrec["_id"]; !ok || id == nil || id == "" {
+		nrec["_id"] = newID()
+	} else {
+		// look-up existing record via _id index
+		res := make(map[int]struct{})
+		lookup := map[string]any{"eq": id, "in": []any{"_id"}}
+		if err := tiedodb.EvalQuery(lookup, col, &res); err != nil {
+			return nil, err
+		}
+		for docID := range res {
+			if doc, err := col.Read(docID); err == nil && query.Compare(doc["_id"], id) == 0 {
+				return nil, fmt.Errorf("%w: _id %v", ErrDuplicateKey, id)
+			}
+		}
+	}
+	_, err := col.Insert(nrec)
+	return nrec, err
 }
 
 // Insert records into document-oriented db
 func Insert(dbname, collname string, records []map[string]any) {
-	Upsert(dbname, collname, "", records)
+	if err := InsertContext(context.TODO(), dbname, collname, records); err != nil {
+		log.Println("ERROR:", err)
+	}
+}
+
+// InsertContext inserts records into TiedotDB
+func InsertContext(ctx context.Context, dbname, collname string, records []map[string]any) error {
+	if err := upsertContext(ctx, dbname, collname, "", records); err != nil {
+		return fmt.Errorf("[golib.tiedot.Insert] upsert error: %w", err)
+	}
+	return nil
 }
 
 // Upsert records into document-oriented db
 func Upsert(dbname, collname, attr string, records []map[string]any) error {
+	return UpsertContext(context.TODO(), dbname, collname, attr, records)
+}
+
+// UpsertContext upserts records into TiedotDB. It follows MongoDB semantics:
+// existing record is looked up by given attribute value and new values are
+// merged into it, otherwise new record with generated _id is inserted.
+// Records without attribute value are skipped.
+func UpsertContext(ctx context.Context, dbname, collname, attr string, records []map[string]any) error {
+	if err := upsertContext(ctx, dbname, collname, attr, records); err != nil {
+		return fmt.Errorf("[golib.tiedot.Upsert] upsert error: %w", err)
+	}
+	return nil
+}
+
+// helper function to upsert records using given attribute, empty attribute
+// means plain insert of records
+func upsertContext(ctx context.Context, dbname, collname, attr string, records []map[string]any) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	col, err := collection(dbname, collname)
+	if err != nil {
+		return fmt.Errorf("failed to get collection: %v", err)
+	}
+	for _, record := range records {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if attr == "" {
+			rec, err := insertRecord(col, record)
+			if err != nil {
+				return fmt.Errorf("failed to insert record: %w", err)
+			}
+			changeLog.Publish(changes.Event(changes.Insert, dbname, collname, rec))
+			continue
+		}
+		value, ok := record[attr]
+		if !ok || value == nil || value == "" {
+			continue
+		}
+		// update first matched record as MongoDB UpdateOne does
+		docID := -1
+		var doc map[string]any
+		err := scan(ctx, col, map[string]any{attr: value}, func(id int, rec map[string]any) bool {
+			docID, doc = id, rec
+			return false
+		})
+		if err != nil {
+			return fmt.Errorf("failed to find record: %v", err)
+		}
+		if docID < 0 {
+			rec, err := insertRecord(col, record)
+			if err != nil {
+				return fmt.Errorf("failed to insert record: %w", err)
+			}
+			changeLog.Publish(changes.Event(changes.Insert, dbname, collname, rec))
+			continue
+		}
+		for k, v := range record {
+			if k != "_id" {
+				doc[k] = v
+			}
+		}
+		if err := col.Update(docID, doc); err != nil {
+			return fmt.Errorf("failed to upsert record: %v", err)
+		}
+		changeLog.Publish(changes.Event(changes.Update, dbname, collname, doc))
+	}
 	return nil
 }
 
 // Get records from document-oriented db
 func Get(dbname, collname string, spec map[string]any, idx, limit int) []map[string]any {
-	coll := db.Use(collname)
-	var results []map[string]any
-	row := make(map[string]any)
-	queryRes := make(map[int]struct{})
-	if err := tiedodb.EvalQuery(spec, coll, &queryRes); err != nil {
-		log.Fatal(err)
+	out, err := GetContext(context.TODO(), dbname, collname, spec, idx, limit)
+	if err != nil {
+		log.Println("ERROR:", err)
+	}
+	return out
+}
+
+// GetContext fetches records from TiedotDB for given spec and pagination,
+// records are returned in order of their insertion
+func GetContext(ctx context.Context, dbname, collname string, spec map[string]any, idx, limit int) ([]map[string]any, error) {
+	results, err := getSorted(ctx, dbname, collname, spec, nil, 1, idx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("[golib.tiedot.Get] scan error: %w", err)
 	}
-	results = append(results, row)
-	return results
+	return stripID(results), nil
+}
+
+// GetProjection records from document-oriented db
+func GetProjection(dbname, collname string, spec map[string]any, projection map[string]int, idx, limit int) []map[string]any {
+	out, err := GetProjectionContext(context.TODO(), dbname, collname, spec, projection, idx, limit)
+	if err != nil {
+		log.Println("ERROR:", err)
+	}
+	return out
+}
+
+// GetProjectionContext fetches records with given projection from TiedotDB
+func GetProjectionContext(ctx context.Context, dbname, collname string, spec map[string]any, projection map[string]int, idx, limit int) ([]map[string]any, error) {
+	results, err := getSorted(ctx, dbname, collname, spec, nil, 1, idx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("[golib.tiedot.GetProjection] scan error: %w", err)
+	}
+	// like other APIs do not return _id unless it is explicitly requested
+	proj := map[string]int{"_id": 0}
+	for k, v := range projection {
+		proj[k] = v
+	}
+	out, err := query.Project(results, proj)
+	if err != nil {
+		return nil, fmt.Errorf("[golib.tiedot.GetProjection] projection error: %w", err)
+	}
+	return out, nil
 }
 
 // Update inplace for given spec
 func Update(dbname, collname string, spec, newdata map[string]any) error {
-	// Not implemented yet
-	return nil
+	err := UpdateContext(context.TODO(), dbname, collname, spec, newdata)
+	if err != nil {
+		log.Println("ERROR:", err)
+	}
+	return err
+}
+
+// UpdateContext updates first record matching given spec in TiedotDB
+func UpdateContext(ctx context.Context, dbname, collname string, spec, newdata map[string]any) error {
+	_, _, err := UpdateCountContext(ctx, dbname, collname, spec, newdata)
+	return err
+}
+
+// UpdateCountContext updates first record matching given spec in TiedotDB,
+// as MongoDB UpdateOne does, and returns number of matched and modified records
+func UpdateCountContext(ctx context.Context, dbname, collname string, spec, newdata map[string]any) (int, int, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	col, err := collection(dbname, collname)
+	if err != nil {
+		return 0, 0, fmt.Errorf("[golib.tiedot.Update] collection error: %w", err)
+	}
+	docID := -1
+	var doc map[string]any
+	err = scan(ctx, col, spec, func(id int, rec map[string]any) bool {
+		docID, doc = id, rec
+		return false
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("[golib.tiedot.Update] scan error: %w", err)
+	}
+	if docID < 0 {
+		return 0, 0, nil
+	}
+	rec, err := query.ApplyUpdate(doc, newdata)
+	if err != nil {
+		return 0, 0, fmt.Errorf("[golib.tiedot.Update] update error: %w", err)
+	}
+	if query.Equal(doc, rec) {
+		return 1, 0, nil
+	}
+	if err := col.Update(docID, rec); err != nil {
+		return 0, 0, fmt.Errorf("[golib.tiedot.Update] col.Update error: %w", err)
+	}
+	changeLog.Publish(changes.Event(changes.Update, dbname, collname, rec))
+	return 1, 1, nil
 }
 
 // Count gets number records from document-oriented db
 func Count(dbname, collname string, spec map[string]any) int {
-	// Not implemented yet
-	return 0
+	nrec, err := CountContext(context.TODO(), dbname, collname, spec)
+	if err != nil {
+		log.Println("ERROR:", err)
+	}
+	return nrec
+}
+
+// CountContext counts records matching given spec in TiedotDB
+func CountContext(ctx context.Context, dbname, collname string, spec map[string]any) (int, error) {
+	col, err := collection(dbname, collname)
+	if err != nil {
+		return 0, fmt.Errorf("[golib.tiedot.Count] collection error: %w", err)
+	}
+	nrec := 0
+	err = scan(ctx, col, spec, func(id int, rec map[string]any) bool {
+		nrec++
+		return true
+	})
+	if err != nil {
+		return 0, fmt.Errorf("[golib.tiedot.Count] scan error: %w", err)
+	}
+	return nrec, nil
 }
 
 // Remove records from document-oriented db
 func Remove(dbname, collname string, spec map[string]any) error {
-	// Not implemented yet
+	return RemoveContext(context.TODO(), dbname, collname, spec)
+}
+
+// RemoveContext removes records matching given spec from TiedotDB
+func RemoveContext(ctx context.Context, dbname, collname string, spec map[string]any) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	col, err := collection(dbname, collname)
+	if err != nil {
+		return fmt.Errorf("[golib.tiedot.Remove] collection error: %w", err)
+	}
+	docs := make(map[int]map[string]any)
+	err = scan(ctx, col, spec, func(id int, rec map[string]any) bool {
+		docs[id] = rec
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("[golib.tiedot.Remove] scan error: %w", err)
+	}
+	for id, rec := range docs {
+		if err := col.Delete(id); err != nil {
+			return fmt.Errorf("[golib.tiedot.Remove] col.Delete error: %w", err)
+		}
+		changeLog.Publish(changes.Event(changes.Delete, dbname, collname, rec))
+	}
 	return nil
 }
 
+// Watch returns channel of change events of TiedotDB records which belong to
+// given database/collection and match given spec, the channel is closed when
+// context is done
+func Watch(ctx context.Context, dbname, collname string, spec map[string]any) (<-chan map[string]any, error) {
+	return changeLog.Watch(ctx, dbname, collname, spec)
+}
+
 // Distinct gets number records from document-oriented db
 func Distinct(dbname, collname, field string) ([]any, error) {
+	return DistinctContext(context.TODO(), dbname, collname, field)
+}
+
+// DistinctContext returns unique values of given field in TiedotDB collection
+func DistinctContext(ctx context.Context, dbname, collname, field string) ([]any, error) {
 	var out []any
-	// Not implemented yet
+	col, err := collection(dbname, collname)
+	if err != nil {
+		return out, fmt.Errorf("[golib.tiedot.Distinct] collection error: %w", err)
+	}
+	// loop over records and collect unique values of the field
+	seen := make(map[string]bool)
+	err = scan(ctx, col, map[string]any{}, func(id int, rec map[string]any) bool {
+		if val, ok := rec[field]; ok {
+			key := fmt.Sprintf("%v", val)
+			if !seen[key] {
+				seen[key] = true
+				out = append(out, val)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return out, fmt.Errorf("[golib.tiedot.Distinct] scan error: %w", err)
+	}
 	return out, nil
 }
 
 // InsertRecord insert record with given spec to document-oriented db
 func InsertRecord(dbname, collname string, rec map[string]any) error {
+	return InsertRecordContext(context.TODO(), dbname, collname, rec)
+}
+
+// InsertRecordContext inserts single record into TiedotDB
+func InsertRecordContext(ctx context.Context, dbname, collname string, rec map[string]any) error {
 	var records []map[string]any
 	records = append(records, rec)
-	return Upsert(dbname, collname, "", records)
+	return upsertContext(ctx, dbname, collname, "", records)
 }
 
 // GetSorted fetches records from document-oriented db sorted by given key with specific order
 func GetSorted(dbname, collname string, spec map[string]any, skeys []string, sortOrder, idx, limit int) []map[string]any {
-	return Get(dbname, collname, spec, idx, limit)
+	out, err := GetSortedContext(context.TODO(), dbname, collname, spec, skeys, sortOrder, idx, limit)
+	if err != nil {
+		log.Println("ERROR:", err)
+	}
+	return out
+}
+
+// GetSortedContext fetches records from TiedotDB sorted by given keys with
+// specific order, it keeps at most idx+limit records in memory
+func GetSortedContext(ctx context.Context, dbname, collname string, spec map[string]any, skeys []string, sortOrder, idx, limit int) ([]map[string]any, error) {
+	results, err := getSorted(ctx, dbname, collname, spec, skeys, sortOrder, idx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("[golib.tiedot.GetSorted] scan error: %w", err)
+	}
+	return stripID(results), nil
+}
+
+// helper function to fetch records within [idx, idx+limit) window sorted by
+// given keys, records with equal keys are ordered by their _id, i.e. by
+// insertion time, since tiedot iterates documents in random order
+func getSorted(ctx context.Context, dbname, collname string, spec map[string]any, skeys []string, sortOrder, idx, limit int) ([]map[string]any, error) {
+	col, err := collection(dbname, collname)
+	if err != nil {
+		return nil, err
+	}
+	topk := query.NewTopK(skeys, sortOrder, idx, limit)
+	topk.SetTieBreaker("_id")
+	err = scan(ctx, col, spec, func(id int, rec map[string]any) bool {
+		topk.Push(rec)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return topk.Records(), nil
 }
@@ -0,0 +1,101 @@
+package embed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func setupTiedotDB(t *testing.T) {
+	if err := InitDB(t.TempDir()); err != nil {
+		t.Fatalf("Failed to initialize TiedotDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+}
+
+func TestUpsertAndGet(t *testing.T) {
+	setupTiedotDB(t)
+
+	records := []map[string]any{
+		{"did": "/a", "energy": 10},
+		{"did": "/b", "energy": 20},
+		{"energy": 30},
+	}
+	if err := Upsert("test", "meta", "did", records); err != nil {
+		t.Fatalf("Failed to upsert records: %v", err)
+	}
+	if err := Upsert("test", "meta", "did", []map[string]any{{"did": "/a", "cycle": "2024-3"}}); err != nil {
+		t.Fatalf("Failed to upsert records: %v", err)
+	}
+	results := Get("test", "meta", map[string]any{"did": "/a"}, 0, 0)
+	if len(results) != 1 || results[0]["energy"] != 10.0 || results[0]["cycle"] != "2024-3" {
+		t.Fatalf("Wrong upserted records %v", results)
+	}
+	if nrec := Count("test", "meta", map[string]any{}); nrec != 2 {
+		t.Fatalf("Expected 2 records, got %d", nrec)
+	}
+	if nrec := Count("other", "meta", map[string]any{}); nrec != 0 {
+		t.Fatalf("Expected 0 records in other database, got %d", nrec)
+	}
+}
+
+func TestInsertRecord(t *testing.T) {
+	setupTiedotDB(t)
+
+	if err := InsertRecord("test", "meta", map[string]any{"_id": "abc", "did": "/a"}); err != nil {
+		t.Fatalf("Failed to insert record: %v", err)
+	}
+	err := InsertRecord("test", "meta", map[string]any{"_id": "abc", "did": "/b"})
+	if !errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("Expected ErrDuplicateKey error, got %v", err)
+	}
+	cctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := CountContext(cctx, "test", "meta", map[string]any{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled error, got %v", err)
+	}
+}
+
+func TestUpdateRemoveAndSort(t *testing.T) {
+	setupTiedotDB(t)
+
+	var records []map[string]any
+	for i := 0; i < 10; i++ {
+		records = append(records, map[string]any{"id": i, "group": i % 2})
+	}
+	Insert("test", "meta", records)
+
+	// records with equal sort values keep order of their insertion
+	results := GetSorted("test", "meta", map[string]any{}, []string{"group"}, -1, 1, 3)
+	if got := fmt.Sprint(ids(results)); got != "[3 5 7]" {
+		t.Fatalf("Wrong sorted records %v", got)
+	}
+	// like MongoDB UpdateOne only first matched record is updated
+	matched, modified, err := UpdateCountContext(context.Background(), "test", "meta", map[string]any{"group": 1}, map[string]any{"odd": true})
+	if err != nil {
+		t.Fatalf("Failed to update records: %v", err)
+	}
+	if nrec := Count("test", "meta", map[string]any{"odd": true}); nrec != 1 || matched != 1 || modified != 1 {
+		t.Fatalf("Expected 1 updated record, got %d, matched %d, modified %d", nrec, matched, modified)
+	}
+	if err := Remove("test", "meta", map[string]any{"id": map[string]any{"$lt": 4}}); err != nil {
+		t.Fatalf("Failed to remove records: %v", err)
+	}
+	if got := fmt.Sprint(ids(Get("test", "meta", map[string]any{}, 0, 0))); got != "[4 5 6 7 8 9]" {
+		t.Fatalf("Wrong records after removal %v", got)
+	}
+	values, err := Distinct("test", "meta", "group")
+	if err != nil || len(values) != 2 {
+		t.Fatalf("Expected 2 distinct values, got %v, error %v", values, err)
+	}
+}
+
+// helper function to return ids of records
+func ids(records []map[string]any) []any {
+	var out []any
+	for _, rec := range records {
+		out = append(out, rec["id"])
+	}
+	return out
+}
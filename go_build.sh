@@ -2,24 +2,17 @@
 
 set -e
 
+module=$(go list -m)
 for d in $(go list ./... | grep -v vendor); do
     echo "Building $d"
-    if [ "$d" == "github.com/CHESSComputing/golib/gonexus/integration/gotest" ]; then
+    if [ "$d" == "$module/gonexus/integration/gotest" ]; then
         continue
     fi
-    bdir=`echo $d | awk '{z=split($0,a,"/"); print a[z]}'`
-    if [ "$bdir" == "badger" ]; then
-        bdir="embed/badger"
-    fi
-    if [ "$bdir" == "clover" ]; then
-        bdir="embed/clover"
-    fi
-    if [ "$bdir" == "tiedot" ]; then
-        bdir="embed/tiedot"
-    fi
-    if [ "$bdir" == "gonexus" ]; then
+    if [ "$d" == "$module/gonexus" ]; then
         continue
     fi
+    # package directory relative to module root, e.g. embed/changes
+    bdir=${d#$module/}
     echo "cd $bdir"
     cd $bdir
     go build
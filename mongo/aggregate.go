@@ -0,0 +1,158 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	bson "go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Pipeline represents MongoDB aggregation pipeline, e.g.
+//
+//	pipeline := Pipeline{
+//		Match(map[string]any{"beamline": "3a"}),
+//		Group("$cycle", map[string]Accumulator{"count": Sum(1), "btrs": AddToSet("$btr")}),
+//		Sort([]string{"count"}, -1),
+//		Limit(10),
+//	}
+//	records, err := Aggregate(dbname, collname, pipeline)
+type Pipeline []map[string]any
+
+// Accumulator represents accumulator expression of $group and $bucket stages
+type Accumulator map[string]any
+
+// Aggregate runs aggregation pipeline on given database/collection
+func Aggregate(dbname, collname string, pipeline []map[string]any) ([]map[string]any, error) {
+	return AggregateContext(context.TODO(), dbname, collname, pipeline)
+}
+
+// AggregateContext runs aggregation pipeline on given database/collection
+func AggregateContext(ctx context.Context, dbname, collname string, pipeline []map[string]any) ([]map[string]any, error) {
+	out := []map[string]any{}
+	c, err := collection(dbname, collname)
+	if err != nil {
+		return out, fmt.Errorf("[golib.mongo.Aggregate] collection error: %w", err)
+	}
+	cur, err := c.Aggregate(ctx, pipeline)
+	if err != nil {
+		log.Printf("ERROR: pipeline=%+v, error=%v", pipeline, err)
+		return out, fmt.Errorf("[golib.mongo.Aggregate] c.Aggregate error: %w", err)
+	}
+	if err := cur.All(ctx, &out); err != nil {
+		return out, fmt.Errorf("[golib.mongo.Aggregate] cur.All error: %w", err)
+	}
+	return out, nil
+}
+
+// Match returns $match stage which filters records with given spec
+func Match(spec map[string]any) map[string]any {
+	return map[string]any{"$match": spec}
+}
+
+// Group returns $group stage which groups records by given _id expression,
+// e.g. "$beamline", and computes given accumulators of every group
+func Group(id any, fields map[string]Accumulator) map[string]any {
+	group := map[string]any{"_id": id}
+	for name, acc := range fields {
+		group[name] = map[string]any(acc)
+	}
+	return map[string]any{"$group": group}
+}
+
+// Facet returns $facet stage which runs given sub-pipelines on the same
+// records and returns single record with their results
+func Facet(facets map[string]Pipeline) map[string]any {
+	facet := make(map[string]any, len(facets))
+	for name, pipeline := range facets {
+		facet[name] = []map[string]any(pipeline)
+	}
+	return map[string]any{"$facet": facet}
+}
+
+// Bucket returns $bucket stage which groups records into buckets defined by
+// given ascending boundaries. Records outside of boundaries fall into default
+// bucket, if default is nil such records cause an error. If output is nil
+// every bucket contains count of its records.
+func Bucket(groupBy any, boundaries []any, defaultBucket any, output map[string]Accumulator) map[string]any {
+	bucket := map[string]any{"groupBy": groupBy, "boundaries": boundaries}
+	if defaultBucket != nil {
+		bucket["default"] = defaultBucket
+	}
+	if output != nil {
+		out := make(map[string]any, len(output))
+		for name, acc := range output {
+			out[name] = map[string]any(acc)
+		}
+		bucket["output"] = out
+	}
+	return map[string]any{"$bucket": bucket}
+}
+
+// SortByCount returns $sortByCount stage which groups records by given
+// expression and sorts groups by their count in descending order
+func SortByCount(expr any) map[string]any {
+	return map[string]any{"$sortByCount": expr}
+}
+
+// Sort returns $sort stage which sorts records by given keys and order
+func Sort(keys []string, order int) map[string]any {
+	if order != -1 {
+		order = 1
+	}
+	sort := bson.D{}
+	for _, key := range keys {
+		sort = append(sort, bson.E{Key: key, Value: order})
+	}
+	return map[string]any{"$sort": sort}
+}
+
+// Skip returns $skip stage
+func Skip(n int) map[string]any {
+	return map[string]any{"$skip": n}
+}
+
+// Limit returns $limit stage
+func Limit(n int) map[string]any {
+	return map[string]any{"$limit": n}
+}
+
+// Sum returns $sum accumulator, use Sum(1) to count records
+func Sum(expr any) Accumulator {
+	return Accumulator{"$sum": expr}
+}
+
+// Avg returns $avg accumulator
+func Avg(expr any) Accumulator {
+	return Accumulator{"$avg": expr}
+}
+
+// Min returns $min accumulator
+func Min(expr any) Accumulator {
+	return Accumulator{"$min": expr}
+}
+
+// Max returns $max accumulator
+func Max(expr any) Accumulator {
+	return Accumulator{"$max": expr}
+}
+
+// First returns $first accumulator
+func First(expr any) Accumulator {
+	return Accumulator{"$first": expr}
+}
+
+// Last returns $last accumulator
+func Last(expr any) Accumulator {
+	return Accumulator{"$last": expr}
+}
+
+// Push returns $push accumulator
+func Push(expr any) Accumulator {
+	return Accumulator{"$push": expr}
+}
+
+// AddToSet returns $addToSet accumulator
+func AddToSet(expr any) Accumulator {
+	return Accumulator{"$addToSet": expr}
+}
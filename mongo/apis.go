@@ -1,5 +1,10 @@
 package mongo
 
+import (
+	"context"
+	"fmt"
+)
+
 // MongoDB represents MongoDB interface with MongoDB backend
 type MongoDB struct {
 }
@@ -58,3 +63,92 @@ func (d *MongoDB) InsertRecord(dbname, collname string, rec map[string]any) erro
 func (d *MongoDB) GetSorted(dbname, collname string, spec map[string]any, skeys []string, sortOrder, idx, limit int) []map[string]any {
 	return GetSorted(dbname, collname, spec, skeys, sortOrder, idx, limit)
 }
+
+// MongoDBV2 represents context-aware MongoDB interface with MongoDB backend
+type MongoDBV2 struct {
+}
+
+// InitDB initializes MongoDB backend
+func (d *MongoDBV2) InitDB(ctx context.Context, uri string) error {
+	InitMongoDB(uri)
+	if _, err := Mongo.ConnectWithError(); err != nil {
+		return fmt.Errorf("[golib.mongo.MongoDBV2.InitDB] connect error: %w", err)
+	}
+	return nil
+}
+
+// Insert inserts records into provided database/collection
+func (d *MongoDBV2) Insert(ctx context.Context, dbname, collname string, records []map[string]any) error {
+	return InsertContext(ctx, dbname, collname, records)
+}
+
+// Upsert inserts records into provided database/collection and attribute
+func (d *MongoDBV2) Upsert(ctx context.Context, dbname, collname, attr string, records []map[string]any) error {
+	return UpsertContext(ctx, dbname, collname, attr, records)
+}
+
+// Get fetches data from underlying database/collection
+func (d *MongoDBV2) Get(ctx context.Context, dbname, collname string, spec map[string]any, idx, limit int) ([]map[string]any, error) {
+	return GetContext(ctx, dbname, collname, spec, idx, limit)
+}
+
+// GetProjection fetches data from underlying database/collection
+func (d *MongoDBV2) GetProjection(ctx context.Context, dbname, collname string, spec map[string]any, projection map[string]int, idx, limit int) ([]map[string]any, error) {
+	return GetProjectionContext(ctx, dbname, collname, spec, projection, idx, limit)
+}
+
+// Update updates data into given database/collection
+func (d *MongoDBV2) Update(ctx context.Context, dbname, collname string, spec, newdata map[string]any) error {
+	return UpdateContext(ctx, dbname, collname, spec, newdata)
+}
+
+// UpdateCount updates data into given database/collection and returns number
+// of matched and modified records
+func (d *MongoDBV2) UpdateCount(ctx context.Context, dbname, collname string, spec, newdata map[string]any) (int, int, error) {
+	return UpdateCountContext(ctx, dbname, collname, spec, newdata)
+}
+
+// Count returns total number of records within database/collection and given spec
+func (d *MongoDBV2) Count(ctx context.Context, dbname, collname string, spec map[string]any) (int, error) {
+	return CountContext(ctx, dbname, collname, spec)
+}
+
+// Remove deletes records in given database/collection using given spec
+func (d *MongoDBV2) Remove(ctx context.Context, dbname, collname string, spec map[string]any) error {
+	return RemoveContext(ctx, dbname, collname, spec)
+}
+
+// Distinct returns distinct collection of records
+func (d *MongoDBV2) Distinct(ctx context.Context, dbname, collname, field string) ([]any, error) {
+	return DistinctContext(ctx, dbname, collname, field)
+}
+
+// InsertRecord inserts single record into given database/collection
+func (d *MongoDBV2) InsertRecord(ctx context.Context, dbname, collname string, rec map[string]any) error {
+	return InsertRecordContext(ctx, dbname, collname, rec)
+}
+
+// GetSorted returns sorted records from given database/collection using provided spec, sorted keys, order and limits
+func (d *MongoDBV2) GetSorted(ctx context.Context, dbname, collname string, spec map[string]any, skeys []string, sortOrder, idx, limit int) ([]map[string]any, error) {
+	return GetSortedContext(ctx, dbname, collname, spec, skeys, sortOrder, idx, limit)
+}
+
+// WithTransaction runs given function within MongoDB transaction
+func (d *MongoDBV2) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return WithTransaction(ctx, fn)
+}
+
+// Watch returns channel of change events of given database/collection
+func (d *MongoDBV2) Watch(ctx context.Context, dbname, collname string, spec map[string]any) (<-chan map[string]any, error) {
+	return Watch(ctx, dbname, collname, spec)
+}
+
+// Aggregate runs aggregation pipeline on given database/collection
+func (d *MongoDBV2) Aggregate(ctx context.Context, dbname, collname string, pipeline []map[string]any) ([]map[string]any, error) {
+	return AggregateContext(ctx, dbname, collname, pipeline)
+}
+
+// Ping verifies that MongoDB server is reachable
+func (d *MongoDBV2) Ping(ctx context.Context) error {
+	return Ping(ctx)
+}
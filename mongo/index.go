@@ -0,0 +1,224 @@
+package mongo
+
+// index module provides declarative management of MongoDB indexes
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	bson "go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// IndexSpec represents declarative specification of MongoDB index, e.g.
+//
+//	specs := []IndexSpec{
+//		{Fields: []string{"did"}, Unique: true},
+//		{Fields: []string{"beamline", "-date"}},
+//		{Fields: []string{"created"}, TTL: 30 * 24 * time.Hour},
+//		{Fields: []string{"description", "sample"}, Text: true},
+//	}
+//	err := EnsureIndexes(ctx, dbname, collname, specs)
+type IndexSpec struct {
+	Name   string        // index name, generated from fields if not set
+	Fields []string      // indexed fields, "-" prefix defines descending order
+	Unique bool          // unique index
+	TTL    time.Duration // expire records after given duration since date of the field
+	Text   bool          // text index of given fields
+}
+
+// keys returns ordered index keys of index spec
+func (s IndexSpec) keys() (bson.D, error) {
+	if len(s.Fields) == 0 {
+		return nil, fmt.Errorf("index without fields")
+	}
+	if s.TTL != 0 && (len(s.Fields) != 1 || s.Text || s.TTL < time.Second) {
+		return nil, fmt.Errorf("TTL index requires single field and at least 1s duration, got %+v", s)
+	}
+	var keys bson.D
+	for _, field := range s.Fields {
+		var order any = 1
+		if strings.HasPrefix(field, "-") {
+			if s.Text {
+				return nil, fmt.Errorf("text index does not support order of field %s", field)
+			}
+			field, order = field[1:], -1
+		}
+		if s.Text {
+			order = "text"
+		}
+		if field == "" {
+			return nil, fmt.Errorf("empty field name in %+v", s)
+		}
+		keys = append(keys, bson.E{Key: field, Value: order})
+	}
+	return keys, nil
+}
+
+// name returns index name, by default it follows MongoDB naming, e.g. did_1
+func (s IndexSpec) name(keys bson.D) string {
+	if s.Name != "" {
+		return s.Name
+	}
+	var parts []string
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s_%v", k.Key, k.Value))
+	}
+	return strings.Join(parts, "_")
+}
+
+// model returns MongoDB index model of index spec
+func (s IndexSpec) model() (mongo.IndexModel, error) {
+	keys, err := s.keys()
+	if err != nil {
+		return mongo.IndexModel{}, err
+	}
+	opts := options.Index().SetName(s.name(keys))
+	if s.Unique {
+		opts.SetUnique(true)
+	}
+	if s.TTL != 0 {
+		opts.SetExpireAfterSeconds(int32(s.TTL / time.Second))
+	}
+	return mongo.IndexModel{Keys: keys, Options: opts}, nil
+}
+
+// matches checks if existing index, as returned by listIndexes command,
+// has the same keys and options as index spec
+func (s IndexSpec) matches(index bson.M) bool {
+	keys, err := s.keys()
+	if err != nil {
+		return false
+	}
+	unique, _ := index["unique"].(bool)
+	if unique != s.Unique {
+		return false
+	}
+	ttl, hasTTL := index["expireAfterSeconds"]
+	if hasTTL != (s.TTL != 0) || (hasTTL && fmt.Sprint(ttl) != fmt.Sprint(int64(s.TTL/time.Second))) {
+		return false
+	}
+	if s.Text {
+		// text indexes store their fields as weights
+		weights := document(index["weights"])
+		if len(weights) != len(keys) {
+			return false
+		}
+		for _, k := range keys {
+			if _, ok := weights[k.Key]; !ok {
+				return false
+			}
+		}
+		return true
+	}
+	var ikeys bson.D
+	switch v := index["key"].(type) {
+	case bson.D:
+		ikeys = v
+	case bson.M:
+		// bson.M does not keep order, compare single key indexes only
+		for k, e := range v {
+			ikeys = append(ikeys, bson.E{Key: k, Value: e})
+		}
+		if len(ikeys) > 1 {
+			return false
+		}
+	}
+	if len(ikeys) != len(keys) {
+		return false
+	}
+	for i, k := range keys {
+		if ikeys[i].Key != k.Key || fmt.Sprint(ikeys[i].Value) != fmt.Sprint(k.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// helper function to convert BSON document into map
+func document(val any) map[string]any {
+	switch v := val.(type) {
+	case bson.M:
+		return v
+	case map[string]any:
+		return v
+	case bson.D:
+		out := make(map[string]any, len(v))
+		for _, e := range v {
+			out[e.Key] = e.Value
+		}
+		return out
+	}
+	return nil
+}
+
+// EnsureIndexes makes indexes of given database/collection match given
+// specs. Missing indexes are created, indexes with the same name but
+// different keys or options are re-created while other existing indexes
+// are kept, therefore it is safe to call it at every service start-up.
+func EnsureIndexes(ctx context.Context, dbname, collname string, specs []IndexSpec) error {
+	c, err := collection(dbname, collname)
+	if err != nil {
+		return fmt.Errorf("[golib.mongo.EnsureIndexes] collection error: %w", err)
+	}
+	cur, err := c.Indexes().List(ctx, options.ListIndexes())
+	if err != nil {
+		return fmt.Errorf("[golib.mongo.EnsureIndexes] list indexes error: %w", err)
+	}
+	var indexes []bson.M
+	if err := cur.All(ctx, &indexes); err != nil {
+		return fmt.Errorf("[golib.mongo.EnsureIndexes] cur.All error: %w", err)
+	}
+	existing := make(map[string]bson.M, len(indexes))
+	for _, index := range indexes {
+		if name, ok := index["name"].(string); ok {
+			existing[name] = index
+		}
+	}
+	var models []mongo.IndexModel
+	for _, spec := range specs {
+		model, err := spec.model()
+		if err != nil {
+			return fmt.Errorf("[golib.mongo.EnsureIndexes] index spec error: %w", err)
+		}
+		name := spec.name(model.Keys.(bson.D))
+		if index, ok := existing[name]; ok {
+			if spec.matches(index) {
+				continue
+			}
+			log.Printf("re-create index %s of %s.%s", name, dbname, collname)
+			if err := c.Indexes().DropOne(ctx, name); err != nil {
+				return fmt.Errorf("[golib.mongo.EnsureIndexes] drop index %s error: %w", name, err)
+			}
+		} else if matchAny(spec, indexes) {
+			// the same index exists under different name
+			continue
+		}
+		models = append(models, model)
+	}
+	if len(models) == 0 {
+		return nil
+	}
+	names, err := c.Indexes().CreateMany(ctx, models)
+	if err != nil {
+		return fmt.Errorf("[golib.mongo.EnsureIndexes] create indexes error: %w", err)
+	}
+	sort.Strings(names)
+	log.Printf("created indexes %v of %s.%s", names, dbname, collname)
+	return nil
+}
+
+// helper function to check if any of given indexes matches index spec
+func matchAny(spec IndexSpec, indexes []bson.M) bool {
+	for _, index := range indexes {
+		if spec.matches(index) {
+			return true
+		}
+	}
+	return false
+}
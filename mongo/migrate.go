@@ -0,0 +1,135 @@
+package mongo
+
+// migrate module provides versioned migrations of MongoDB records
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	bson "go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// MigrationsCollection defines collection which keeps applied migrations
+var MigrationsCollection = "migrations"
+
+// Migration represents versioned change of database records or schema
+type Migration struct {
+	Version     int                                                 // migration version, migrations are applied in ascending order
+	Description string                                              // migration description
+	Up          func(ctx context.Context, db *mongo.Database) error // migration function
+}
+
+// Migrate applies pending migrations to given database in order of their
+// versions and records every applied migration in MigrationsCollection,
+// execution stops at first failed migration. In dry-run mode pending
+// migrations are returned without being applied. It returns list of
+// applied (or pending in dry-run mode) migrations, e.g.
+//
+//	migrations := []Migration{
+//		{Version: 1, Description: "add beamline index", Up: func(ctx context.Context, db *mongo.Database) error {
+//			_, err := db.Collection("meta").Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "beamline", Value: 1}}})
+//			return err
+//		}},
+//	}
+//	applied, err := Migrate(ctx, dbname, migrations, false)
+//
+// Migrations are not atomic, therefore migration functions should be safe
+// to run again if they fail in the middle.
+func Migrate(ctx context.Context, dbname string, migrations []Migration, dryRun bool) ([]Migration, error) {
+	c, err := collection(dbname, MigrationsCollection)
+	if err != nil {
+		return nil, fmt.Errorf("[golib.mongo.Migrate] collection error: %w", err)
+	}
+	versions, err := appliedVersions(ctx, c)
+	if err != nil {
+		return nil, fmt.Errorf("[golib.mongo.Migrate] applied migrations error: %w", err)
+	}
+	pending, err := pendingMigrations(migrations, versions)
+	if err != nil {
+		return nil, fmt.Errorf("[golib.mongo.Migrate] error: %w", err)
+	}
+	if dryRun {
+		for _, m := range pending {
+			log.Printf("pending migration %d of %s: %s", m.Version, dbname, m.Description)
+		}
+		return pending, nil
+	}
+	if len(pending) > 0 {
+		// unique index prevents recording the same migration twice
+		_, err := c.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "version", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("[golib.mongo.Migrate] create index error: %w", err)
+		}
+	}
+	var applied []Migration
+	for _, m := range pending {
+		log.Printf("apply migration %d of %s: %s", m.Version, dbname, m.Description)
+		time0 := time.Now()
+		if err := m.Up(ctx, c.Database()); err != nil {
+			return applied, fmt.Errorf("[golib.mongo.Migrate] migration %d error: %w", m.Version, err)
+		}
+		rec := bson.M{
+			"version":     m.Version,
+			"description": m.Description,
+			"applied":     time.Now().UTC(),
+			"duration":    time.Since(time0).Seconds(),
+		}
+		if _, err := c.InsertOne(ctx, rec); err != nil {
+			return applied, fmt.Errorf("[golib.mongo.Migrate] record migration %d error: %w", m.Version, err)
+		}
+		applied = append(applied, m)
+	}
+	return applied, nil
+}
+
+// helper function to get versions of applied migrations
+func appliedVersions(ctx context.Context, c *mongo.Collection) (map[int]bool, error) {
+	cur, err := c.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"version": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var records []bson.M
+	if err := cur.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	versions := make(map[int]bool, len(records))
+	for _, rec := range records {
+		switch v := rec["version"].(type) {
+		case int32:
+			versions[int(v)] = true
+		case int64:
+			versions[int(v)] = true
+		}
+	}
+	return versions, nil
+}
+
+// helper function to return sorted migrations which are not applied yet
+func pendingMigrations(migrations []Migration, applied map[int]bool) ([]Migration, error) {
+	seen := make(map[int]bool, len(migrations))
+	var pending []Migration
+	for _, m := range migrations {
+		if m.Up == nil {
+			return nil, fmt.Errorf("migration %d has no function", m.Version)
+		}
+		if seen[m.Version] {
+			return nil, fmt.Errorf("duplicate migration version %d", m.Version)
+		}
+		seen[m.Version] = true
+		if !applied[m.Version] {
+			pending = append(pending, m)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Version < pending[j].Version
+	})
+	return pending, nil
+}
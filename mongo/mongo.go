@@ -18,8 +18,8 @@ import (
 	"html"
 	"log"
 	"strings"
-	"time"
 
+	srvConfig "github.com/CHESSComputing/golib/config"
 	bson "go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
@@ -183,35 +183,72 @@ func GetInt64Value(rec map[string]any, key string) (int64, error) {
 
 // Connection defines connection to MongoDB
 type Connection struct {
-	Client *mongo.Client
-	URI    string
+	Client  *mongo.Client
+	URI     string
+	Options ClientOptions
+	stats   *poolCounters
 }
 
-// InitMongoDB initializes MongoDB connection object
+// InitMongoDB initializes MongoDB connection object, it keeps client options
+// of existing connection with the same URI or uses options of MongoDB
+// configuration with given URI
 func InitMongoDB(uri string) {
-	Mongo = Connection{URI: uri}
+	opts := Mongo.Options
+	if Mongo.URI != uri {
+		opts = ConfigClientOptions(uri)
+	}
+	Mongo = Connection{URI: uri, Options: opts}
+}
+
+// InitMongoDBConfig initializes MongoDB connection object with URI, pool
+// size, timeouts, read preference and write concern of given configuration
+func InitMongoDBConfig(cfg srvConfig.MongoDB) {
+	Mongo = Connection{URI: cfg.DBUri, Options: ClientOptionsFromConfig(cfg)}
 }
 
 // Connect provides connection to MongoDB
 func (m *Connection) Connect() *mongo.Client {
-	var err error
+	client, err := m.ConnectWithError()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return client
+}
+
+// ConnectWithError provides connection to MongoDB and returns error to the caller
+// instead of terminating the process
+func (m *Connection) ConnectWithError() (*mongo.Client, error) {
 	if m.Client != nil {
-		return m.Client
+		return m.Client, nil
+	}
+	if m.stats == nil {
+		m.stats = &poolCounters{}
 	}
 	opts := options.Client().ApplyURI(m.URI)
-	timeout := time.Duration(10) * time.Second
-	opts.Timeout = &timeout
+	if err := m.Options.apply(opts); err != nil {
+		return nil, fmt.Errorf("[golib.mongo.Connect] client options error: %w", err)
+	}
+	opts.SetPoolMonitor(m.stats.monitor())
 	client, err := mongo.Connect(opts)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("[golib.mongo.Connect] mongo.Connect error: %w", err)
 	}
 	m.Client = client
-	return client
+	return client, nil
 }
 
 // Mongo holds MongoDB connection
 var Mongo Connection
 
+// helper function to get MongoDB collection for given database and collection names
+func collection(dbname, collname string) (*mongo.Collection, error) {
+	client, err := Mongo.ConnectWithError()
+	if err != nil {
+		return nil, err
+	}
+	return client.Database(dbname).Collection(collname), nil
+}
+
 // InsertAny insert records into MongoDB
 func InsertAny(dbname, collname string, records []any) {
 	client := Mongo.Connect()
@@ -243,21 +280,40 @@ func UpsertAny(dbname, collname string, records []any) error {
 
 // Insert records into MongoDB
 func Insert(dbname, collname string, records []map[string]any) {
-	client := Mongo.Connect()
-	ctx := context.TODO()
-	c := client.Database(dbname).Collection(collname)
+	if err := InsertContext(context.TODO(), dbname, collname, records); err != nil {
+		log.Println("ERROR:", err)
+	}
+}
+
+// InsertContext inserts records into MongoDB and reports first insert error
+func InsertContext(ctx context.Context, dbname, collname string, records []map[string]any) error {
+	c, err := collection(dbname, collname)
+	if err != nil {
+		return fmt.Errorf("[golib.mongo.InsertContext] collection error: %w", err)
+	}
+	var ierr error
 	for _, rec := range records {
 		if _, err := c.InsertOne(ctx, &rec); err != nil {
 			log.Printf("Fail to insert record %v, error %v\n", rec, err)
+			if ierr == nil {
+				ierr = fmt.Errorf("[golib.mongo.InsertContext] c.InsertOne error: %w", err)
+			}
 		}
 	}
+	return ierr
 }
 
 // InsertRecord insert record with given spec to MongoDB
 func InsertRecord(dbname, collname string, rec map[string]any) error {
-	client := Mongo.Connect()
-	ctx := context.TODO()
-	c := client.Database(dbname).Collection(collname)
+	return InsertRecordContext(context.TODO(), dbname, collname, rec)
+}
+
+// InsertRecordContext insert record with given spec to MongoDB
+func InsertRecordContext(ctx context.Context, dbname, collname string, rec map[string]any) error {
+	c, err := collection(dbname, collname)
+	if err != nil {
+		return fmt.Errorf("[golib.mongo.InsertRecord] collection error: %w", err)
+	}
 	if _, err := c.InsertOne(ctx, &rec); err != nil {
 		log.Printf("Fail to insert record %v, error %v\n", rec, err)
 		return fmt.Errorf("[golib.mongo.InsertRecord] c.InsertOne error: %w", err)
@@ -280,12 +336,18 @@ func UpsertRecord(dbname, collname string, spec, rec map[string]any) error {
 
 // Upsert records into MongoDB
 func Upsert(dbname, collname, attr string, records []map[string]any) error {
-	client := Mongo.Connect()
-	ctx := context.TODO()
-	c := client.Database(dbname).Collection(collname)
+	return UpsertContext(context.TODO(), dbname, collname, attr, records)
+}
+
+// UpsertContext upserts records into MongoDB using given attribute as a record key
+func UpsertContext(ctx context.Context, dbname, collname, attr string, records []map[string]any) error {
+	c, err := collection(dbname, collname)
+	if err != nil {
+		return fmt.Errorf("[golib.mongo.Upsert] collection error: %w", err)
+	}
 	for _, rec := range records {
-		value := rec[attr].(string)
-		if value == "" {
+		value, ok := rec[attr]
+		if !ok || value == nil || value == "" {
 			continue
 		}
 		spec := bson.M{attr: value}
@@ -301,71 +363,89 @@ func Upsert(dbname, collname, attr string, records []map[string]any) error {
 
 // GetProjection records from MongoDB
 func GetProjection(dbname, collname string, spec map[string]any, projection map[string]int, idx, limit int) []map[string]any {
-	out := []map[string]any{}
-	client := Mongo.Connect()
-	ctx := context.TODO()
-	c := client.Database(dbname).Collection(collname)
-	var err error
-	proj := bson.M{}
+	out, err := GetProjectionContext(context.TODO(), dbname, collname, spec, projection, idx, limit)
+	if err != nil {
+		log.Printf("Unable to get records, error %v\n", err)
+	}
+	return out
+}
+
+// GetProjectionContext fetches records with given projection from MongoDB
+func GetProjectionContext(ctx context.Context, dbname, collname string, spec map[string]any, projection map[string]int, idx, limit int) ([]map[string]any, error) {
+	proj := bson.M{"_id": 0}
 	for k, v := range projection {
 		proj[k] = v
 	}
-	proj["_id"] = 0
+	opts := options.Find().SetSkip(int64(idx)).SetProjection(proj)
 	if limit > 0 {
-		opts := options.Find().SetSkip(int64(idx)).SetLimit(int64(limit)).SetProjection(proj)
-		cur, err := c.Find(ctx, spec, opts)
-		if err != nil {
-			log.Printf("ERROR: spec=%+v, error=%v", spec, err)
-		}
-		cur.All(ctx, &out)
-	} else {
-		opts := options.Find().SetSkip(int64(idx)).SetProjection(proj)
-		cur, err := c.Find(ctx, spec, opts)
-		if err != nil {
-			log.Printf("ERROR: spec=%+v, error=%v", spec, err)
-		}
-		cur.All(ctx, &out)
+		opts = opts.SetLimit(int64(limit))
 	}
+	out, err := find(ctx, dbname, collname, spec, opts)
 	if err != nil {
-		log.Printf("Unable to get records, error %v\n", err)
+		return out, fmt.Errorf("[golib.mongo.GetProjection] find error: %w", err)
 	}
-	return out
+	return out, nil
 }
 
 // Get records from MongoDB
 func Get(dbname, collname string, spec map[string]any, idx, limit int) []map[string]any {
-	out := []map[string]any{}
-	client := Mongo.Connect()
-	ctx := context.TODO()
-	c := client.Database(dbname).Collection(collname)
-	var err error
-	if limit > 0 {
-		opts := options.Find().SetSkip(int64(idx)).SetLimit(int64(limit)).SetProjection(bson.M{"_id": 0})
-		cur, err := c.Find(ctx, spec, opts)
-		if err != nil {
-			log.Printf("ERROR: spec=%+v, error=%v", spec, err)
-		}
-		cur.All(ctx, &out)
-	} else {
-		opts := options.Find().SetSkip(int64(idx)).SetProjection(bson.M{"_id": 0})
-		cur, err := c.Find(ctx, spec, opts)
-		if err != nil {
-			log.Printf("ERROR: spec=%+v, error=%v", spec, err)
-		}
-		cur.All(ctx, &out)
-	}
+	out, err := GetContext(context.TODO(), dbname, collname, spec, idx, limit)
 	if err != nil {
 		log.Printf("Unable to get records, error %v\n", err)
 	}
 	return out
 }
 
+// GetContext fetches records from MongoDB for given spec and pagination
+func GetContext(ctx context.Context, dbname, collname string, spec map[string]any, idx, limit int) ([]map[string]any, error) {
+	opts := options.Find().SetSkip(int64(idx)).SetProjection(bson.M{"_id": 0})
+	if limit > 0 {
+		opts = opts.SetLimit(int64(limit))
+	}
+	out, err := find(ctx, dbname, collname, spec, opts)
+	if err != nil {
+		return out, fmt.Errorf("[golib.mongo.Get] find error: %w", err)
+	}
+	return out, nil
+}
+
+// helper function to find records in MongoDB with given find options
+func find(ctx context.Context, dbname, collname string, spec map[string]any, opts *options.FindOptionsBuilder) ([]map[string]any, error) {
+	out := []map[string]any{}
+	c, err := collection(dbname, collname)
+	if err != nil {
+		return out, err
+	}
+	cur, err := c.Find(ctx, spec, opts)
+	if err != nil {
+		log.Printf("ERROR: spec=%+v, error=%v", spec, err)
+		return out, err
+	}
+	if err := cur.All(ctx, &out); err != nil {
+		return out, err
+	}
+	return out, nil
+}
+
 // GetSorted records from MongoDB sorted by given key with specific order
 func GetSorted(dbname, collname string, spec map[string]any, skeys []string, sortOrder, idx, limit int) []map[string]any {
-	out := []map[string]any{}
-	client := Mongo.Connect()
 	ctx := context.TODO()
-	c := client.Database(dbname).Collection(collname)
+	out, err := GetSortedContext(ctx, dbname, collname, spec, skeys, sortOrder, idx, limit)
+	if err != nil {
+		log.Printf("Unable to sort records, error %v\n", err)
+		// try to fetch all unsorted data
+		out, err = GetContext(ctx, dbname, collname, spec, 0, 0)
+		if err != nil {
+			log.Printf("Unable to find records, error %v\n", err)
+			out = append(out, ErrorRecord(fmt.Sprintf("%v", err), DBErrorName, DBError))
+			return out
+		}
+	}
+	return out
+}
+
+// GetSortedContext fetches records from MongoDB sorted by given keys with specific order
+func GetSortedContext(ctx context.Context, dbname, collname string, spec map[string]any, skeys []string, sortOrder, idx, limit int) ([]map[string]any, error) {
 	// Construct the sort options using the provided sort keys and sort order
 	sortOptions := bson.D{}
 	for _, key := range skeys {
@@ -375,22 +455,13 @@ func GetSorted(dbname, collname string, spec map[string]any, skeys []string, sor
 	// Define the find options with the constructed sortOptions
 	opts := options.Find().SetSort(sortOptions).SetSkip(int64(idx)).SetProjection(bson.M{"_id": 0})
 	if limit > 0 {
-		opts = opts.SetLimit(int64(limit)).SetProjection(bson.M{"_id": 0})
+		opts = opts.SetLimit(int64(limit))
 	}
-	cur, err := c.Find(ctx, spec, opts)
-	cur.All(ctx, &out)
+	out, err := find(ctx, dbname, collname, spec, opts)
 	if err != nil {
-		log.Printf("Unable to sort records, error %v\n", err)
-		// try to fetch all unsorted data
-		cur, err := c.Find(ctx, spec)
-		if err != nil {
-			log.Printf("Unable to find records, error %v\n", err)
-			out = append(out, ErrorRecord(fmt.Sprintf("%v", err), DBErrorName, DBError))
-			return out
-		}
-		cur.All(ctx, &out)
+		return out, fmt.Errorf("[golib.mongo.GetSorted] find error: %w", err)
 	}
-	return out
+	return out, nil
 }
 
 // helper function to present in bson selected fields
@@ -404,42 +475,71 @@ func sel(q ...string) (r map[string]any) {
 
 // Update inplace for given spec
 func Update(dbname, collname string, spec, newdata map[string]any) error {
-	client := Mongo.Connect()
-	ctx := context.TODO()
-	c := client.Database(dbname).Collection(collname)
-	_, err := c.UpdateOne(ctx, spec, newdata)
+	return UpdateContext(context.TODO(), dbname, collname, spec, newdata)
+}
+
+// UpdateContext updates inplace first record matching given spec
+func UpdateContext(ctx context.Context, dbname, collname string, spec, newdata map[string]any) error {
+	_, _, err := UpdateCountContext(ctx, dbname, collname, spec, newdata)
+	return err
+}
+
+// UpdateCountContext updates inplace first record matching given spec and
+// returns number of matched and modified records
+func UpdateCountContext(ctx context.Context, dbname, collname string, spec, newdata map[string]any) (int, int, error) {
+	c, err := collection(dbname, collname)
+	if err != nil {
+		return 0, 0, fmt.Errorf("[golib.mongo.Update] collection error: %w", err)
+	}
+	res, err := c.UpdateOne(ctx, spec, newdata)
 	if err != nil {
 		log.Printf("ERROR: Unable to update record, spec %v, data %v, error %v\n", spec, newdata, err)
-		return fmt.Errorf("[golib.mongo.Update] c.UpdateOne error: %w", err)
+		return 0, 0, fmt.Errorf("[golib.mongo.Update] c.UpdateOne error: %w", err)
 	}
-	return nil
+	return int(res.MatchedCount), int(res.ModifiedCount), nil
 }
 
 // Count gets number records from MongoDB
 func Count(dbname, collname string, spec map[string]any) int {
-	client := Mongo.Connect()
-	ctx := context.TODO()
-	c := client.Database(dbname).Collection(collname)
-	nrec, err := c.CountDocuments(ctx, spec)
+	nrec, err := CountContext(context.TODO(), dbname, collname, spec)
 	if err != nil {
 		log.Printf("Unable to count records, spec %v, error %v\n", spec, err)
 	}
-	return int(nrec)
+	return nrec
+}
+
+// CountContext gets number records from MongoDB
+func CountContext(ctx context.Context, dbname, collname string, spec map[string]any) (int, error) {
+	c, err := collection(dbname, collname)
+	if err != nil {
+		return 0, fmt.Errorf("[golib.mongo.Count] collection error: %w", err)
+	}
+	nrec, err := c.CountDocuments(ctx, spec)
+	if err != nil {
+		return 0, fmt.Errorf("[golib.mongo.Count] c.CountDocuments error: %w", err)
+	}
+	return int(nrec), nil
 }
 
 // Distinct gets number records from MongoDB
 func Distinct(dbname, collname, field string) ([]any, error) {
+	return DistinctContext(context.TODO(), dbname, collname, field)
+}
+
+// DistinctContext gets distinct values of given field from MongoDB
+func DistinctContext(ctx context.Context, dbname, collname, field string) ([]any, error) {
 	var records []any
-	client := Mongo.Connect()
-	ctx := context.TODO()
 	filter := bson.D{}
-	c := client.Database(dbname).Collection(collname)
+	c, err := collection(dbname, collname)
+	if err != nil {
+		return records, fmt.Errorf("[golib.mongo.Distinct] collection error: %w", err)
+	}
 	res := c.Distinct(ctx, field, filter)
 	if err := res.Err(); err != nil {
 		log.Printf("Unable to fetch unique records, field %s spec %v, error %v\n", field, filter, err)
 		return records, fmt.Errorf("[golib.mongo.Distinct] res.Err error: %w", err)
 	}
-	err := res.Decode(&records)
+	err = res.Decode(&records)
 	if err != nil {
 		log.Printf("failed to decode distinct result: %v", err)
 		return records, fmt.Errorf("[golib.mongo.Distinct] res.Decode error: %w", err)
@@ -449,9 +549,15 @@ func Distinct(dbname, collname, field string) ([]any, error) {
 
 // Remove records from MongoDB
 func Remove(dbname, collname string, spec map[string]any) error {
-	client := Mongo.Connect()
-	ctx := context.TODO()
-	c := client.Database(dbname).Collection(collname)
+	return RemoveContext(context.TODO(), dbname, collname, spec)
+}
+
+// RemoveContext removes records matching given spec from MongoDB
+func RemoveContext(ctx context.Context, dbname, collname string, spec map[string]any) error {
+	c, err := collection(dbname, collname)
+	if err != nil {
+		return fmt.Errorf("[golib.mongo.Remove] collection error: %w", err)
+	}
 	results, err := c.DeleteMany(ctx, spec)
 	if err != nil {
 		log.Printf("Unable to remove records, spec %v, error %v\n", spec, err)
@@ -460,3 +566,120 @@ func Remove(dbname, collname string, spec map[string]any) error {
 	log.Printf("mongo remove results %+v", results)
 	return nil
 }
+
+// WithTransaction runs given function within MongoDB transaction, all
+// operations which use context passed to the function are part of it. The
+// transaction is committed if function returns no error and aborted
+// otherwise, nested calls join outer transaction. MongoDB retries function
+// on transient errors, therefore it should be safe to run it several times.
+// Transactions require replica set or sharded cluster deployment.
+func WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if mongo.SessionFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+	client, err := Mongo.ConnectWithError()
+	if err != nil {
+		return fmt.Errorf("[golib.mongo.WithTransaction] connect error: %w", err)
+	}
+	sess, err := client.StartSession()
+	if err != nil {
+		return fmt.Errorf("[golib.mongo.WithTransaction] client.StartSession error: %w", err)
+	}
+	defer sess.EndSession(ctx)
+	_, err = sess.WithTransaction(ctx, func(sctx context.Context) (any, error) {
+		return nil, fn(sctx)
+	})
+	return err
+}
+
+// Watch returns channel of change events of MongoDB records which belong to
+// given database/collection and match given spec, the channel is closed when
+// context is done or change stream fails. Update events contain current
+// version of updated record, while delete events are not filtered by spec
+// since MongoDB does not provide deleted records. Change streams require
+// replica set or sharded cluster deployment.
+func Watch(ctx context.Context, dbname, collname string, spec map[string]any) (<-chan map[string]any, error) {
+	c, err := collection(dbname, collname)
+	if err != nil {
+		return nil, fmt.Errorf("[golib.mongo.Watch] collection error: %w", err)
+	}
+	ops := bson.A{"insert", "update", "replace", "delete"}
+	match := bson.M{"operationType": bson.M{"$in": ops}}
+	if len(spec) > 0 {
+		match = bson.M{"$and": bson.A{match, bson.M{"$or": bson.A{
+			bson.M{"operationType": "delete"},
+			prefixSpec(spec, "fullDocument."),
+		}}}}
+	}
+	pipeline := bson.A{bson.M{"$match": match}}
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	stream, err := c.Watch(ctx, pipeline, opts)
+	if err != nil {
+		return nil, fmt.Errorf("[golib.mongo.Watch] c.Watch error: %w", err)
+	}
+	out := make(chan map[string]any)
+	go func() {
+		defer close(out)
+		defer stream.Close(context.Background())
+		for stream.Next(ctx) {
+			var event map[string]any
+			if err := stream.Decode(&event); err != nil {
+				log.Println("ERROR:", err)
+				return
+			}
+			// replacement of a document is reported as its update
+			if event["operationType"] == "replace" {
+				event["operationType"] = "update"
+			}
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err := stream.Err(); err != nil && ctx.Err() == nil {
+			log.Println("ERROR:", err)
+		}
+	}()
+	return out, nil
+}
+
+// helper function to add given prefix to field names of given spec, it is
+// used to match fields of documents embedded into change events
+func prefixSpec(spec map[string]any, prefix string) map[string]any {
+	out := make(map[string]any, len(spec))
+	for key, val := range spec {
+		switch {
+		case key == "$and" || key == "$or" || key == "$nor":
+			if arr, ok := val.(bson.A); ok {
+				val = []any(arr)
+			}
+			var specs bson.A
+			switch v := val.(type) {
+			case []map[string]any:
+				for _, s := range v {
+					specs = append(specs, prefixSpec(s, prefix))
+				}
+			case []any:
+				for _, s := range v {
+					if m, ok := s.(map[string]any); ok {
+						specs = append(specs, prefixSpec(m, prefix))
+					} else if m, ok := s.(bson.M); ok {
+						specs = append(specs, prefixSpec(m, prefix))
+					} else {
+						specs = append(specs, s)
+					}
+				}
+			default:
+				out[key] = val
+				continue
+			}
+			out[key] = specs
+		case strings.HasPrefix(key, "$"):
+			out[key] = val
+		default:
+			out[prefix+key] = val
+		}
+	}
+	return out
+}
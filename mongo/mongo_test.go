@@ -1,7 +1,16 @@
 package mongo
 
 import (
+	"context"
 	"testing"
+	"time"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	bson "go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/event"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
 )
 
 // TestMongoInsert
@@ -44,3 +53,116 @@ func TestMongoInsert(t *testing.T) {
 		t.Errorf("unable to find records using spec '%s', records %+v", spec, records)
 	}
 }
+
+// TestPrefixSpec tests conversion of spec to match change events
+func TestPrefixSpec(t *testing.T) {
+	spec := map[string]any{
+		"did":   "/a",
+		"$or":   []any{map[string]any{"energy": 1}, map[string]any{"sample.name": "Fe"}},
+		"$text": map[string]any{"$search": "Fe"},
+	}
+	out := prefixSpec(spec, "fullDocument.")
+	if out["fullDocument.did"] != "/a" || out["$text"] == nil || len(out) != 3 {
+		t.Fatalf("wrong spec %v", out)
+	}
+	or, ok := out["$or"].(bson.A)
+	if !ok || len(or) != 2 {
+		t.Fatalf("wrong $or spec %v", out["$or"])
+	}
+	if m := or[1].(map[string]any); m["fullDocument.sample.name"] != "Fe" {
+		t.Fatalf("wrong $or spec %v", or)
+	}
+}
+
+// TestClientOptions tests client options of MongoDB configuration
+func TestClientOptions(t *testing.T) {
+	cfg := srvConfig.MongoDB{MaxPoolSize: 50, ServerSelectionTimeout: 3, ReadPreference: "secondaryPreferred", WriteConcern: "2"}
+	opts := options.Client()
+	if err := ClientOptionsFromConfig(cfg).apply(opts); err != nil {
+		t.Fatalf("apply error: %v", err)
+	}
+	if *opts.MaxPoolSize != 50 || opts.MinPoolSize != nil || *opts.ServerSelectionTimeout != 3*time.Second || *opts.Timeout != DefaultTimeout {
+		t.Errorf("wrong pool options %+v", opts)
+	}
+	if opts.ReadPreference.Mode() != readpref.SecondaryPreferredMode || opts.WriteConcern.W != 2 {
+		t.Errorf("wrong read preference %v or write concern %v", opts.ReadPreference, opts.WriteConcern)
+	}
+	for _, c := range []ClientOptions{{ReadPreference: "foo"}, {WriteConcern: "-1"}} {
+		if err := c.apply(options.Client()); err == nil {
+			t.Errorf("options %+v, expected error", c)
+		}
+	}
+}
+
+// TestPoolStats tests connection pool statistics and ping of unreachable server
+func TestPoolStats(t *testing.T) {
+	conn := Connection{URI: "mongodb://localhost:1", Options: ClientOptions{ServerSelectionTimeout: 100 * time.Millisecond}}
+	if err := conn.Ping(context.Background()); err == nil {
+		t.Error("expected ping error")
+	}
+	monitor := conn.stats.monitor()
+	for _, etype := range []string{event.ConnectionCreated, event.ConnectionCreated, event.ConnectionCheckedOut, event.ConnectionCheckedOut, event.ConnectionCheckedIn} {
+		monitor.Event(&event.PoolEvent{Type: etype, Duration: time.Millisecond})
+	}
+	stats := conn.PoolStats()
+	if stats.Open != 2 || stats.CheckedOut != 1 || stats.CheckOuts != 2 || stats.WaitTime != 2*time.Millisecond {
+		t.Errorf("wrong pool stats %+v", stats)
+	}
+}
+
+// TestIndexSpec tests index models and comparison with existing indexes
+func TestIndexSpec(t *testing.T) {
+	spec := IndexSpec{Fields: []string{"beamline", "-date"}, Unique: true}
+	model, err := spec.model()
+	if err != nil {
+		t.Fatalf("model error: %v", err)
+	}
+	keys := bson.D{{Key: "beamline", Value: 1}, {Key: "date", Value: -1}}
+	if spec.name(keys) != "beamline_1_date_-1" || len(model.Keys.(bson.D)) != 2 {
+		t.Errorf("wrong index model %+v", model)
+	}
+	index := bson.M{"name": "beamline_1_date_-1", "unique": true,
+		"key": bson.D{{Key: "beamline", Value: int32(1)}, {Key: "date", Value: int32(-1)}}}
+	if !spec.matches(index) {
+		t.Errorf("spec %+v does not match index %v", spec, index)
+	}
+	index["unique"] = false
+	if spec.matches(index) {
+		t.Errorf("spec %+v matches non-unique index %v", spec, index)
+	}
+	ttl := IndexSpec{Fields: []string{"created"}, TTL: time.Hour}
+	if !ttl.matches(bson.M{"key": bson.D{{Key: "created", Value: 1}}, "expireAfterSeconds": int32(3600)}) {
+		t.Errorf("TTL spec %+v does not match index", ttl)
+	}
+	text := IndexSpec{Fields: []string{"description", "sample"}, Text: true}
+	if !text.matches(bson.M{"key": bson.D{{Key: "_fts", Value: "text"}, {Key: "_ftsx", Value: 1}},
+		"weights": bson.D{{Key: "description", Value: 1}, {Key: "sample", Value: 1}}}) {
+		t.Errorf("text spec %+v does not match index", text)
+	}
+	for _, spec := range []IndexSpec{
+		{},
+		{Fields: []string{"a", "b"}, TTL: time.Hour},
+		{Fields: []string{"-a"}, Text: true},
+		{Fields: []string{"-"}},
+	} {
+		if _, err := spec.model(); err == nil {
+			t.Errorf("spec %+v, expected error", spec)
+		}
+	}
+}
+
+// TestPendingMigrations tests order and validation of migrations
+func TestPendingMigrations(t *testing.T) {
+	up := func(ctx context.Context, db *mongo.Database) error { return nil }
+	migrations := []Migration{{Version: 3, Up: up}, {Version: 1, Up: up}, {Version: 2, Up: up}}
+	pending, err := pendingMigrations(migrations, map[int]bool{1: true})
+	if err != nil || len(pending) != 2 || pending[0].Version != 2 || pending[1].Version != 3 {
+		t.Errorf("wrong pending migrations %+v, error %v", pending, err)
+	}
+	if _, err := pendingMigrations(append(migrations, Migration{Version: 2, Up: up}), nil); err == nil {
+		t.Error("expected duplicate version error")
+	}
+	if _, err := pendingMigrations([]Migration{{Version: 1}}, nil); err == nil {
+		t.Error("expected missing function error")
+	}
+}
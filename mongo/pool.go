@@ -0,0 +1,201 @@
+package mongo
+
+// pool module provides configuration, statistics and health check of
+// MongoDB connection pool
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	"go.mongodb.org/mongo-driver/v2/event"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
+	"go.mongodb.org/mongo-driver/v2/mongo/writeconcern"
+)
+
+// DefaultTimeout defines default timeout of MongoDB operations
+const DefaultTimeout = 10 * time.Second
+
+// ClientOptions represents MongoDB client options, zero values keep
+// options of connection URI or driver defaults
+type ClientOptions struct {
+	MaxPoolSize            uint64        // max number of connections in the pool
+	MinPoolSize            uint64        // min number of connections in the pool
+	ServerSelectionTimeout time.Duration // server selection timeout
+	Timeout                time.Duration // operation timeout, DefaultTimeout if not set
+	ReadPreference         string        // read preference mode, e.g. primary, secondaryPreferred, nearest
+	WriteConcern           string        // write concern, e.g. majority or number of nodes
+}
+
+// ClientOptionsFromConfig returns client options of given MongoDB configuration
+func ClientOptionsFromConfig(cfg srvConfig.MongoDB) ClientOptions {
+	return ClientOptions{
+		MaxPoolSize:            cfg.MaxPoolSize,
+		MinPoolSize:            cfg.MinPoolSize,
+		ServerSelectionTimeout: time.Duration(cfg.ServerSelectionTimeout) * time.Second,
+		Timeout:                time.Duration(cfg.Timeout) * time.Second,
+		ReadPreference:         cfg.ReadPreference,
+		WriteConcern:           cfg.WriteConcern,
+	}
+}
+
+// ConfigClientOptions returns client options of MongoDB section of server
+// configuration with given URI, or zero options if no such section exists
+func ConfigClientOptions(uri string) ClientOptions {
+	if srvConfig.Config == nil || uri == "" {
+		return ClientOptions{}
+	}
+	if cfg, ok := findMongoConfig(reflect.ValueOf(*srvConfig.Config), uri); ok {
+		return ClientOptionsFromConfig(cfg)
+	}
+	return ClientOptions{}
+}
+
+// helper function to find MongoDB configuration with given URI within
+// (nested) configuration sections
+func findMongoConfig(v reflect.Value, uri string) (srvConfig.MongoDB, bool) {
+	if v.Kind() != reflect.Struct {
+		return srvConfig.MongoDB{}, false
+	}
+	if cfg, ok := v.Interface().(srvConfig.MongoDB); ok {
+		return cfg, cfg.DBUri == uri
+	}
+	for i := 0; i < v.NumField(); i++ {
+		if !v.Type().Field(i).IsExported() {
+			continue
+		}
+		if cfg, ok := findMongoConfig(v.Field(i), uri); ok {
+			return cfg, true
+		}
+	}
+	return srvConfig.MongoDB{}, false
+}
+
+// helper function to apply client options to MongoDB driver options
+func (c ClientOptions) apply(opts *options.ClientOptions) error {
+	if c.MaxPoolSize > 0 {
+		opts.SetMaxPoolSize(c.MaxPoolSize)
+	}
+	if c.MinPoolSize > 0 {
+		opts.SetMinPoolSize(c.MinPoolSize)
+	}
+	if c.ServerSelectionTimeout > 0 {
+		opts.SetServerSelectionTimeout(c.ServerSelectionTimeout)
+	}
+	timeout := DefaultTimeout
+	if c.Timeout > 0 {
+		timeout = c.Timeout
+	}
+	opts.SetTimeout(timeout)
+	if c.ReadPreference != "" {
+		mode, err := readpref.ModeFromString(c.ReadPreference)
+		if err != nil {
+			return err
+		}
+		rp, err := readpref.New(mode)
+		if err != nil {
+			return err
+		}
+		opts.SetReadPreference(rp)
+	}
+	if c.WriteConcern != "" {
+		wc := &writeconcern.WriteConcern{W: c.WriteConcern}
+		if strings.ToLower(c.WriteConcern) == "majority" {
+			wc = writeconcern.Majority()
+		} else if n, err := strconv.Atoi(c.WriteConcern); err == nil {
+			if n < 0 {
+				return fmt.Errorf("wrong write concern %s", c.WriteConcern)
+			}
+			wc = &writeconcern.WriteConcern{W: n}
+		}
+		opts.SetWriteConcern(wc)
+	}
+	return nil
+}
+
+// PoolStats represents statistics of MongoDB connection pool
+type PoolStats struct {
+	Open             int64         `json:"open"`             // number of open connections
+	CheckedOut       int64         `json:"checkedOut"`       // number of connections in use
+	CheckOuts        uint64        `json:"checkOuts"`        // total number of connection check-outs
+	CheckOutFailures uint64        `json:"checkOutFailures"` // total number of failed check-outs
+	WaitTime         time.Duration `json:"waitTime"`         // total time spent waiting for connections
+	Cleared          uint64        `json:"cleared"`          // number of times the pool was cleared
+}
+
+// poolCounters keeps track of MongoDB connection pool events
+type poolCounters struct {
+	open             atomic.Int64
+	checkedOut       atomic.Int64
+	checkOuts        atomic.Uint64
+	checkOutFailures atomic.Uint64
+	waitTime         atomic.Int64
+	cleared          atomic.Uint64
+}
+
+// helper function to create pool monitor which updates pool counters
+func (p *poolCounters) monitor() *event.PoolMonitor {
+	return &event.PoolMonitor{Event: func(e *event.PoolEvent) {
+		switch e.Type {
+		case event.ConnectionCreated:
+			p.open.Add(1)
+		case event.ConnectionClosed:
+			p.open.Add(-1)
+		case event.ConnectionCheckedOut:
+			p.checkedOut.Add(1)
+			p.checkOuts.Add(1)
+			p.waitTime.Add(int64(e.Duration))
+		case event.ConnectionCheckOutFailed:
+			p.checkOutFailures.Add(1)
+			p.waitTime.Add(int64(e.Duration))
+		case event.ConnectionCheckedIn:
+			p.checkedOut.Add(-1)
+		case event.ConnectionPoolCleared:
+			p.cleared.Add(1)
+		}
+	}}
+}
+
+// PoolStats returns statistics of connection pool
+func (m *Connection) PoolStats() PoolStats {
+	if m.stats == nil {
+		return PoolStats{}
+	}
+	return PoolStats{
+		Open:             m.stats.open.Load(),
+		CheckedOut:       m.stats.checkedOut.Load(),
+		CheckOuts:        m.stats.checkOuts.Load(),
+		CheckOutFailures: m.stats.checkOutFailures.Load(),
+		WaitTime:         time.Duration(m.stats.waitTime.Load()),
+		Cleared:          m.stats.cleared.Load(),
+	}
+}
+
+// Ping verifies that MongoDB server is reachable using read preference of
+// the connection
+func (m *Connection) Ping(ctx context.Context) error {
+	client, err := m.ConnectWithError()
+	if err != nil {
+		return fmt.Errorf("[golib.mongo.Ping] connect error: %w", err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		return fmt.Errorf("[golib.mongo.Ping] client.Ping error: %w", err)
+	}
+	return nil
+}
+
+// Ping verifies that MongoDB server of global connection is reachable
+func Ping(ctx context.Context) error {
+	return Mongo.Ping(ctx)
+}
+
+// Stats returns connection pool statistics of global connection
+func Stats() PoolStats {
+	return Mongo.PoolStats()
+}
@@ -0,0 +1,534 @@
+package record
+
+// record module provides typed access to MongoDB records. Fields are
+// addressed by dotted paths where numeric parts are indices of arrays, e.g.
+// "sample.scans.0.energy", and values are converted between Go and BSON
+// types such as ObjectID, DateTime and Decimal128, e.g.
+//
+//	energy, err := record.Get[float64](rec, "sample.scans.0.energy")
+//	created, err := record.Get[time.Time](rec, "created")
+//	tags, err := record.GetSlice[string](rec, "tags")
+//	err = record.Set(rec, "sample.name", "Fe")
+//	ok := record.Delete(rec, "sample.mass")
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	bson "go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ErrNotFound is returned when record does not have given path
+var ErrNotFound = errors.New("path not found")
+
+// Get returns value of given path converted to type T
+func Get[T any](rec map[string]any, path string) (T, error) {
+	var out T
+	val, err := lookup(rec, path)
+	if err != nil {
+		return out, fmt.Errorf("[golib.mongo.record.Get] %w", err)
+	}
+	out, err = Convert[T](val)
+	if err != nil {
+		return out, fmt.Errorf("[golib.mongo.record.Get] path %s: %w", path, err)
+	}
+	return out, nil
+}
+
+// GetSlice returns array of given path with elements converted to type T
+func GetSlice[T any](rec map[string]any, path string) ([]T, error) {
+	val, err := lookup(rec, path)
+	if err != nil {
+		return nil, fmt.Errorf("[golib.mongo.record.GetSlice] %w", err)
+	}
+	arr, ok := asArray(val)
+	if !ok {
+		return nil, fmt.Errorf("[golib.mongo.record.GetSlice] path %s: expects array, got %T", path, val)
+	}
+	out := make([]T, 0, len(arr))
+	for i, elem := range arr {
+		v, err := Convert[T](elem)
+		if err != nil {
+			return nil, fmt.Errorf("[golib.mongo.record.GetSlice] path %s, element %d: %w", path, i, err)
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// Set sets value of given path, missing documents are created and array
+// index equal to array length appends value to the array
+func Set(rec map[string]any, path string, val any) error {
+	if rec == nil || path == "" {
+		return errors.New("[golib.mongo.record.Set] nil record or empty path")
+	}
+	if _, err := set(rec, splitPath(path), val); err != nil {
+		return fmt.Errorf("[golib.mongo.record.Set] path %s: %w", path, err)
+	}
+	return nil
+}
+
+// Delete removes given path from record, elements removed from arrays shift
+// following ones. It reports whether the path existed.
+func Delete(rec map[string]any, path string) bool {
+	_, ok := remove(rec, splitPath(path))
+	return ok
+}
+
+// Convert converts given value to type T, it handles numeric conversions
+// without loss of precision, BSON ObjectID, DateTime, Timestamp and
+// Decimal128 types, BSON documents and arrays
+func Convert[T any](val any) (T, error) {
+	var out T
+	if v, ok := val.(T); ok {
+		return v, nil
+	}
+	var err error
+	switch p := any(&out).(type) {
+	case *string:
+		*p, err = toString(val)
+	case *int:
+		*p, err = toInt[int](val, math.MinInt, math.MaxInt)
+	case *int32:
+		*p, err = toInt[int32](val, math.MinInt32, math.MaxInt32)
+	case *int64:
+		*p, err = toInt[int64](val, math.MinInt64, math.MaxInt64)
+	case *uint:
+		*p, err = toInt[uint](val, 0, math.MaxInt)
+	case *uint64:
+		*p, err = toInt[uint64](val, 0, math.MaxInt64)
+	case *float64:
+		*p, err = toFloat(val)
+	case *float32:
+		var f float64
+		f, err = toFloat(val)
+		*p = float32(f)
+	case *bool:
+		*p, err = toBool(val)
+	case *time.Time:
+		*p, err = toTime(val)
+	case *bson.ObjectID:
+		*p, err = toObjectID(val)
+	case *bson.DateTime:
+		var t time.Time
+		t, err = toTime(val)
+		*p = bson.NewDateTimeFromTime(t)
+	case *bson.Decimal128:
+		*p, err = toDecimal(val)
+	case *map[string]any:
+		doc, ok := asDoc(val)
+		if !ok {
+			err = conversionError(val, out)
+		}
+		*p = doc
+	case *[]any:
+		arr, ok := asArray(val)
+		if !ok {
+			err = conversionError(val, out)
+		}
+		*p = arr
+	default:
+		err = conversionError(val, out)
+	}
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return out, nil
+}
+
+// helper function to create conversion error
+func conversionError(val, target any) error {
+	return fmt.Errorf("unable to convert %v of type %T to %T", val, val, target)
+}
+
+// helper function to split path into its parts
+func splitPath(path string) []string {
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+// helper function to look-up value of given path
+func lookup(rec map[string]any, path string) (any, error) {
+	keys := splitPath(path)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("empty path")
+	}
+	var val any = rec
+	for i, key := range keys {
+		if doc, ok := asDoc(val); ok {
+			v, ok := doc[key]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", ErrNotFound, strings.Join(keys[:i+1], "."))
+			}
+			val = v
+		} else if arr, ok := asArray(val); ok {
+			idx, err := strconv.Atoi(key)
+			if err != nil {
+				return nil, fmt.Errorf("%s is array, expects index got %s", strings.Join(keys[:i], "."), key)
+			}
+			if idx < 0 || idx >= len(arr) {
+				return nil, fmt.Errorf("%w: %s, index out of range", ErrNotFound, strings.Join(keys[:i+1], "."))
+			}
+			val = arr[idx]
+		} else {
+			return nil, fmt.Errorf("%w: %s, %s is %T", ErrNotFound, strings.Join(keys, "."), strings.Join(keys[:i], "."), val)
+		}
+	}
+	return val, nil
+}
+
+// helper function to set value of given path within container, it returns
+// container which should replace original one, e.g. when array grows
+func set(container any, keys []string, val any) (any, error) {
+	if len(keys) == 0 {
+		return val, nil
+	}
+	key := keys[0]
+	switch c := container.(type) {
+	case map[string]any:
+		v, err := set(child(c[key], keys[1:]), keys[1:], val)
+		if err != nil {
+			return nil, err
+		}
+		c[key] = v
+		return c, nil
+	case bson.M:
+		v, err := set(child(c[key], keys[1:]), keys[1:], val)
+		if err != nil {
+			return nil, err
+		}
+		c[key] = v
+		return c, nil
+	case bson.D:
+		for i, e := range c {
+			if e.Key == key {
+				v, err := set(child(e.Value, keys[1:]), keys[1:], val)
+				if err != nil {
+					return nil, err
+				}
+				c[i].Value = v
+				return c, nil
+			}
+		}
+		v, err := set(child(nil, keys[1:]), keys[1:], val)
+		if err != nil {
+			return nil, err
+		}
+		return append(c, bson.E{Key: key, Value: v}), nil
+	}
+	rv := reflect.ValueOf(container)
+	if rv.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("unable to set field %s of %T", key, container)
+	}
+	idx, err := strconv.Atoi(key)
+	if err != nil || idx < 0 || idx > rv.Len() {
+		return nil, fmt.Errorf("wrong index %s of array with %d elements", key, rv.Len())
+	}
+	var elem any
+	if idx < rv.Len() {
+		elem = rv.Index(idx).Interface()
+	}
+	if len(keys) > 1 {
+		elem = child(elem, keys[1:])
+	}
+	v, err := set(elem, keys[1:], val)
+	if err != nil {
+		return nil, err
+	}
+	ev := reflect.ValueOf(v)
+	if !ev.IsValid() {
+		ev = reflect.Zero(rv.Type().Elem())
+	}
+	if !ev.Type().AssignableTo(rv.Type().Elem()) {
+		return nil, fmt.Errorf("unable to store %T in %T", v, container)
+	}
+	if idx == rv.Len() {
+		return reflect.Append(rv, ev).Interface(), nil
+	}
+	rv.Index(idx).Set(ev)
+	return container, nil
+}
+
+// helper function to return existing child container or create new one
+// for remaining keys
+func child(val any, keys []string) any {
+	if val != nil || len(keys) == 0 {
+		return val
+	}
+	return map[string]any{}
+}
+
+// helper function to remove given path from container, it returns container
+// which should replace original one and reports whether path existed
+func remove(container any, keys []string) (any, bool) {
+	if len(keys) == 0 {
+		return container, false
+	}
+	key, last := keys[0], len(keys) == 1
+	switch c := container.(type) {
+	case map[string]any:
+		return removeKey(c, key, keys[1:], last)
+	case bson.M:
+		return removeKey(c, key, keys[1:], last)
+	case bson.D:
+		for i, e := range c {
+			if e.Key != key {
+				continue
+			}
+			if last {
+				return append(c[:i:i], c[i+1:]...), true
+			}
+			v, ok := remove(e.Value, keys[1:])
+			c[i].Value = v
+			return c, ok
+		}
+		return c, false
+	}
+	rv := reflect.ValueOf(container)
+	if rv.Kind() != reflect.Slice {
+		return container, false
+	}
+	idx, err := strconv.Atoi(key)
+	if err != nil || idx < 0 || idx >= rv.Len() {
+		return container, false
+	}
+	if last {
+		out := reflect.MakeSlice(rv.Type(), 0, rv.Len()-1)
+		out = reflect.AppendSlice(out, rv.Slice(0, idx))
+		out = reflect.AppendSlice(out, rv.Slice(idx+1, rv.Len()))
+		return out.Interface(), true
+	}
+	v, ok := remove(rv.Index(idx).Interface(), keys[1:])
+	if ok {
+		rv.Index(idx).Set(reflect.ValueOf(v))
+	}
+	return container, ok
+}
+
+// helper function to remove key from document
+func removeKey(doc map[string]any, key string, keys []string, last bool) (any, bool) {
+	val, ok := doc[key]
+	if !ok {
+		return doc, false
+	}
+	if last {
+		delete(doc, key)
+		return doc, true
+	}
+	v, ok := remove(val, keys)
+	doc[key] = v
+	return doc, ok
+}
+
+// helper function to convert BSON documents into map
+func asDoc(val any) (map[string]any, bool) {
+	switch v := val.(type) {
+	case map[string]any:
+		return v, true
+	case bson.M:
+		return v, true
+	case bson.D:
+		out := make(map[string]any, len(v))
+		for _, e := range v {
+			out[e.Key] = e.Value
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// helper function to convert arrays of any type into []any
+func asArray(val any) ([]any, bool) {
+	switch v := val.(type) {
+	case []any:
+		return v, true
+	case bson.A:
+		return v, true
+	case bson.D, []byte:
+		return nil, false
+	}
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	out := make([]any, rv.Len())
+	for i := range out {
+		out[i] = rv.Index(i).Interface()
+	}
+	return out, true
+}
+
+// helper function to convert value into string
+func toString(val any) (string, error) {
+	switch v := val.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case bson.ObjectID:
+		return v.Hex(), nil
+	case bson.DateTime:
+		return v.Time().UTC().Format(time.RFC3339Nano), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case bson.Decimal128:
+		return v.String(), nil
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case fmt.Stringer:
+		return v.String(), nil
+	}
+	return "", conversionError(val, "")
+}
+
+// helper function to convert value into float
+func toFloat(val any) (float64, error) {
+	switch v := val.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int8:
+		return float64(v), nil
+	case int16:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint:
+		return float64(v), nil
+	case uint8:
+		return float64(v), nil
+	case uint16:
+		return float64(v), nil
+	case uint32:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case bson.Decimal128:
+		return strconv.ParseFloat(v.String(), 64)
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(v), 64)
+	}
+	return 0, conversionError(val, float64(0))
+}
+
+// helper function to convert value into integer type within given range,
+// floating point values must not have fractional part
+func toInt[T int | int32 | int64 | uint | uint64](val any, min, max int64) (T, error) {
+	var i int64
+	switch v := val.(type) {
+	case int:
+		i = int64(v)
+	case int8:
+		i = int64(v)
+	case int16:
+		i = int64(v)
+	case int32:
+		i = int64(v)
+	case int64:
+		i = v
+	case uint8:
+		i = int64(v)
+	case uint16:
+		i = int64(v)
+	case uint32:
+		i = int64(v)
+	case uint, uint64:
+		u := reflect.ValueOf(v).Uint()
+		if u > math.MaxInt64 {
+			return 0, fmt.Errorf("value %v is out of range", val)
+		}
+		i = int64(u)
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		i = n
+	default:
+		f, err := toFloat(val)
+		if err != nil {
+			return 0, conversionError(val, T(0))
+		}
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return 0, fmt.Errorf("value %v is not an integer", val)
+		}
+		i = int64(f)
+	}
+	if i < min || i > max {
+		return 0, fmt.Errorf("value %v is out of range", val)
+	}
+	return T(i), nil
+}
+
+// helper function to convert value into bool
+func toBool(val any) (bool, error) {
+	switch v := val.(type) {
+	case bool:
+		return v, nil
+	case string:
+		return strconv.ParseBool(strings.TrimSpace(v))
+	}
+	return false, conversionError(val, false)
+}
+
+// helper function to convert value into time
+func toTime(val any) (time.Time, error) {
+	switch v := val.(type) {
+	case time.Time:
+		return v, nil
+	case bson.DateTime:
+		return v.Time(), nil
+	case bson.Timestamp:
+		return time.Unix(int64(v.T), 0), nil
+	case bson.ObjectID:
+		return v.Timestamp(), nil
+	case string:
+		return time.Parse(time.RFC3339Nano, strings.TrimSpace(v))
+	}
+	return time.Time{}, conversionError(val, time.Time{})
+}
+
+// helper function to convert value into ObjectID
+func toObjectID(val any) (bson.ObjectID, error) {
+	switch v := val.(type) {
+	case bson.ObjectID:
+		return v, nil
+	case string:
+		return bson.ObjectIDFromHex(v)
+	}
+	return bson.ObjectID{}, conversionError(val, bson.ObjectID{})
+}
+
+// helper function to convert value into Decimal128
+func toDecimal(val any) (bson.Decimal128, error) {
+	switch v := val.(type) {
+	case bson.Decimal128:
+		return v, nil
+	case string:
+		return bson.ParseDecimal128(strings.TrimSpace(v))
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return bson.ParseDecimal128(fmt.Sprint(v))
+	case float32:
+		return bson.ParseDecimal128(strconv.FormatFloat(float64(v), 'g', -1, 32))
+	case float64:
+		return bson.ParseDecimal128(strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	return bson.Decimal128{}, conversionError(val, bson.Decimal128{})
+}
@@ -0,0 +1,117 @@
+package record
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	bson "go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// helper function to create test record
+func testRecord() map[string]any {
+	oid, _ := bson.ObjectIDFromHex("65a1b2c3d4e5f60718293a4b")
+	dec, _ := bson.ParseDecimal128("12.50")
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	return map[string]any{
+		"_id":     oid,
+		"created": bson.NewDateTimeFromTime(created),
+		"price":   dec,
+		"energy":  int32(25),
+		"sample":  bson.M{"name": "Fe", "mass": 1.5},
+		"scans":   bson.A{bson.D{{Key: "id", Value: int64(1)}}, bson.D{{Key: "id", Value: 2.0}}},
+		"tags":    []string{"a", "b"},
+	}
+}
+
+// TestGet tests typed look-ups of record values
+func TestGet(t *testing.T) {
+	rec := testRecord()
+	if v, err := Get[int](rec, "energy"); err != nil || v != 25 {
+		t.Errorf("energy: got %v, error %v", v, err)
+	}
+	if v, err := Get[float64](rec, "sample.mass"); err != nil || v != 1.5 {
+		t.Errorf("sample.mass: got %v, error %v", v, err)
+	}
+	if v, err := Get[string](rec, "sample.name"); err != nil || v != "Fe" {
+		t.Errorf("sample.name: got %v, error %v", v, err)
+	}
+	if v, err := Get[int64](rec, "scans.1.id"); err != nil || v != 2 {
+		t.Errorf("scans.1.id: got %v, error %v", v, err)
+	}
+	if v, err := Get[string](rec, "_id"); err != nil || v != "65a1b2c3d4e5f60718293a4b" {
+		t.Errorf("_id: got %v, error %v", v, err)
+	}
+	if v, err := Get[time.Time](rec, "created"); err != nil || !v.Equal(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("created: got %v, error %v", v, err)
+	}
+	if v, err := Get[float64](rec, "price"); err != nil || v != 12.5 {
+		t.Errorf("price: got %v, error %v", v, err)
+	}
+	if v, err := Get[map[string]any](rec, "scans.0"); err != nil || v["id"] != int64(1) {
+		t.Errorf("scans.0: got %v, error %v", v, err)
+	}
+	if v, err := GetSlice[string](rec, "tags"); err != nil || len(v) != 2 || v[1] != "b" {
+		t.Errorf("tags: got %v, error %v", v, err)
+	}
+	if v, err := GetSlice[int](rec, "scans.0.id"); err == nil {
+		t.Errorf("scans.0.id: expected error, got %v", v)
+	}
+	if _, err := Get[int](rec, "sample.size"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("sample.size: expected ErrNotFound, got %v", err)
+	}
+	for _, path := range []string{"sample.mass", "sample.name", "scans.id", "scans.5.id"} {
+		if v, err := Get[int](rec, path); err == nil {
+			t.Errorf("%s: expected error, got %v", path, v)
+		}
+	}
+	if _, err := Get[int8](rec, "energy"); err == nil {
+		t.Error("int8: expected unsupported type error")
+	}
+}
+
+// TestSetDelete tests modification of record values
+func TestSetDelete(t *testing.T) {
+	rec := testRecord()
+	for path, val := range map[string]any{
+		"sample.name":  "Cu",
+		"beam.current": 5,
+		"scans.0.id":   10,
+		"scans.2":      bson.M{"id": 3},
+		"tags.0":       "x",
+	} {
+		if err := Set(rec, path, val); err != nil {
+			t.Fatalf("Set %s error: %v", path, err)
+		}
+		if v, err := Get[any](rec, path); err != nil || v == nil {
+			t.Errorf("%s: got %v, error %v", path, v, err)
+		}
+	}
+	if v, _ := GetSlice[int](rec, "scans"); v != nil {
+		t.Errorf("scans are documents, got %v", v)
+	}
+	if n, _ := Get[int](rec, "scans.2.id"); n != 3 {
+		t.Errorf("scans.2.id: got %v", n)
+	}
+	for _, path := range []string{"tags.5", "energy.value", "scans.x"} {
+		if err := Set(rec, path, 1); err == nil {
+			t.Errorf("Set %s: expected error", path)
+		}
+	}
+	if err := Set(rec, "tags.1", 1); err == nil {
+		t.Error("Set tags.1: expected type error")
+	}
+
+	if !Delete(rec, "sample.mass") || !Delete(rec, "scans.0.id") || !Delete(rec, "tags.0") {
+		t.Fatalf("Delete failed %v", rec)
+	}
+	if Delete(rec, "sample.size") || Delete(rec, "tags.3") {
+		t.Error("Delete of missing path reported success")
+	}
+	if _, err := Get[float64](rec, "sample.mass"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("sample.mass is not deleted: %v", err)
+	}
+	if v, err := GetSlice[string](rec, "tags"); err != nil || len(v) != 1 || v[0] != "b" {
+		t.Errorf("tags: got %v, error %v", v, err)
+	}
+}
@@ -0,0 +1,229 @@
+package ql
+
+// compile module converts QL abstract syntax tree into MongoDB spec
+
+import (
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Compile converts QL query abstract syntax tree into MongoDB spec. Terms
+// are adjusted to schema keys and their values to key data types like in
+// ParseQuery, AND of terms with
+// different keys is compiled into single spec and free text terms are
+// combined into single $text search which follows MongoDB text search
+// semantics, therefore free text can not be part of OR with field terms.
+func Compile(node Node) (map[string]any, error) {
+	return compile(node, "", defaultKeys())
+}
+
+// helper function to compile node of given query with given known keys
+func compile(node Node, query string, keys *queryKeys) (map[string]any, error) {
+	c := &compiler{query: query, keys: keys}
+	spec, text, err := c.compile(node)
+	if err != nil {
+		return nil, err
+	}
+	if spec == nil {
+		spec = make(map[string]any)
+	}
+	if len(text) > 0 {
+		spec["$text"] = map[string]any{"$search": strings.Join(text, " ")}
+	}
+	return spec, nil
+}
+
+// compiler represents QL compiler
+type compiler struct {
+	query string     // original query used in error messages
+	keys  *queryKeys // known query keys
+}
+
+// helper function to create compilation error of given node
+func (c *compiler) errorf(node Node, msg string) error {
+	return &SyntaxError{Query: c.query, Offset: node.Offset(), Msg: msg}
+}
+
+// helper function to compile node into MongoDB spec and free text terms
+func (c *compiler) compile(node Node) (map[string]any, []string, error) {
+	switch n := node.(type) {
+	case *TermNode:
+		if n.Field == "" {
+			return nil, []string{textTerm(n)}, nil
+		}
+		spec, err := c.compileTerm(n)
+		if err != nil {
+			return nil, nil, c.errorf(n, err.Error())
+		}
+		return spec, nil, nil
+	case *RangeNode:
+		if _, known := c.keys.keyType(n.Field); !known {
+			return nil, nil, c.errorf(n, unknownKey(n.Field).Error())
+		}
+		cond := make(map[string]any)
+		bounds := []struct {
+			val       string
+			op        string
+			inclusive bool
+		}{{n.Lower, "$gt", n.IncludeLower}, {n.Upper, "$lt", n.IncludeUpper}}
+		for _, b := range bounds {
+			if b.val == "" {
+				continue
+			}
+			val, err := c.keys.typedValue(n.Field, b.val)
+			if err != nil {
+				return nil, nil, c.errorf(n, err.Error())
+			}
+			op := b.op
+			if b.inclusive {
+				op += "e"
+			}
+			cond[op] = val
+		}
+		if len(cond) == 0 {
+			cond["$exists"] = true
+		}
+		return map[string]any{c.keys.adjustKey(n.Field): cond}, nil, nil
+	case *ListNode:
+		var vals []any
+		for _, v := range n.Values {
+			val, err := c.keys.typedValue(n.Field, v)
+			if err != nil {
+				return nil, nil, c.errorf(n, err.Error())
+			}
+			vals = append(vals, val)
+		}
+		key, cond, err := c.keys.adjustList(n.Field, vals)
+		if err != nil {
+			return nil, nil, c.errorf(n, err.Error())
+		}
+		return map[string]any{key: cond}, nil, nil
+	case *NotNode:
+		spec, text, err := c.compile(n.Node)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(text) > 0 {
+			if len(spec) > 0 || len(text) > 1 {
+				return nil, nil, c.errorf(n, "negation of free text can be applied to single term only")
+			}
+			return nil, []string{"-" + text[0]}, nil
+		}
+		return map[string]any{"$nor": []any{spec}}, nil, nil
+	case *BoolNode:
+		var specs []map[string]any
+		var text []string
+		var withText, withSpec bool
+		for _, child := range n.Nodes {
+			spec, words, err := c.compile(child)
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(spec) > 0 {
+				specs = append(specs, spec)
+				withSpec = true
+			}
+			if len(words) > 0 {
+				text = append(text, words...)
+				withText = true
+			}
+		}
+		if n.Op == "OR" {
+			if withText && withSpec {
+				return nil, nil, c.errorf(n, "free text search can not be combined with field terms by OR")
+			}
+			if len(specs) == 0 {
+				return nil, text, nil
+			}
+			var or []any
+			for _, spec := range specs {
+				or = append(or, spec)
+			}
+			return map[string]any{"$or": or}, nil, nil
+		}
+		return mergeSpecs(specs), text, nil
+	}
+	return nil, nil, nil
+}
+
+// helper function to compile field:value term
+func (c *compiler) compileTerm(n *TermNode) (map[string]any, error) {
+	if _, known := c.keys.keyType(n.Field); !known {
+		return nil, unknownKey(n.Field)
+	}
+	if n.Wildcard && !n.Quoted {
+		if n.Value == "*" {
+			return map[string]any{c.keys.adjustKey(n.Field): map[string]any{"$exists": true}}, nil
+		}
+		return map[string]any{c.keys.adjustKey(n.Field): map[string]any{"$regex": n.Pattern}}, nil
+	}
+	if n.Field == "_id" {
+		// adjust query _id to object id type
+		if oid, err := bson.ObjectIDFromHex(n.Value); err == nil {
+			return map[string]any{"_id": oid}, nil
+		}
+		return map[string]any{"_id": n.Value}, nil
+	}
+	key, val, err := c.keys.adjustValue(n.Field, n.Value)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{key: val}, nil
+}
+
+// helper function to return free text search term of a node
+func textTerm(n *TermNode) string {
+	if n.Quoted {
+		return `"` + n.Value + `"`
+	}
+	return n.Value
+}
+
+// helper function to merge specs of AND expression, specs are merged into
+// single spec unless they have common keys
+func mergeSpecs(specs []map[string]any) map[string]any {
+	if len(specs) == 0 {
+		return nil
+	}
+	var all []any
+	out := make(map[string]any)
+	merge := true
+	for _, spec := range specs {
+		if and, ok := spec["$and"].([]any); ok && len(spec) == 1 {
+			all = append(all, and...)
+			for _, s := range and {
+				for k, v := range s.(map[string]any) {
+					if _, ok := out[k]; ok {
+						merge = false
+					}
+					out[k] = v
+				}
+			}
+			continue
+		}
+		all = append(all, spec)
+		for k, v := range spec {
+			if _, ok := out[k]; ok {
+				merge = false
+			}
+			out[k] = v
+		}
+	}
+	if merge {
+		return out
+	}
+	return map[string]any{"$and": all}
+}
+
+// helper function to convert range bound or list value into number if possible
+func number(val string) any {
+	if i, err := strconv.ParseInt(val, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(val, 64); err == nil {
+		return f
+	}
+	return val
+}
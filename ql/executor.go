@@ -0,0 +1,280 @@
+package ql
+
+// executor module runs QL queries across FOXDEN services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	services "github.com/CHESSComputing/golib/services"
+)
+
+// DefaultServiceTimeout defines default timeout of service queries
+var DefaultServiceTimeout = 30 * time.Second
+
+// Executor executes QL queries across FOXDEN services, it sends per-service
+// specs of QLManager.ServiceQueries to services in parallel and joins their
+// records on JoinKey, e.g.
+//
+//	exe := &Executor{Manager: &qlMgr, Request: services.NewHttpRequest("read", 0)}
+//	results, err := exe.Execute(query, services.ServiceQuery{Limit: 100})
+type Executor struct {
+	Manager  *QLManager               // QL manager with service map
+	Request  *services.HttpRequest    // HTTP request used as template for token and headers
+	URLs     map[string]string        // service URLs, by default taken from Services configuration
+	Path     string                   // search end-point of services, default /search
+	Timeout  time.Duration            // timeout of service queries, default DefaultServiceTimeout
+	Timeouts map[string]time.Duration // timeouts of individual services
+	JoinKey  string                   // key to join records of services, default did
+	Dialects map[string]string        // SQL dialects of services backed by SQL databases
+}
+
+// ServiceStatus represents status of federated query of individual service
+type ServiceStatus struct {
+	Service  string         `json:"service"`
+	URL      string         `json:"url"`
+	Spec     map[string]any `json:"spec"`
+	NRecords int            `json:"nrecords"`
+	Duration time.Duration  `json:"duration"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// FederatedResults represents results of federated query
+type FederatedResults struct {
+	NRecords int                      `json:"nrecords"`
+	Records  []map[string]any         `json:"records"`
+	Services map[string]ServiceStatus `json:"services"`
+}
+
+// Failed returns names of services which failed to execute the query
+func (r *FederatedResults) Failed() []string {
+	var out []string
+	for srv, status := range r.Services {
+		if status.Error != "" {
+			out = append(out, srv)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Execute splits query into per-service specs, queries services in parallel
+// and returns records of successful services joined on JoinKey. Failures of
+// individual services are reported in FederatedResults.Services and error is
+// returned only if query can not be parsed or all services failed.
+func (e *Executor) Execute(query string, sq services.ServiceQuery) (*FederatedResults, error) {
+	if e.Manager == nil {
+		return nil, errors.New("[golib.ql.Executor.Execute] no QL manager")
+	}
+	sqMap, err := e.Manager.ServiceQueries(query)
+	if err != nil {
+		return nil, fmt.Errorf("[golib.ql.Executor.Execute] ServiceQueries error: %w", err)
+	}
+	if len(sqMap) == 0 {
+		return nil, fmt.Errorf("[golib.ql.Executor.Execute] no services for query '%s'", query)
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := &FederatedResults{Services: make(map[string]ServiceStatus)}
+	srvRecords := make(map[string][]map[string]any)
+	for srv, spec := range sqMap {
+		wg.Add(1)
+		go func(srv string, spec map[string]any) {
+			defer wg.Done()
+			time0 := time.Now()
+			status := ServiceStatus{Service: srv, Spec: spec}
+			records, err := e.query(srv, spec, sq, &status)
+			status.Duration = time.Since(time0)
+			status.NRecords = len(records)
+			if err != nil {
+				log.Printf("ERROR: service %s failed to execute query '%s', error %v", srv, query, err)
+				status.Error = err.Error()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			results.Services[srv] = status
+			if err == nil {
+				srvRecords[srv] = records
+			}
+		}(srv, spec)
+	}
+	wg.Wait()
+	if len(srvRecords) == 0 {
+		return results, fmt.Errorf("[golib.ql.Executor.Execute] all services %v failed", results.Failed())
+	}
+	results.Records = joinRecords(srvRecords, e.joinKey())
+	results.NRecords = len(results.Records)
+	return results, nil
+}
+
+// helper function to return join key
+func (e *Executor) joinKey() string {
+	if e.JoinKey == "" {
+		return "did"
+	}
+	return e.JoinKey
+}
+
+// helper function to send spec to the service and return its records
+func (e *Executor) query(srv string, spec map[string]any, sq services.ServiceQuery, status *ServiceStatus) ([]map[string]any, error) {
+	rurl, err := e.serviceURL(srv)
+	if err != nil {
+		return nil, err
+	}
+	path := e.Path
+	if path == "" {
+		path = "/search"
+	}
+	rurl = strings.TrimSuffix(rurl, "/") + path
+	status.URL = rurl
+
+	// services parse query themselves, therefore we send spec as query
+	query, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	sq.Query = string(query)
+	sq.Spec = spec
+	if dialect, ok := e.Dialects[srv]; ok {
+		// SQL services get WHERE clause of the spec along with its arguments
+		t := SQLTranslator{Dialect: dialect, Columns: e.Manager.SQLColumns(srv)}
+		if sq.SQL, sq.Args, err = t.Where(spec); err != nil {
+			return nil, err
+		}
+	}
+	data, err := json.Marshal(services.ServiceRequest{Client: "foxden", ServiceQuery: sq})
+	if err != nil {
+		return nil, err
+	}
+	// use individual request per service since timeouts differ
+	req := &services.HttpRequest{Scope: "read"}
+	if e.Request != nil {
+		*req = *e.Request
+	}
+	req.Timeout = e.Timeout
+	if req.Timeout == 0 {
+		req.Timeout = DefaultServiceTimeout
+	}
+	if t, ok := e.Timeouts[srv]; ok {
+		req.Timeout = t
+	}
+	resp, err := req.Post(rurl, "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("service %s returned HTTP status %d: %s", srv, resp.StatusCode, string(data))
+	}
+	return serviceRecords(data)
+}
+
+// helper function to parse records of service response, services respond
+// either with ServiceResponse or with list of records
+func serviceRecords(data []byte) ([]map[string]any, error) {
+	var records []map[string]any
+	if err := json.Unmarshal(data, &records); err == nil {
+		return records, nil
+	}
+	var response services.ServiceResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("unable to parse service response: %w", err)
+	}
+	if response.Status == "error" || response.Error != "" {
+		return nil, fmt.Errorf("service error: %s", response.Error)
+	}
+	return response.Results.Records, nil
+}
+
+// helper function to find URL of the service
+func (e *Executor) serviceURL(srv string) (string, error) {
+	if rurl, ok := e.URLs[srv]; ok {
+		return rurl, nil
+	}
+	if rurl := ServiceURL(srv); rurl != "" {
+		return rurl, nil
+	}
+	return "", fmt.Errorf("no URL of service %s", srv)
+}
+
+// ServiceURL returns URL of FOXDEN service from Services configuration,
+// e.g. MetaData service URL is defined by MetaDataUrl
+func ServiceURL(srv string) string {
+	if srvConfig.Config == nil {
+		return ""
+	}
+	val := reflect.ValueOf(srvConfig.Config.Services)
+	for i := 0; i < val.NumField(); i++ {
+		name := val.Type().Field(i).Name
+		if strings.EqualFold(name, srv+"URL") {
+			return val.Field(i).String()
+		}
+	}
+	return ""
+}
+
+// helper function to join records of services on given key, records of the
+// single service are returned as is, otherwise records present in all
+// services are merged with priority of services in alphabetical order
+func joinRecords(srvRecords map[string][]map[string]any, key string) []map[string]any {
+	var srvs []string
+	for srv := range srvRecords {
+		srvs = append(srvs, srv)
+	}
+	sort.Strings(srvs)
+	if len(srvs) == 1 {
+		return srvRecords[srvs[0]]
+	}
+	var order []string
+	joined := make(map[string]map[string]any)
+	counts := make(map[string]int)
+	for idx, srv := range srvs {
+		seen := make(map[string]bool)
+		for _, rec := range srvRecords[srv] {
+			val, ok := rec[key]
+			if !ok {
+				continue
+			}
+			id := fmt.Sprintf("%v", val)
+			if idx == 0 && !seen[id] {
+				order = append(order, id)
+				joined[id] = make(map[string]any)
+			}
+			out, ok := joined[id]
+			if !ok {
+				continue
+			}
+			for k, v := range rec {
+				if _, ok := out[k]; !ok {
+					out[k] = v
+				}
+			}
+			if !seen[id] {
+				seen[id] = true
+				counts[id]++
+			}
+		}
+	}
+	var records []map[string]any
+	for _, id := range order {
+		if counts[id] == len(srvs) {
+			records = append(records, joined[id])
+		}
+	}
+	return records
+}
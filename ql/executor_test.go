@@ -0,0 +1,83 @@
+package ql
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	services "github.com/CHESSComputing/golib/services"
+)
+
+// helper function to create test service which returns given records
+func testService(t *testing.T, records []map[string]any, delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req services.ServiceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("unable to decode service request: %v", err)
+		}
+		time.Sleep(delay)
+		resp := services.ServiceResponse{
+			Service: r.URL.Path,
+			Status:  "ok",
+			Results: services.ServiceResults{NRecords: len(records), Records: records},
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+// TestExecutor
+func TestExecutor(t *testing.T) {
+	meta := testService(t, []map[string]any{
+		{"did": "/a", "beamline": "3a"},
+		{"did": "/b", "beamline": "3a"},
+	}, 0)
+	defer meta.Close()
+	scans := testService(t, []map[string]any{
+		{"did": "/b", "scan": 2},
+		{"did": "/c", "scan": 3},
+	}, 0)
+	defer scans.Close()
+	slow := testService(t, nil, time.Second)
+	defer slow.Close()
+
+	qlMgr := &QLManager{Map: ServiceMap{
+		"MetaData":  []string{"beamline"},
+		"SpecScans": []string{"scan"},
+		"Slow":      []string{"sample"},
+	}}
+	exe := &Executor{
+		Manager:  qlMgr,
+		URLs:     map[string]string{"MetaData": meta.URL, "SpecScans": scans.URL, "Slow": slow.URL},
+		Timeouts: map[string]time.Duration{"Slow": 100 * time.Millisecond},
+	}
+
+	// join records of two services
+	results, err := exe.Execute("beamline:3a scan:2", services.ServiceQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []map[string]any{{"did": "/b", "beamline": "3a", "scan": float64(2)}}
+	if !reflect.DeepEqual(results.Records, expect) {
+		t.Errorf("wrong records %+v != %+v", results.Records, expect)
+	}
+	if results.Services["MetaData"].NRecords != 2 || results.Services["SpecScans"].NRecords != 2 {
+		t.Errorf("wrong service statuses %+v", results.Services)
+	}
+
+	// partial failure of slow service
+	results, err = exe.Execute("beamline:3a sample:x", services.ServiceQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(results.Failed(), []string{"Slow"}) || results.NRecords != 2 {
+		t.Errorf("unexpected results %+v", results)
+	}
+
+	// failure of all services
+	if _, err := exe.Execute("sample:x", services.ServiceQuery{}); err == nil {
+		t.Error("expected error of failed services")
+	}
+}
@@ -0,0 +1,239 @@
+package ql
+
+// explain module reports how QL query is parsed, typed and split across services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Explanation represents report of QL query processing
+type Explanation struct {
+	Query     string                    `json:"query"`
+	Canonical string                    `json:"canonical,omitempty"` // canonical form of parsed query
+	Tokens    []TokenInfo               `json:"tokens,omitempty"`
+	Keys      []KeyInfo                 `json:"keys"`
+	Spec      map[string]any            `json:"spec,omitempty"`
+	Services  map[string]map[string]any `json:"services,omitempty"` // per-service specs
+	Dropped   []DroppedKey              `json:"dropped,omitempty"`
+	Warnings  []string                  `json:"warnings,omitempty"` // query cost warnings
+	Error     string                    `json:"error,omitempty"`
+	Position  int                       `json:"position,omitempty"` // position of syntax error
+}
+
+// TokenInfo represents parsed token of QL query
+type TokenInfo struct {
+	Type     string `json:"type"`
+	Value    string `json:"value"`
+	Position int    `json:"position"`
+}
+
+// KeyInfo represents resolution of query key
+type KeyInfo struct {
+	Key       string   `json:"key"`
+	SchemaKey string   `json:"schema_key"`
+	DataType  string   `json:"type,omitempty"`
+	Condition any      `json:"condition,omitempty"` // MongoDB condition of the key
+	Decision  string   `json:"decision"`            // how value is matched, e.g. case-insensitive regex
+	Services  []string `json:"services,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// DroppedKey represents query key which can not be sent to any service
+type DroppedKey struct {
+	Key    string `json:"key"`
+	Reason string `json:"reason"`
+}
+
+// Explain returns report how given query is parsed, which schema keys and
+// data types are used, how it is split across services and which keys are
+// dropped. Errors of the query are reported within explanation.
+func (q *QLManager) Explain(query string) Explanation {
+	exp := Explanation{Query: query}
+	srvMap, keys := q.snapshot()
+	if strings.HasPrefix(strings.TrimSpace(query), "{") {
+		// MongoDB spec
+		spec, err := parseQuery(query, keys)
+		if err != nil {
+			exp.Error = err.Error()
+			return exp
+		}
+		for key, val := range spec {
+			info := KeyInfo{Key: key, SchemaKey: key, Condition: val, Decision: decision(val, "")}
+			info.DataType, _ = keys.keyType(key)
+			info.Services = keyServices(srvMap, key)
+			exp.Keys = append(exp.Keys, info)
+		}
+		sort.Slice(exp.Keys, func(i, j int) bool { return exp.Keys[i].Key < exp.Keys[j].Key })
+		exp.explainSpec(srvMap, spec)
+		return exp
+	}
+
+	node, tokens, err := parse(query)
+	for _, tok := range tokens {
+		pos := (&SyntaxError{Query: query, Offset: tok.offset}).Column()
+		exp.Tokens = append(exp.Tokens, TokenInfo{Type: tok.typ.String(), Value: tok.val, Position: pos})
+	}
+	if err != nil {
+		exp.setError(err)
+		return exp
+	}
+	exp.Canonical = node.String()
+	for _, leaf := range leaves(node) {
+		info := explainKey(srvMap, keys, leaf, query)
+		if info.Error != "" {
+			exp.Dropped = append(exp.Dropped, DroppedKey{Key: info.Key, Reason: info.Error})
+		} else if len(info.Services) == 0 {
+			exp.Dropped = append(exp.Dropped, DroppedKey{Key: info.Key, Reason: "key is not supported by any service"})
+		}
+		exp.Keys = append(exp.Keys, info)
+	}
+	spec, err := compile(node, query, keys)
+	if err != nil {
+		exp.setError(err)
+		return exp
+	}
+	exp.explainSpec(srvMap, spec)
+	return exp
+}
+
+// helper function to set error of explanation
+func (e *Explanation) setError(err error) {
+	e.Error = err.Error()
+	var serr *SyntaxError
+	if errors.As(err, &serr) {
+		e.Position = serr.Column()
+	}
+}
+
+// helper function to explain per-service specs and cost of compiled spec
+func (e *Explanation) explainSpec(srvMap ServiceMap, spec map[string]any) {
+	e.Spec = spec
+	sqMap, dropped := serviceSpecs(srvMap, spec)
+	e.Services = sqMap
+	for _, key := range dropped {
+		if !strings.HasPrefix(key, "$") {
+			// field keys are already reported
+			continue
+		}
+		e.Dropped = append(e.Dropped, DroppedKey{
+			Key:    key,
+			Reason: fmt.Sprintf("keys %v of operator are not supported by single service", specKeys(spec[key])),
+		})
+	}
+	if len(sqMap) > 1 {
+		e.Warnings = append(e.Warnings, fmt.Sprintf("query is executed by %d services and joined on did", len(sqMap)))
+	}
+	for _, info := range e.Keys {
+		switch {
+		case strings.HasPrefix(info.Decision, "case-insensitive regex"):
+			e.Warnings = append(e.Warnings, fmt.Sprintf("key %s uses case-insensitive regex which can not use index efficiently", info.Key))
+		case strings.HasPrefix(info.Decision, "wildcard regex"):
+			if cond, ok := info.Condition.(map[string]any); ok && strings.HasPrefix(fmt.Sprint(cond["$regex"]), "^.*") {
+				e.Warnings = append(e.Warnings, fmt.Sprintf("key %s uses leading wildcard which scans all values", info.Key))
+			}
+		}
+	}
+	if _, ok := spec["$nor"]; ok {
+		e.Warnings = append(e.Warnings, "negation can not use index efficiently")
+	}
+	if _, ok := spec["$text"]; ok {
+		e.Warnings = append(e.Warnings, "free text search requires text index of services")
+	}
+}
+
+// helper function to return leaf nodes of the query
+func leaves(node Node) []Node {
+	switch n := node.(type) {
+	case *BoolNode:
+		var out []Node
+		for _, child := range n.Nodes {
+			out = append(out, leaves(child)...)
+		}
+		return out
+	case *NotNode:
+		return leaves(n.Node)
+	}
+	return []Node{node}
+}
+
+// helper function to explain key of leaf node
+func explainKey(srvMap ServiceMap, keys *queryKeys, node Node, query string) KeyInfo {
+	var field string
+	switch n := node.(type) {
+	case *TermNode:
+		if n.Field == "" {
+			return KeyInfo{Key: "$text", SchemaKey: "$text", Condition: textTerm(n),
+				Decision: "free text search", Services: keyServices(srvMap, "$text")}
+		}
+		field = n.Field
+	case *RangeNode:
+		field = n.Field
+	case *ListNode:
+		field = n.Field
+	}
+	info := KeyInfo{Key: field, SchemaKey: keys.adjustKey(field)}
+	if field == "_id" {
+		info.SchemaKey = "_id"
+	}
+	dtype, known := keys.keyType(field)
+	info.DataType = dtype
+	if !known {
+		info.Error = unknownKey(field).Error()
+		info.Decision = "unknown key"
+		return info
+	}
+	spec, err := compile(node, query, keys)
+	if err != nil {
+		info.Error = err.Error()
+		info.Decision = "invalid value"
+		return info
+	}
+	info.Condition = spec[info.SchemaKey]
+	info.Decision = decision(info.Condition, dtype)
+	info.Services = keyServices(srvMap, info.SchemaKey)
+	return info
+}
+
+// helper function to return sorted services which support given key,
+// operators are supported by all services
+func keyServices(srvMap ServiceMap, key string) []string {
+	var out []string
+	for srv := range srvMap {
+		if strings.HasPrefix(key, "$") || keyAllowed(srvMap, key, srv) {
+			out = append(out, srv)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// helper function to describe how condition matches values
+func decision(cond any, dtype string) string {
+	if m, ok := cond.(map[string]any); ok {
+		if _, ok := m["$regex"]; ok {
+			if _, ok := m["$options"]; ok {
+				return "case-insensitive regex match of the whole value"
+			}
+			return "wildcard regex match"
+		}
+		if _, ok := m["$exists"]; ok {
+			return "key exists"
+		}
+		if vals, ok := m["$in"]; ok {
+			return fmt.Sprintf("match any of values %v", vals)
+		}
+		for _, op := range []string{"$gt", "$gte", "$lt", "$lte"} {
+			if _, ok := m[op]; ok {
+				return "range of values"
+			}
+		}
+		return "MongoDB operator"
+	}
+	if dtype != "" && !stringType(dtype) {
+		return fmt.Sprintf("exact match, value converted to %s (%T)", dtype, cond)
+	}
+	return "exact match"
+}
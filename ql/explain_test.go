@@ -0,0 +1,49 @@
+package ql
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestExplain
+func TestExplain(t *testing.T) {
+	t.Cleanup(resetKeys)
+	qlMgr := &QLManager{Map: ServiceMap{
+		"MetaData":  []string{"Beamline", "Sample"},
+		"SpecScans": []string{"Energy"},
+	}}
+	RegisterKeys(map[string]string{"Beamline": "string", "Sample": "string", "Energy": "float", "Cycle": "string"})
+
+	exp := qlMgr.Explain("beamline:3a energy:>10 cycle:2024-3")
+	if exp.Canonical != "beamline:3a AND energy:{10 TO *} AND cycle:2024-3" {
+		t.Errorf("wrong canonical query %s", exp.Canonical)
+	}
+	if len(exp.Tokens) != 10 || exp.Tokens[3].Type != "word" || exp.Tokens[5].Type != "compare" || exp.Tokens[5].Position != 20 {
+		t.Errorf("wrong tokens %+v", exp.Tokens)
+	}
+	if len(exp.Keys) != 3 || exp.Keys[0].SchemaKey != "Beamline" || exp.Keys[1].Decision != "range of values" {
+		t.Errorf("wrong keys %+v", exp.Keys)
+	}
+	if !reflect.DeepEqual(exp.Keys[0].Services, []string{"MetaData"}) {
+		t.Errorf("wrong services of key %+v", exp.Keys[0])
+	}
+	if !reflect.DeepEqual(exp.Dropped, []DroppedKey{{Key: "cycle", Reason: "key is not supported by any service"}}) {
+		t.Errorf("wrong dropped keys %+v", exp.Dropped)
+	}
+	if len(exp.Services) != 2 || exp.Services["SpecScans"]["Energy"] == nil {
+		t.Errorf("wrong service specs %+v", exp.Services)
+	}
+	if len(exp.Warnings) == 0 {
+		t.Error("expected cost warnings")
+	}
+
+	// unknown keys and syntax errors
+	exp = qlMgr.Explain("beamline:3a foo:1")
+	if exp.Error == "" || exp.Position != 13 || len(exp.Dropped) != 1 || exp.Dropped[0].Key != "foo" {
+		t.Errorf("wrong explanation of unknown key %+v", exp)
+	}
+	exp = qlMgr.Explain("beamline:(3a OR")
+	if exp.Error == "" || exp.Position != 16 {
+		t.Errorf("wrong explanation of syntax error %+v", exp)
+	}
+}
@@ -0,0 +1,230 @@
+package ql
+
+// lexer module of FOXDEN QueryLanguage (QL)
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SyntaxError represents error of QL query with its position
+type SyntaxError struct {
+	Query  string // input query
+	Offset int    // byte offset of the error within the query
+	Msg    string // error message
+}
+
+// Column returns 1-based position of the error in characters of the query
+func (e *SyntaxError) Column() int {
+	if e.Query == "" {
+		return e.Offset + 1
+	}
+	offset := e.Offset
+	if offset > len(e.Query) {
+		offset = len(e.Query)
+	}
+	return utf8.RuneCountInString(e.Query[:offset]) + 1
+}
+
+// Error implements error interface
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at position %d: %s", e.Column(), e.Msg)
+}
+
+// tokenType represents type of QL token
+type tokenType int
+
+// QL token types
+const (
+	tokEOF tokenType = iota
+	tokWord
+	tokPhrase
+	tokColon
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokLBrace
+	tokRBrace
+	tokAnd
+	tokOr
+	tokNot
+	tokCompare
+)
+
+// token type names used in query explanation
+var tokenNames = map[tokenType]string{
+	tokEOF: "eof", tokWord: "word", tokPhrase: "phrase", tokColon: "separator",
+	tokLParen: "lparen", tokRParen: "rparen", tokLBracket: "lbracket", tokRBracket: "rbracket",
+	tokLBrace: "lbrace", tokRBrace: "rbrace", tokAnd: "and", tokOr: "or", tokNot: "not",
+	tokCompare: "compare",
+}
+
+// String returns name of token type
+func (t tokenType) String() string {
+	return tokenNames[t]
+}
+
+// token represents QL token
+type token struct {
+	typ      tokenType
+	val      string // unescaped value of words, phrases and comparison operators
+	wildcard bool   // word contains unescaped * or ? wildcards
+	pattern  string // regular expression of wildcard word
+	offset   int    // byte offset of the token within the query
+}
+
+// String returns token description used in error messages
+func (t token) String() string {
+	switch t.typ {
+	case tokEOF:
+		return "end of query"
+	case tokPhrase:
+		return fmt.Sprintf("phrase %q", t.val)
+	case tokWord:
+		return fmt.Sprintf("%q", t.val)
+	}
+	return fmt.Sprintf("'%s'", t.val)
+}
+
+// lexer splits QL query into tokens, in value mode, i.e. after field
+// separator, colons and leading dashes are part of the words and comparison
+// operators are recognized
+type lexer struct {
+	input string
+	pos   int
+}
+
+// helper function to create syntax error at given offset
+func (l *lexer) errorf(offset int, format string, args ...any) error {
+	return &SyntaxError{Query: l.input, Offset: offset, Msg: fmt.Sprintf(format, args...)}
+}
+
+// scan returns next token of the query
+func (l *lexer) scan(value bool) (token, error) {
+	for l.pos < len(l.input) {
+		r, size := utf8.DecodeRuneInString(l.input[l.pos:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		l.pos += size
+	}
+	start := l.pos
+	if l.pos >= len(l.input) {
+		return token{typ: tokEOF, offset: start}, nil
+	}
+	rest := l.input[l.pos:]
+	punct := map[byte]tokenType{
+		'(': tokLParen, ')': tokRParen, '[': tokLBracket, ']': tokRBracket, '{': tokLBrace, '}': tokRBrace,
+	}
+	c := rest[0]
+	if typ, ok := punct[c]; ok {
+		l.pos++
+		return token{typ: typ, val: string(c), offset: start}, nil
+	}
+	switch {
+	case c == '"':
+		return l.scanPhrase()
+	case value && (c == '>' || c == '<'):
+		op := string(c)
+		if strings.HasPrefix(rest[1:], "=") {
+			op += "="
+		}
+		l.pos += len(op)
+		return token{typ: tokCompare, val: op, offset: start}, nil
+	case value:
+		return l.scanWord(value)
+	case c == ':':
+		l.pos++
+		return token{typ: tokColon, val: ":", offset: start}, nil
+	case strings.HasPrefix(rest, "&&"):
+		l.pos += 2
+		return token{typ: tokAnd, val: "&&", offset: start}, nil
+	case strings.HasPrefix(rest, "||"):
+		l.pos += 2
+		return token{typ: tokOr, val: "||", offset: start}, nil
+	case c == '!' || c == '-':
+		l.pos++
+		return token{typ: tokNot, val: string(c), offset: start}, nil
+	case c == '+':
+		// required term prefix is no-op since terms are joined by AND
+		l.pos++
+		return l.scan(value)
+	}
+	tok, err := l.scanWord(value)
+	if err != nil {
+		return tok, err
+	}
+	switch tok.val {
+	case "AND":
+		tok.typ = tokAnd
+	case "OR":
+		tok.typ = tokOr
+	case "NOT":
+		tok.typ = tokNot
+	}
+	return tok, nil
+}
+
+// helper function to scan quoted phrase
+func (l *lexer) scanPhrase() (token, error) {
+	start := l.pos
+	var sb strings.Builder
+	for l.pos++; l.pos < len(l.input); l.pos++ {
+		c := l.input[l.pos]
+		if c == '\\' && l.pos+1 < len(l.input) {
+			l.pos++
+			sb.WriteByte(l.input[l.pos])
+			continue
+		}
+		if c == '"' {
+			l.pos++
+			return token{typ: tokPhrase, val: sb.String(), offset: start}, nil
+		}
+		sb.WriteByte(c)
+	}
+	return token{}, l.errorf(start, "unterminated quoted phrase")
+}
+
+// helper function to scan word, backslash escapes special characters
+func (l *lexer) scanWord(value bool) (token, error) {
+	start := l.pos
+	stop := " \t\r\n()[]{}\""
+	if !value {
+		stop += ":"
+	}
+	tok := token{typ: tokWord, offset: start}
+	var sb, pat strings.Builder
+	for l.pos < len(l.input) {
+		r, size := utf8.DecodeRuneInString(l.input[l.pos:])
+		if unicode.IsSpace(r) || (r < utf8.RuneSelf && strings.ContainsRune(stop, r)) {
+			break
+		}
+		if r == '\\' {
+			if l.pos+1 >= len(l.input) {
+				return tok, l.errorf(l.pos, "escape character at end of query")
+			}
+			l.pos++
+			r, size = utf8.DecodeRuneInString(l.input[l.pos:])
+			pat.WriteString(regexp.QuoteMeta(string(r)))
+		} else if r == '*' {
+			tok.wildcard = true
+			pat.WriteString(".*")
+		} else if r == '?' {
+			tok.wildcard = true
+			pat.WriteString(".")
+		} else {
+			pat.WriteString(regexp.QuoteMeta(string(r)))
+		}
+		sb.WriteRune(r)
+		l.pos += size
+	}
+	tok.val = sb.String()
+	if tok.wildcard {
+		tok.pattern = "^" + pat.String() + "$"
+	}
+	return tok, nil
+}
@@ -0,0 +1,475 @@
+package ql
+
+// parser module of FOXDEN QueryLanguage (QL). The QL follows Lucene query
+// syntax:
+//
+//	query   := or
+//	or      := and (("OR" | "||") and)*
+//	and     := not (("AND" | "&&")? not)*
+//	not     := ("NOT" | "!" | "-") not | primary
+//	primary := "(" or ")" | field ":" value | word | phrase
+//	value   := word | phrase | "(" or ")" | range | list | (">" | ">=" | "<" | "<=") word
+//	range   := ("[" | "{") word "TO" word ("]" | "}")
+//	list    := "[" word ("," word)* "]"
+//
+// e.g. cycle:2024-3 AND (beamline:3a OR beamline:4b) -sample:"Fe Cu" energy:[10 TO 50] run:[1,2,3]
+// Words may contain * and ? wildcards, field:* matches records with the
+// field, and terms without field are used for free text search.
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Node represents node of QL query abstract syntax tree
+type Node interface {
+	Offset() int    // byte offset of the node within the query
+	String() string // canonical query of the node
+}
+
+// BoolNode represents AND or OR of several query nodes
+type BoolNode struct {
+	Op     string // AND or OR
+	Nodes  []Node
+	offset int
+}
+
+// NotNode represents negation of query node
+type NotNode struct {
+	Node   Node
+	offset int
+}
+
+// TermNode represents field:value term or free text term if field is empty
+type TermNode struct {
+	Field    string // field name, empty for free text
+	Value    string // unescaped value
+	Quoted   bool   // value is quoted phrase
+	Wildcard bool   // value contains * or ? wildcards
+	Pattern  string // regular expression of wildcard value
+	offset   int
+}
+
+// RangeNode represents range or comparison of field values, empty bound
+// means open range
+type RangeNode struct {
+	Field        string
+	Lower        string
+	Upper        string
+	IncludeLower bool
+	IncludeUpper bool
+	offset       int
+}
+
+// ListNode represents list of field values, i.e. field matches any of them
+type ListNode struct {
+	Field  string
+	Values []string
+	offset int
+}
+
+// Offset returns byte offset of the node within the query
+func (n *BoolNode) Offset() int { return n.offset }
+
+// Offset returns byte offset of the node within the query
+func (n *NotNode) Offset() int { return n.offset }
+
+// Offset returns byte offset of the node within the query
+func (n *TermNode) Offset() int { return n.offset }
+
+// Offset returns byte offset of the node within the query
+func (n *RangeNode) Offset() int { return n.offset }
+
+// Offset returns byte offset of the node within the query
+func (n *ListNode) Offset() int { return n.offset }
+
+// String returns canonical query of the node
+func (n *BoolNode) String() string {
+	var parts []string
+	for _, node := range n.Nodes {
+		if b, ok := node.(*BoolNode); ok && b.Op != n.Op {
+			parts = append(parts, "("+b.String()+")")
+		} else {
+			parts = append(parts, node.String())
+		}
+	}
+	return strings.Join(parts, " "+n.Op+" ")
+}
+
+// String returns canonical query of the node
+func (n *NotNode) String() string {
+	if _, ok := n.Node.(*BoolNode); ok {
+		return "NOT (" + n.Node.String() + ")"
+	}
+	return "NOT " + n.Node.String()
+}
+
+// String returns canonical query of the node
+func (n *TermNode) String() string {
+	val := n.Value
+	if n.Quoted {
+		val = quote(val)
+	} else if !n.Wildcard {
+		val = escape(val)
+	}
+	if n.Field == "" {
+		return val
+	}
+	return n.Field + Separator + val
+}
+
+// String returns canonical query of the node
+func (n *ListNode) String() string {
+	var vals []string
+	for _, v := range n.Values {
+		vals = append(vals, escape(v))
+	}
+	return n.Field + Separator + "[" + strings.Join(vals, ",") + "]"
+}
+
+// String returns canonical query of the node
+func (n *RangeNode) String() string {
+	lower, upper := "{", "}"
+	if n.IncludeLower {
+		lower = "["
+	}
+	if n.IncludeUpper {
+		upper = "]"
+	}
+	bound := func(v string) string {
+		if v == "" {
+			return "*"
+		}
+		return escape(v)
+	}
+	return fmt.Sprintf("%s%s%s%s TO %s%s", n.Field, Separator, lower, bound(n.Lower), bound(n.Upper), upper)
+}
+
+// helper function to quote phrase
+func quote(val string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(val) + `"`
+}
+
+// helper function to escape special characters of the word, words with
+// spaces are quoted
+func escape(val string) string {
+	if strings.ContainsAny(val, " \t\r\n") || val == "AND" || val == "OR" || val == "NOT" || val == "TO" {
+		return quote(val)
+	}
+	var sb strings.Builder
+	for i, r := range val {
+		if strings.ContainsRune(`\()[]{}":*?,`, r) || (i == 0 && strings.ContainsRune("-!+<>", r)) {
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// parser represents QL parser
+type parser struct {
+	lex    *lexer
+	tok    token
+	tokens []token // scanned tokens
+}
+
+// Parse parses QL query into abstract syntax tree, it returns *SyntaxError
+// with position of the error for malformed queries
+func Parse(query string) (Node, error) {
+	node, _, err := parse(query)
+	return node, err
+}
+
+// helper function to parse QL query, it returns scanned tokens as well
+func parse(query string) (Node, []token, error) {
+	p := &parser{lex: &lexer{input: query}}
+	node, err := p.parse()
+	return node, p.tokens, err
+}
+
+// helper function to parse query of the parser
+func (p *parser) parse() (Node, error) {
+	if err := p.next(false); err != nil {
+		return nil, err
+	}
+	if p.tok.typ == tokEOF {
+		return nil, p.lex.errorf(0, "empty query")
+	}
+	node, err := p.parseOr("")
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.typ != tokEOF {
+		return nil, p.unexpected()
+	}
+	return node, nil
+}
+
+// helper function to advance to next token
+func (p *parser) next(value bool) error {
+	tok, err := p.lex.scan(value)
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	if tok.typ != tokEOF {
+		p.tokens = append(p.tokens, tok)
+	}
+	return nil
+}
+
+// helper function to create error about unexpected token
+func (p *parser) unexpected() error {
+	switch p.tok.typ {
+	case tokRParen:
+		return p.lex.errorf(p.tok.offset, "unexpected ')' without matching '('")
+	case tokEOF:
+		return p.lex.errorf(p.tok.offset, "unexpected end of query")
+	}
+	return p.lex.errorf(p.tok.offset, "unexpected %s", p.tok)
+}
+
+// helper function to parse OR expression, field is set within field groups, e.g. field:(a OR b)
+func (p *parser) parseOr(field string) (Node, error) {
+	offset := p.tok.offset
+	node, err := p.parseAnd(field)
+	if err != nil {
+		return nil, err
+	}
+	nodes := []Node{node}
+	for p.tok.typ == tokOr {
+		if err := p.next(false); err != nil {
+			return nil, err
+		}
+		node, err := p.parseAnd(field)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return boolNode("OR", nodes, offset), nil
+}
+
+// helper function to parse AND expression, terms without operator are joined by AND
+func (p *parser) parseAnd(field string) (Node, error) {
+	offset := p.tok.offset
+	node, err := p.parseNot(field)
+	if err != nil {
+		return nil, err
+	}
+	nodes := []Node{node}
+	for {
+		switch p.tok.typ {
+		case tokAnd:
+			if err := p.next(false); err != nil {
+				return nil, err
+			}
+		case tokWord, tokPhrase, tokLParen, tokNot, tokLBracket, tokLBrace:
+		default:
+			return boolNode("AND", nodes, offset), nil
+		}
+		node, err := p.parseNot(field)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+}
+
+// helper function to create bool node, single node is returned as is and
+// nested nodes of the same operator are flattened
+func boolNode(op string, nodes []Node, offset int) Node {
+	if len(nodes) == 1 {
+		return nodes[0]
+	}
+	var out []Node
+	for _, node := range nodes {
+		if b, ok := node.(*BoolNode); ok && b.Op == op {
+			out = append(out, b.Nodes...)
+		} else {
+			out = append(out, node)
+		}
+	}
+	return &BoolNode{Op: op, Nodes: out, offset: offset}
+}
+
+// helper function to parse negation
+func (p *parser) parseNot(field string) (Node, error) {
+	if p.tok.typ != tokNot {
+		return p.parsePrimary(field)
+	}
+	offset := p.tok.offset
+	if err := p.next(false); err != nil {
+		return nil, err
+	}
+	node, err := p.parseNot(field)
+	if err != nil {
+		return nil, err
+	}
+	return &NotNode{Node: node, offset: offset}, nil
+}
+
+// helper function to parse group, term or field:value expression
+func (p *parser) parsePrimary(field string) (Node, error) {
+	tok := p.tok
+	switch tok.typ {
+	case tokLParen:
+		return p.parseGroup(field)
+	case tokPhrase:
+		if err := p.next(false); err != nil {
+			return nil, err
+		}
+		return &TermNode{Field: field, Value: tok.val, Quoted: true, offset: tok.offset}, nil
+	case tokLBracket, tokLBrace:
+		if field == "" {
+			return nil, p.lex.errorf(tok.offset, "range requires field, e.g. energy:[10 TO 50]")
+		}
+		return p.parseRange(field, tok.offset)
+	case tokWord:
+		if err := p.next(false); err != nil {
+			return nil, err
+		}
+		if p.tok.typ != tokColon {
+			return wordTerm(field, tok), nil
+		}
+		if field != "" {
+			return nil, p.lex.errorf(tok.offset, "field %s within group of field %s", tok.val, field)
+		}
+		if tok.val == "" || tok.wildcard {
+			return nil, p.lex.errorf(tok.offset, "wrong field name %q", tok.val)
+		}
+		if err := p.next(true); err != nil {
+			return nil, err
+		}
+		return p.parseValue(tok.val, tok.offset)
+	}
+	return nil, p.unexpected()
+}
+
+// helper function to create term node of a word
+func wordTerm(field string, tok token) *TermNode {
+	return &TermNode{Field: field, Value: tok.val, Wildcard: tok.wildcard, Pattern: tok.pattern, offset: tok.offset}
+}
+
+// helper function to parse parenthesized group
+func (p *parser) parseGroup(field string) (Node, error) {
+	offset := p.tok.offset
+	if err := p.next(false); err != nil {
+		return nil, err
+	}
+	if p.tok.typ == tokRParen {
+		return nil, p.lex.errorf(p.tok.offset, "empty group")
+	}
+	node, err := p.parseOr(field)
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.typ != tokRParen {
+		if p.tok.typ == tokEOF {
+			return nil, p.lex.errorf(offset, "missing ')' for '('")
+		}
+		return nil, p.lex.errorf(p.tok.offset, "expected ')' but found %s", p.tok)
+	}
+	if err := p.next(false); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// helper function to parse value of a field, current token is scanned in value mode
+func (p *parser) parseValue(field string, offset int) (Node, error) {
+	tok := p.tok
+	switch tok.typ {
+	case tokWord:
+		if err := p.next(false); err != nil {
+			return nil, err
+		}
+		node := wordTerm(field, tok)
+		node.offset = offset
+		return node, nil
+	case tokPhrase:
+		if err := p.next(false); err != nil {
+			return nil, err
+		}
+		return &TermNode{Field: field, Value: tok.val, Quoted: true, offset: offset}, nil
+	case tokLParen:
+		return p.parseGroup(field)
+	case tokLBracket, tokLBrace:
+		return p.parseRange(field, offset)
+	case tokCompare:
+		if err := p.next(true); err != nil {
+			return nil, err
+		}
+		if p.tok.typ != tokWord && p.tok.typ != tokPhrase {
+			return nil, p.lex.errorf(p.tok.offset, "expected value after %s but found %s", tok.val, p.tok)
+		}
+		node := &RangeNode{Field: field, offset: offset}
+		switch tok.val {
+		case ">", ">=":
+			node.Lower, node.IncludeLower = p.tok.val, tok.val == ">="
+		default:
+			node.Upper, node.IncludeUpper = p.tok.val, tok.val == "<="
+		}
+		if err := p.next(false); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case tokEOF:
+		return nil, p.lex.errorf(tok.offset, "missing value of field %s", field)
+	}
+	return nil, p.lex.errorf(tok.offset, "unexpected %s as value of field %s", tok, field)
+}
+
+// helper function to parse range or list of field values
+func (p *parser) parseRange(field string, offset int) (Node, error) {
+	node := &RangeNode{Field: field, IncludeLower: p.tok.typ == tokLBracket, offset: offset}
+	bound := func() (string, error) {
+		if err := p.next(true); err != nil {
+			return "", err
+		}
+		tok := p.tok
+		if tok.typ != tokWord && tok.typ != tokPhrase {
+			return "", p.lex.errorf(tok.offset, "expected range bound but found %s", tok)
+		}
+		if tok.typ == tokWord && tok.val == "*" {
+			return "", nil
+		}
+		return tok.val, nil
+	}
+	var err error
+	if node.Lower, err = bound(); err != nil {
+		return nil, err
+	}
+	if err := p.next(true); err != nil {
+		return nil, err
+	}
+	if p.tok.typ == tokRBracket && node.IncludeLower && node.Lower != "" {
+		// list of values, e.g. run:[1,2,3]
+		if err := p.next(false); err != nil {
+			return nil, err
+		}
+		return &ListNode{Field: field, Values: strings.Split(node.Lower, ","), offset: offset}, nil
+	}
+	if p.tok.typ != tokWord || p.tok.val != "TO" {
+		return nil, p.lex.errorf(p.tok.offset, "expected TO but found %s", p.tok)
+	}
+	if node.Upper, err = bound(); err != nil {
+		return nil, err
+	}
+	if err := p.next(true); err != nil {
+		return nil, err
+	}
+	switch p.tok.typ {
+	case tokRBracket:
+		node.IncludeUpper = true
+	case tokRBrace:
+	case tokEOF:
+		return nil, p.lex.errorf(offset, "unterminated range")
+	default:
+		return nil, p.lex.errorf(p.tok.offset, "expected ']' or '}' but found %s", p.tok)
+	}
+	if err := p.next(false); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
@@ -0,0 +1,121 @@
+package ql
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// TestParse
+func TestParse(t *testing.T) {
+	tests := []struct {
+		query string
+		canon string
+	}{
+		{"bla:1", "bla:1"},
+		{"a:1 b:2", "a:1 AND b:2"},
+		{"a:1 && b:2 || c:3", "(a:1 AND b:2) OR c:3"},
+		{"a:1 AND (b:2 OR b:3)", "a:1 AND (b:2 OR b:3)"},
+		{"beamline:(3a OR 4b)", "beamline:3a OR beamline:4b"},
+		{"-a:1 NOT b:2 !c:3", "NOT a:1 AND NOT b:2 AND NOT c:3"},
+		{`sample:"Fe Cu"`, `sample:"Fe Cu"`},
+		{`title:"say \"hi\""`, `title:"say \"hi\""`},
+		{"energy:[10 TO 50}", "energy:[10 TO 50}"},
+		{"energy:{* TO 50]", "energy:{* TO 50]"},
+		{"energy:>=10", "energy:[10 TO *}"},
+		{"energy:<5", "energy:{* TO 5}"},
+		{"run:[1,2,3]", "run:[1,2,3]"},
+		{"sample:Fe*", "sample:Fe*"},
+		{`did:/beamline=3a/btr=x\:1`, `did:/beamline=3a/btr=x\:1`},
+		{"date:-1", `date:\-1`},
+		{"foo bar", "foo AND bar"},
+	}
+	for _, tc := range tests {
+		node, err := Parse(tc.query)
+		if err != nil {
+			t.Errorf("query %s, error %v", tc.query, err)
+			continue
+		}
+		if node.String() != tc.canon {
+			t.Errorf("query %s, wrong canonical query %s != %s", tc.query, node.String(), tc.canon)
+		}
+		// canonical query should be parsed into the same tree
+		if again, err := Parse(node.String()); err != nil || again.String() != tc.canon {
+			t.Errorf("query %s, canonical query %s is not stable: %v", tc.query, node.String(), err)
+		}
+	}
+}
+
+// TestParseErrors
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query  string
+		column int
+	}{
+		{"   ", 1},
+		{"(a:1 OR b:2", 1},
+		{"a:1)", 4},
+		{"a:", 3},
+		{"a:[1 TO", 8},
+		{"a:[1 TO 2", 1},
+		{"a:[1 5]", 6},
+		{`a:"Fe`, 3},
+		{"a:1 OR", 7},
+		{"[1 TO 2]", 1},
+		{"é:1 OR (", 9},
+	}
+	for _, tc := range tests {
+		_, err := Parse(tc.query)
+		var serr *SyntaxError
+		if !errors.As(err, &serr) {
+			t.Errorf("query %q, expected syntax error but got %v", tc.query, err)
+			continue
+		}
+		if serr.Column() != tc.column {
+			t.Errorf("query %q, wrong error position %d != %d, error %v", tc.query, serr.Column(), tc.column, err)
+		}
+	}
+}
+
+// TestCompile
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		query string
+		spec  map[string]any
+	}{
+		{"bla:1 foo:2", map[string]any{"bla": "1", "foo": "2"}},
+		{"bla:1 OR bla:2", map[string]any{"$or": []any{
+			map[string]any{"bla": "1"}, map[string]any{"bla": "2"}}}},
+		{"bla:1 bla:2", map[string]any{"$and": []any{
+			map[string]any{"bla": "1"}, map[string]any{"bla": "2"}}}},
+		{"-bla:1", map[string]any{"$nor": []any{map[string]any{"bla": "1"}}}},
+		{"energy:[10 TO 50.5}", map[string]any{"energy": map[string]any{"$gte": int64(10), "$lt": 50.5}}},
+		{"run:[1,2,x]", map[string]any{"run": map[string]any{"$in": []any{int64(1), int64(2), "x"}}}},
+		{"sample:Fe.C*", map[string]any{"sample": map[string]any{"$regex": `^Fe\.C.*$`}}},
+		{"sample:*", map[string]any{"sample": map[string]any{"$exists": true}}},
+		{`foo "bar baz" -qux`, map[string]any{"$text": map[string]any{"$search": `foo "bar baz" -qux`}}},
+		{"bla:1 foo", map[string]any{"bla": "1", "$text": map[string]any{"$search": "foo"}}},
+	}
+	for _, tc := range tests {
+		node, err := Parse(tc.query)
+		if err != nil {
+			t.Errorf("query %s, parse error %v", tc.query, err)
+			continue
+		}
+		spec, err := Compile(node)
+		if err != nil {
+			t.Errorf("query %s, compile error %v", tc.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(spec, tc.spec) {
+			t.Errorf("query %s, wrong spec %+v != %+v", tc.query, spec, tc.spec)
+		}
+	}
+
+	// free text can not be part of OR with field terms
+	_, err := ParseQuery("bla:1 OR foo")
+	var serr *SyntaxError
+	if !errors.As(err, &serr) || serr.Column() != 1 {
+		t.Errorf("expected syntax error at position 1, got %v", err)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"regexp"
 	"strings"
 
 	utils "github.com/CHESSComputing/golib/utils"
@@ -25,19 +26,24 @@ var Separator = ":"
 // SchemaKeys represents full collection of schema keys across all schemas
 type SchemaKeys map[string]string
 
-// schema keys map
-var _schemaKeys SchemaKeys
-
-// ParseQuery function provides basic parser for user queries and return
-// results in bson dictionary
+// ParseQuery function parses user queries and return results in bson
+// dictionary. It supports MongoDB specs in JSON and QL queries, see Parse
+// for QL syntax, syntax errors are reported as *SyntaxError. Query keys are
+// checked against keys registered by RegisterKeys, use QLManager.ParseQuery
+// to check them against keys of QL service map as well.
 func ParseQuery(query string) (map[string]any, error) {
+	return parseQuery(query, defaultKeys())
+}
+
+// helper function to parse query with given known keys
+func parseQuery(query string, keys *queryKeys) (map[string]any, error) {
 	spec := make(map[string]any)
 	if strings.TrimSpace(query) == "" {
 		log.Println("WARNING: empty query string")
 		return nil, errors.New("empty query")
 	}
 	// support MongoDB specs
-	if strings.Contains(query, "{") {
+	if strings.HasPrefix(strings.TrimSpace(query), "{") {
 		err := json.Unmarshal([]byte(query), &spec)
 		if err != nil {
 			log.Printf("ERROR: unable to parse input query '%s' error %v", query, err)
@@ -55,53 +61,38 @@ func ParseQuery(query string) (map[string]any, error) {
 		if _, ok := spec["$or"]; ok {
 			return spec, nil
 		}
-		return adjustQuery(spec), nil
+		spec, err = keys.adjustQuery(spec)
+		if err != nil {
+			log.Printf("ERROR: unable to adjust input query '%s' error %v", query, err)
+			return nil, fmt.Errorf("[golib.ql.ParseQuery] adjustQuery error: %w", err)
+		}
+		return spec, nil
 	}
 
-	// query as key:value
-	if strings.Contains(query, Separator) {
-		arr := strings.SplitN(query, Separator, 2)
-		var vals []string
-		key := arr[0]
-		last := arr[len(arr)-1]
-		for i := 0; i < len(arr); i++ {
-			if len(arr) > i+1 {
-				vals = strings.Split(arr[i+1], " ")
-				if arr[i+1] == last {
-					spec[key] = last
-					break
-				}
-				if len(vals) > 0 {
-					values := strings.Join(vals[:len(vals)-1], " ")
-					spec[key] = values
-					key = vals[len(vals)-1]
-				} else {
-					spec[key] = vals[0]
-					break
-				}
-			} else {
-				vals = arr[i:]
-				values := strings.Join(vals, " ")
-				spec[key] = values
-				break
-			}
-		}
-	} else {
-		// or, query as free text
-		spec["$text"] = map[string]any{"$search": query}
+	// query in QL syntax, e.g. key:value, (key:a OR key:b), free text
+	node, err := Parse(query)
+	if err != nil {
+		log.Printf("ERROR: unable to parse input query '%s' error %v", query, err)
+		return nil, fmt.Errorf("[golib.ql.ParseQuery] Parse error: %w", err)
+	}
+	spec, err = compile(node, query, keys)
+	if err != nil {
+		log.Printf("ERROR: unable to compile input query '%s' error %v", query, err)
+		return nil, fmt.Errorf("[golib.ql.ParseQuery] Compile error: %w", err)
 	}
 	if Verbose > 0 {
 		log.Printf("input query %s spec=%v", query, spec)
 	}
-	return adjustQuery(spec), nil
+	return spec, nil
 }
 
 // helper function to adjust query keys
-func adjustQuery(spec map[string]any) map[string]any {
-	// TODO: take input query and change its keys to match schema
+func (k *queryKeys) adjustQuery(spec map[string]any) (map[string]any, error) {
 	nspec := make(map[string]any)
 	for kkk, val := range spec {
+		// keep MongoDB operators, e.g. $text
 		if strings.HasPrefix(kkk, "$") {
+			nspec[kkk] = val
 			continue
 		}
 		// adjust query _id to object id type
@@ -111,32 +102,101 @@ func adjustQuery(spec map[string]any) map[string]any {
 			}
 			continue
 		}
-		// look-up appropriate schema key
-		if key, ok := _schemaKeys[strings.ToLower(kkk)]; ok {
-			// create regex for value if it is the string
-			sval := fmt.Sprintf("%v", val)
-			if utils.PatternInt.MatchString(sval) || utils.PatternFloat.MatchString(sval) {
-				nspec[key] = val
-			} else {
-				//                 pat, err := regexp.Compile(fmt.Sprintf("/^%s$/i", sval))
-				pat := fmt.Sprintf("^%s$", sval)
-				nspec[key] = map[string]any{"$regex": pat, "$options": "i"}
-			}
-		} else {
-			if kkk != "did" {
-				log.Printf("WARNING: unable to find matching schema key for %s, existing schema keys %+v", kkk, _schemaKeys)
-			}
-			nspec[kkk] = val
-		}
-		// watch for regex
-		if strings.Contains(fmt.Sprintf("%v", val), "*") {
-			// replace asterisk pattern with proper regexp
-			v := strings.Replace(fmt.Sprintf("%v", val), "*", ".*", -1)
-			nspec[kkk] = map[string]any{"$regex": v}
+		key, value, err := k.adjustValue(kkk, val)
+		if err != nil {
+			return nil, err
 		}
+		nspec[key] = value
 	}
 	if Verbose > 0 {
 		log.Printf("Perform adjustment of input query from %+v to %+v", spec, nspec)
 	}
-	return nspec
+	return nspec, nil
+}
+
+// helper function to adjust query key to schema key and its value to data
+// type of the key, values of string keys are converted to case-insensitive
+// regex unless keys are configured for exact match
+func (k *queryKeys) adjustValue(kkk string, val any) (string, any, error) {
+	dtype, known := k.keyType(kkk)
+	if !known {
+		return kkk, nil, unknownKey(kkk)
+	}
+	if vals, ok := val.([]any); ok {
+		return k.adjustList(kkk, vals)
+	}
+	sval, isString := val.(string)
+	if isString && strings.Contains(sval, "*") {
+		// replace asterisk pattern with anchored regexp like QL wildcards
+		return k.adjustKey(kkk), map[string]any{"$regex": wildcardPattern(sval)}, nil
+	}
+	if dtype != "" && !isString {
+		// values of other types, e.g. numbers or operators of MongoDB specs
+		return k.adjustKey(kkk), val, nil
+	}
+	if dtype != "" && !stringType(dtype) {
+		v, err := coerce(dtype, sval)
+		if err != nil {
+			return kkk, nil, fmt.Errorf("key '%s' of type %s: %w", kkk, dtype, err)
+		}
+		return k.adjustKey(kkk), v, nil
+	}
+	// look-up appropriate schema key
+	if key, ok := k.schemaKey(kkk); ok {
+		if exactMatch(kkk) {
+			return key, val, nil
+		}
+		// create regex for value if it is the string
+		sval := fmt.Sprintf("%v", val)
+		if utils.PatternInt.MatchString(sval) || utils.PatternFloat.MatchString(sval) {
+			return key, val, nil
+		}
+		//                 pat, err := regexp.Compile(fmt.Sprintf("/^%s$/i", sval))
+		pat := fmt.Sprintf("^%s$", sval)
+		return key, map[string]any{"$regex": pat, "$options": "i"}, nil
+	}
+	if kkk != "did" {
+		log.Printf("WARNING: unable to find matching schema key for %s", kkk)
+	}
+	return kkk, val, nil
+}
+
+// helper function to adjust list of query values into $in condition, values
+// are adjusted like single values, i.e. string values become case-insensitive
+// regexes unless key is configured for exact match
+func (k *queryKeys) adjustList(kkk string, vals []any) (string, any, error) {
+	key := k.adjustKey(kkk)
+	in := make([]any, 0, len(vals))
+	for _, v := range vals {
+		vkey, val, err := k.adjustValue(kkk, v)
+		if err != nil {
+			return kkk, nil, err
+		}
+		key = vkey
+		// MongoDB $in operator accepts regexes as BSON regular expressions
+		if re, ok := val.(map[string]any); ok {
+			if pat, ok := re["$regex"].(string); ok {
+				options, _ := re["$options"].(string)
+				val = bson.Regex{Pattern: pat, Options: options}
+			}
+		}
+		in = append(in, val)
+	}
+	return key, map[string]any{"$in": in}, nil
+}
+
+// helper function to convert value with asterisk wildcards into anchored
+// regex, other characters are escaped like in QL queries
+func wildcardPattern(val string) string {
+	parts := strings.Split(val, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return "^" + strings.Join(parts, ".*") + "$"
+}
+
+// helper function to adjust query key to schema key
+func (k *queryKeys) adjustKey(kkk string) string {
+	key, _ := k.schemaKey(kkk)
+	return key
 }
@@ -36,7 +36,7 @@ func TestParseQuery(t *testing.T) {
 	}
 	if val, ok := spec["did"]; ok {
 		vvv := fmt.Sprintf("%v", val)
-		if vvv != "map[$regex: /beamline.*]" {
+		if vvv != "map[$regex:^ /beamline.*$]" {
 			msg := fmt.Sprintf("parsed query %s does not fit regexp", vvv)
 			t.Error(msg)
 		}
@@ -52,4 +52,22 @@ func TestParseQuery(t *testing.T) {
 	if len(spec) == 0 {
 		t.Errorf("empty spec")
 	}
+
+	// test 5: wildcards of QL queries and MongoDB specs match whole values
+	for query, pattern := range map[string]string{
+		"beamline:3*":         "^3.*$",
+		"beamline:3?":         "^3.$",
+		"beamline:3.a*":       `^3\.a.*$`,
+		`{"beamline":"3*"}`:   "^3.*$",
+		`{"beamline":"3.a*"}`: `^3\.a.*$`,
+	} {
+		spec, err := ParseQuery(query)
+		if err != nil {
+			t.Fatalf("query %s, error %v", query, err)
+		}
+		val, _ := spec["beamline"].(map[string]any)
+		if pat, _ := val["$regex"].(string); pat != pattern {
+			t.Errorf("query %s, wrong regex %q, expect %q", query, pat, pattern)
+		}
+	}
 }
@@ -0,0 +1,141 @@
+package ql
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestWatch
+func TestWatch(t *testing.T) {
+	t.Cleanup(resetKeys)
+	ReloadDelay = 10 * time.Millisecond
+	fname := filepath.Join(t.TempDir(), "ql_services.json")
+	write := func(data string) {
+		if err := os.WriteFile(fname, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var qlMgr QLManager
+	if err := qlMgr.Init(fname); err == nil {
+		t.Error("expected error of missing service map")
+	}
+	write(`[{"key":"foo","service":"s1"}]`)
+	if err := qlMgr.Init(fname); err != nil {
+		t.Fatal(err)
+	}
+	v1 := qlMgr.Version()
+	if v1.Version != 1 || v1.Hash == "" || v1.Error != "" {
+		t.Errorf("wrong version %+v", v1)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go qlMgr.Watch(ctx, fname)
+	time.Sleep(50 * time.Millisecond)
+	wait := func(cond func() bool) bool {
+		for i := 0; i < 200; i++ {
+			if cond() {
+				return true
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return false
+	}
+
+	// valid change is loaded
+	write(`[{"key":"foo","service":"s1"},{"key":"bar","service":"s2"}]`)
+	if !wait(func() bool { return qlMgr.Version().Version == 2 }) {
+		t.Fatalf("service map is not reloaded, version %+v", qlMgr.Version())
+	}
+	if !reflect.DeepEqual(qlMgr.Services(), []string{"s1", "s2"}) {
+		t.Errorf("wrong services %v", qlMgr.Services())
+	}
+
+	// invalid change keeps last good map
+	write(`[{"key":"foo","service":"s1"},{"key":"foo","service":"s1"}]`)
+	if !wait(func() bool { return qlMgr.Version().Error != "" }) {
+		t.Fatal("invalid service map is not reported")
+	}
+	if v := qlMgr.Version(); v.Version != 2 || !reflect.DeepEqual(qlMgr.Keys("s2"), []string{"bar"}) {
+		t.Errorf("last good service map is not kept, version %+v", v)
+	}
+}
+
+// TestReloadKeys tests that reload replaces known keys and their data types
+// while queries are parsed, run it with -race flag
+func TestReloadKeys(t *testing.T) {
+	t.Cleanup(resetKeys)
+	fname := filepath.Join(t.TempDir(), "ql_services.json")
+	maps := []string{
+		`[{"key":"foo","service":"s1","type":"int64"},{"key":"bar","service":"s2","type":"string"}]`,
+		`[{"key":"foo","service":"s1","type":"string"},{"key":"baz","service":"s2","type":"string"}]`,
+	}
+	var qlMgr QLManager
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for ctx.Err() == nil {
+			qlMgr.ParseQuery("foo:1 bar:abc")
+			qlMgr.ParseQuery(`{"Foo": "1", "baz": "x"}`)
+			qlMgr.ServiceQueries("foo:1")
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		if err := os.WriteFile(fname, []byte(maps[i%2]), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := qlMgr.reload(fname); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cancel()
+	<-done
+
+	// last loaded map defines known keys and their data types
+	_, keys := qlMgr.snapshot()
+	if _, known := keys.keyType("bar"); known {
+		t.Error("key bar of previous service map is still known")
+	}
+	if dtype, known := keys.keyType("baz"); !known || dtype != "string" {
+		t.Errorf("wrong type of baz %s known %v", dtype, known)
+	}
+	// foo is string key now, while it was integer key before
+	spec, err := qlMgr.ParseQuery("foo:abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := spec["foo"].(map[string]any); !ok {
+		t.Errorf("foo should be string key matched by regex, got %+v", spec)
+	}
+
+	// failed reload keeps keys of last good map
+	if err := os.WriteFile(fname, []byte(`[{"key":"qux","service":"s1"},{"key":"qux","service":"s1"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := qlMgr.reload(fname); err == nil {
+		t.Fatal("expected error of invalid service map")
+	}
+	if _, err := qlMgr.ParseQuery("baz:x"); err != nil {
+		t.Errorf("keys of last good map are not kept, error %v", err)
+	}
+	if _, err := qlMgr.ParseQuery("qux:x"); err == nil {
+		t.Error("key qux of invalid service map is known")
+	}
+
+	// keys of other QL manager do not affect this one
+	var other QLManager
+	other.Map = ServiceMap{"s3": []string{"qux"}}
+	other.keys = newMapKeys(map[string]string{"qux": "int64"})
+	if _, err := other.ParseQuery("qux:1"); err != nil {
+		t.Error(err)
+	}
+	if _, err := qlMgr.ParseQuery("qux:1"); err == nil {
+		t.Error("key qux of other QL manager is known")
+	}
+}
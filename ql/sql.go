@@ -0,0 +1,304 @@
+package ql
+
+// sql module translates QL query specs into SQL WHERE clauses
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// SQL dialects supported by SQLTranslator
+const (
+	SQLite   = "sqlite3"
+	MySQL    = "mysql"
+	Postgres = "postgres"
+)
+
+// SQLTranslator translates MongoDB specs produced by ParseQuery into
+// parameterized SQL WHERE clauses, e.g.
+//
+//	t := SQLTranslator{Dialect: Postgres, Columns: qlMgr.SQLColumns("DataBookkeeping")}
+//	where, args, err := t.Where(spec)
+//	rows, err := db.Query("SELECT * FROM files WHERE "+where, args...)
+type SQLTranslator struct {
+	Dialect string            // SQL dialect: sqlite3, mysql or postgres
+	Columns map[string]string // mapping of query keys to table columns
+}
+
+// SQLColumns returns mapping of query keys to SQL columns of given service.
+// Columns are defined by QLRecord.DBType in form of sql or sql:table.column,
+// keys with sql type are mapped to columns of the same name.
+func (q *QLManager) SQLColumns(srv string) map[string]string {
+	columns := make(map[string]string)
+	_, records := q.current()
+	for _, rec := range records {
+		if rec.Service != srv {
+			continue
+		}
+		dbtype, column, _ := strings.Cut(rec.DBType, ":")
+		if !strings.EqualFold(dbtype, "sql") {
+			continue
+		}
+		if column == "" {
+			column = rec.Key
+		}
+		columns[rec.Key] = column
+	}
+	return columns
+}
+
+// Where returns SQL WHERE clause and its bind arguments for given spec,
+// placeholders follow the dialect, i.e. ? for SQLite and MySQL and $N for
+// Postgres
+func (t *SQLTranslator) Where(spec map[string]any) (string, []any, error) {
+	switch t.Dialect {
+	case SQLite, "sqlite", MySQL, Postgres:
+	default:
+		return "", nil, fmt.Errorf("[golib.ql.SQLTranslator.Where] unsupported SQL dialect '%s'", t.Dialect)
+	}
+	var args []any
+	where, err := t.where(spec, &args)
+	if err != nil {
+		return "", nil, fmt.Errorf("[golib.ql.SQLTranslator.Where] error: %w", err)
+	}
+	if where == "" {
+		where = "1=1"
+	}
+	return where, args, nil
+}
+
+// helper function to add bind argument and return its placeholder
+func (t *SQLTranslator) bind(args *[]any, val any) string {
+	*args = append(*args, val)
+	if t.Dialect == Postgres {
+		return fmt.Sprintf("$%d", len(*args))
+	}
+	return "?"
+}
+
+// helper function to find column of the key
+func (t *SQLTranslator) column(key string) (string, error) {
+	if col, ok := t.Columns[key]; ok {
+		return col, nil
+	}
+	for k, col := range t.Columns {
+		if strings.EqualFold(k, key) {
+			return col, nil
+		}
+	}
+	return "", fmt.Errorf("key '%s' has no SQL column", key)
+}
+
+// helper function to translate spec into conditions joined by AND
+func (t *SQLTranslator) where(spec map[string]any, args *[]any) (string, error) {
+	var keys []string
+	for k := range spec {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var conds []string
+	for _, key := range keys {
+		val := spec[key]
+		var cond string
+		var err error
+		switch key {
+		case "$and", "$or", "$nor":
+			cond, err = t.logical(key, val, args)
+		case "$text":
+			err = errors.New("free text search is not supported by SQL services")
+		default:
+			if strings.HasPrefix(key, "$") {
+				err = fmt.Errorf("unsupported operator %s", key)
+				break
+			}
+			var col string
+			if col, err = t.column(key); err == nil {
+				cond, err = t.condition(col, val, args)
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+		conds = append(conds, cond)
+	}
+	return strings.Join(conds, " AND "), nil
+}
+
+// helper function to translate logical operator
+func (t *SQLTranslator) logical(op string, val any, args *[]any) (string, error) {
+	items, ok := val.([]any)
+	if !ok || len(items) == 0 {
+		return "", fmt.Errorf("operator %s requires non-empty list", op)
+	}
+	var conds []string
+	for _, item := range items {
+		spec, ok := item.(map[string]any)
+		if !ok {
+			return "", fmt.Errorf("operator %s requires list of specs", op)
+		}
+		cond, err := t.where(spec, args)
+		if err != nil {
+			return "", err
+		}
+		conds = append(conds, "("+cond+")")
+	}
+	switch op {
+	case "$and":
+		return strings.Join(conds, " AND "), nil
+	case "$or":
+		return "(" + strings.Join(conds, " OR ") + ")", nil
+	}
+	return "NOT (" + strings.Join(conds, " OR ") + ")", nil
+}
+
+// helper function to translate condition of the column
+func (t *SQLTranslator) condition(col string, val any, args *[]any) (string, error) {
+	ops, ok := val.(map[string]any)
+	if !ok {
+		if val == nil {
+			return col + " IS NULL", nil
+		}
+		return col + " = " + t.bind(args, val), nil
+	}
+	if pat, ok := ops["$regex"]; ok {
+		options, _ := ops["$options"].(string)
+		return t.regex(col, fmt.Sprintf("%v", pat), strings.Contains(options, "i"), args)
+	}
+	var names []string
+	for op := range ops {
+		names = append(names, op)
+	}
+	sort.Strings(names)
+	comparisons := map[string]string{"$gt": ">", "$gte": ">=", "$lt": "<", "$lte": "<=", "$ne": "<>", "$eq": "="}
+	var conds []string
+	for _, op := range names {
+		arg := ops[op]
+		if cmp, ok := comparisons[op]; ok {
+			conds = append(conds, col+" "+cmp+" "+t.bind(args, arg))
+			continue
+		}
+		switch op {
+		case "$in", "$nin":
+			vals, ok := arg.([]any)
+			if !ok || len(vals) == 0 {
+				return "", fmt.Errorf("operator %s requires non-empty list", op)
+			}
+			cond, err := t.list(col, op, vals, args)
+			if err != nil {
+				return "", err
+			}
+			conds = append(conds, cond)
+		case "$exists":
+			if exists, _ := arg.(bool); exists {
+				conds = append(conds, col+" IS NOT NULL")
+			} else {
+				conds = append(conds, col+" IS NULL")
+			}
+		default:
+			return "", fmt.Errorf("unsupported operator %s of column %s", op, col)
+		}
+	}
+	return strings.Join(conds, " AND "), nil
+}
+
+// helper function to translate $in and $nin lists, plain values are bound
+// into IN list while regex values, e.g. of string lists produced by
+// ParseQuery, are OR'ed (or AND'ed negated for $nin) regex conditions
+func (t *SQLTranslator) list(col, op string, vals []any, args *[]any) (string, error) {
+	var binds, terms []string
+	for _, v := range vals {
+		if re, ok := v.(bson.Regex); ok {
+			cond, err := t.regex(col, re.Pattern, strings.Contains(re.Options, "i"), args)
+			if err != nil {
+				return "", err
+			}
+			if op == "$nin" {
+				cond = "NOT (" + cond + ")"
+			}
+			terms = append(terms, cond)
+			continue
+		}
+		binds = append(binds, t.bind(args, v))
+	}
+	if len(binds) > 0 {
+		in := " IN ("
+		if op == "$nin" {
+			in = " NOT IN ("
+		}
+		terms = append([]string{col + in + strings.Join(binds, ", ") + ")"}, terms...)
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	if op == "$nin" {
+		return "(" + strings.Join(terms, " AND ") + ")", nil
+	}
+	return "(" + strings.Join(terms, " OR ") + ")", nil
+}
+
+// helper function to translate regular expression, anchored patterns with
+// wildcards are translated into LIKE, other patterns require regex support
+// of MySQL or Postgres
+func (t *SQLTranslator) regex(col, pat string, nocase bool, args *[]any) (string, error) {
+	if like, ok := likePattern(pat); ok {
+		if nocase {
+			return "LOWER(" + col + ") LIKE LOWER(" + t.bind(args, like) + ") ESCAPE '\\'", nil
+		}
+		return col + " LIKE " + t.bind(args, like) + " ESCAPE '\\'", nil
+	}
+	switch t.Dialect {
+	case MySQL:
+		// MySQL regex is case-insensitive for case-insensitive collations
+		return col + " REGEXP " + t.bind(args, pat), nil
+	case Postgres:
+		if nocase {
+			return col + " ~* " + t.bind(args, pat), nil
+		}
+		return col + " ~ " + t.bind(args, pat), nil
+	}
+	return "", fmt.Errorf("regex %s of column %s is not supported by %s", pat, col, t.Dialect)
+}
+
+// helper function to convert anchored regex with .* and . wildcards into
+// LIKE pattern
+func likePattern(pat string) (string, bool) {
+	if !strings.HasPrefix(pat, "^") || !strings.HasSuffix(pat, "$") || strings.HasSuffix(pat, `\$`) {
+		return "", false
+	}
+	pat = pat[1 : len(pat)-1]
+	var sb strings.Builder
+	for i := 0; i < len(pat); i++ {
+		c := pat[i]
+		switch {
+		case c == '\\' && i+1 < len(pat):
+			i++
+			c = pat[i]
+			if strings.IndexByte(`.*+?()[]{}|^$\`, c) < 0 {
+				// escape classes like \d can not be expressed by LIKE
+				return "", false
+			}
+			if c == '\\' {
+				sb.WriteString(`\\`)
+			} else {
+				sb.WriteByte(c)
+			}
+		case c == '.' && i+1 < len(pat) && pat[i+1] == '*':
+			i++
+			sb.WriteByte('%')
+		case c == '.':
+			sb.WriteByte('_')
+		case c == '%' || c == '_':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case strings.IndexByte(`*+?()[]{}|^$`, c) >= 0:
+			return "", false
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String(), true
+}
@@ -0,0 +1,107 @@
+package ql
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// TestSQLTranslator
+func TestSQLTranslator(t *testing.T) {
+	qlMgr := &QLManager{Records: []QLRecord{
+		{Key: "did", Service: "DataBookkeeping", DBType: "sql:datasets.did"},
+		{Key: "run", Service: "DataBookkeeping", DBType: "sql"},
+		{Key: "site", Service: "DataBookkeeping", DBType: "SQL:sites.name"},
+		{Key: "beamline", Service: "MetaData", DBType: "mongo"},
+	}}
+	columns := qlMgr.SQLColumns("DataBookkeeping")
+	expect := map[string]string{"did": "datasets.did", "run": "run", "site": "sites.name"}
+	if !reflect.DeepEqual(columns, expect) {
+		t.Fatalf("wrong columns %v != %v", columns, expect)
+	}
+	tests := []struct {
+		dialect string
+		query   string
+		where   string
+		args    []any
+	}{
+		{SQLite, "did:/beamline=3a*", `datasets.did LIKE ? ESCAPE '\'`, []any{"/beamline=3a%"}},
+		{Postgres, "run:[1 TO 10} site:cornell",
+			"run >= $1 AND run < $2 AND sites.name = $3", []any{int64(1), int64(10), "cornell"}},
+		{MySQL, "run:[1,2] OR -site:*",
+			"((run IN (?, ?)) OR (NOT ((sites.name IS NOT NULL))))", []any{int64(1), int64(2)}},
+		{Postgres, "did:/a_b?", `datasets.did LIKE $1 ESCAPE '\'`, []any{`/a\_b_`}},
+	}
+	for _, tc := range tests {
+		spec, err := ParseQuery(tc.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr := SQLTranslator{Dialect: tc.dialect, Columns: columns}
+		where, args, err := tr.Where(spec)
+		if err != nil {
+			t.Errorf("query %s, error %v", tc.query, err)
+			continue
+		}
+		if where != tc.where || !reflect.DeepEqual(args, tc.args) {
+			t.Errorf("query %s, wrong SQL %s %v != %s %v", tc.query, where, args, tc.where, tc.args)
+		}
+	}
+
+	// regex which can not be expressed by LIKE
+	spec := map[string]any{"site": map[string]any{"$regex": `^c\d+`}}
+	if _, _, err := (&SQLTranslator{Dialect: SQLite, Columns: columns}).Where(spec); err == nil {
+		t.Error("expected error of regex in SQLite")
+	}
+	where, _, err := (&SQLTranslator{Dialect: Postgres, Columns: columns}).Where(spec)
+	if err != nil || where != "sites.name ~ $1" {
+		t.Errorf("wrong Postgres regex %s, error %v", where, err)
+	}
+	// unknown columns and free text
+	for _, query := range []string{"beamline:3a", "cornell"} {
+		spec, _ := ParseQuery(query)
+		if _, _, err := (&SQLTranslator{Dialect: MySQL, Columns: columns}).Where(spec); err == nil {
+			t.Errorf("query %s, expected error", query)
+		}
+	}
+
+	// string lists are converted by ParseQuery into $in lists of regexes
+	t.Cleanup(resetKeys)
+	RegisterKeys(map[string]string{"site": "string"})
+	lists := []struct {
+		dialect string
+		query   string
+		where   string
+		args    []any
+	}{
+		{Postgres, "site:[cornell,slac]",
+			`(LOWER(sites.name) LIKE LOWER($1) ESCAPE '\' OR LOWER(sites.name) LIKE LOWER($2) ESCAPE '\')`,
+			[]any{"cornell", "slac"}},
+		{SQLite, "-site:[cornell,slac]",
+			`NOT (((LOWER(sites.name) LIKE LOWER(?) ESCAPE '\' OR LOWER(sites.name) LIKE LOWER(?) ESCAPE '\')))`,
+			[]any{"cornell", "slac"}},
+	}
+	for _, tc := range lists {
+		spec, err := ParseQuery(tc.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr := SQLTranslator{Dialect: tc.dialect, Columns: columns}
+		where, args, err := tr.Where(spec)
+		if err != nil {
+			t.Errorf("query %s, error %v", tc.query, err)
+			continue
+		}
+		if where != tc.where || !reflect.DeepEqual(args, tc.args) {
+			t.Errorf("query %s, wrong SQL %s %v != %s %v", tc.query, where, args, tc.where, tc.args)
+		}
+	}
+	// negated regexes of $nin list are AND'ed
+	spec = map[string]any{"site": map[string]any{"$nin": []any{"lbnl", bson.Regex{Pattern: "^sl.*$", Options: "i"}}}}
+	where, args, err := (&SQLTranslator{Dialect: MySQL, Columns: columns}).Where(spec)
+	expectWhere := `(sites.name NOT IN (?) AND NOT (LOWER(sites.name) LIKE LOWER(?) ESCAPE '\'))`
+	if err != nil || where != expectWhere || !reflect.DeepEqual(args, []any{"lbnl", "sl%"}) {
+		t.Errorf("wrong $nin SQL %s %v != %s, error %v", where, args, expectWhere, err)
+	}
+}
@@ -1,69 +1,232 @@
 package ql
 
 import (
+	"context"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	utils "github.com/CHESSComputing/golib/utils"
+	"github.com/fsnotify/fsnotify"
 )
 
 // ServiceMap defines FOXDEN service QL mapping
 type ServiceMap map[string][]string
 
-// QLManager represents QL manager
+// QLManager represents QL manager, its service map, records and query keys
+// are replaced atomically when service map file is reloaded. Map and Records
+// fields must not be accessed directly once Watch is running, use Keys,
+// Services and QLRecords methods instead.
 type QLManager struct {
 	Map     ServiceMap
 	Records []QLRecord
+
+	mu      sync.RWMutex
+	keys    *keyRegistry // query keys and data types of service map
+	version MapVersion
+}
+
+// MapVersion represents version of loaded service map
+type MapVersion struct {
+	File    string    `json:"file"`
+	Version int       `json:"version"` // number of successful loads
+	Hash    string    `json:"hash"`    // sha256 of service map file
+	Loaded  time.Time `json:"loaded"`
+	Error   string    `json:"error,omitempty"` // error of last failed reload
 }
 
-// Init function loads service map from given file name
+// Init function loads service map from given file name. The file is
+// validated before new map replaces current one, if loading fails the
+// current map is kept.
 func (q *QLManager) Init(fname string) error {
-	if q.Map == nil {
-		q.Map = make(ServiceMap)
-	}
-	file, err := os.Open(fname)
-	if err != nil {
-		log.Fatal(err)
+	data, err := os.ReadFile(fname)
+	if err == nil {
+		err = q.load(fname, data)
+	} else {
+		err = fmt.Errorf("[golib.ql.QLManager.Init] os.ReadFile error: %w", err)
 	}
-	defer file.Close()
-	data, err := io.ReadAll(file)
 	if err != nil {
-		return fmt.Errorf("[golib.ql.QLManager.Init] io.ReadAll error: %w", err)
+		q.mu.Lock()
+		q.version.Error = err.Error()
+		q.mu.Unlock()
 	}
+	return err
+}
 
+// helper function to validate and load service map data
+func (q *QLManager) load(fname string, data []byte) error {
 	// each record in QL.ServiceMapFile has the following form:[QLRecord1, QLRecord2]
-	srvMap := make(ServiceMap)
 	var records []QLRecord
-	err = json.Unmarshal(data, &records)
+	err := json.Unmarshal(data, &records)
 	if err != nil {
 		return fmt.Errorf("[golib.ql.QLManager.Init] json.Unmarshal error: %w", err)
 	}
+	if err := validateRecords(records); err != nil {
+		return fmt.Errorf("[golib.ql.QLManager.Init] validation error: %w", err)
+	}
+	srvMap := make(ServiceMap)
 	for _, rec := range records {
 		// collect ql kys for each service
-		if qlKeys, ok := srvMap[rec.Service]; ok {
-			qlKeys = append(qlKeys, rec.Key)
-			srvMap[rec.Service] = qlKeys
-		} else {
-			srvMap[rec.Service] = []string{rec.Key}
+		srvMap[rec.Service] = append(srvMap[rec.Service], rec.Key)
+	}
+	for _, keys := range srvMap {
+		sort.Strings(keys)
+	}
+	// QL keys and their data types are used to coerce query values
+	types := make(map[string]string)
+	for _, rec := range records {
+		if t, ok := types[rec.Key]; !ok || t == "" || t == "N/A" {
+			types[rec.Key] = rec.DataType
 		}
 	}
+
+	keys := newMapKeys(types)
+
+	// replace keys, service map and records together
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.keys = keys
 	q.Map = srvMap
 	q.Records = records
+	q.version = MapVersion{
+		File:    fname,
+		Version: q.version.Version + 1,
+		Hash:    fmt.Sprintf("%x", sha256.Sum256(data)),
+		Loaded:  time.Now(),
+	}
+	return nil
+}
+
+// helper function to validate QL records
+func validateRecords(records []QLRecord) error {
+	if len(records) == 0 {
+		return errors.New("no QL records")
+	}
+	seen := make(map[string]bool)
+	for i, rec := range records {
+		if rec.Key == "" || rec.Service == "" {
+			return fmt.Errorf("record %d has no key or service: %+v", i, rec)
+		}
+		id := rec.Service + "/" + rec.Key
+		if seen[id] {
+			return fmt.Errorf("duplicate key %s of service %s", rec.Key, rec.Service)
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// Version returns version of loaded service map
+func (q *QLManager) Version() MapVersion {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.version
+}
+
+// Watch reloads service map when given file changes until context is
+// done. Invalid files are reported and current map is kept. It is
+// usually started in goroutine after Init, e.g. go qlMgr.Watch(ctx, fname)
+func (q *QLManager) Watch(ctx context.Context, fname string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("[golib.ql.QLManager.Watch] fsnotify.NewWatcher error: %w", err)
+	}
+	defer watcher.Close()
+	// watch directory since editors often replace files
+	fname = filepath.Clean(fname)
+	if err := watcher.Add(filepath.Dir(fname)); err != nil {
+		return fmt.Errorf("[golib.ql.QLManager.Watch] watcher.Add error: %w", err)
+	}
+	var timer <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Clean(event.Name) != fname || event.Has(fsnotify.Chmod) {
+				continue
+			}
+			// wait for series of writes to finish
+			timer = time.After(ReloadDelay)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Println("ERROR: QL service map watcher", err)
+		case <-timer:
+			timer = nil
+			if err := q.reload(fname); err != nil {
+				log.Printf("ERROR: unable to reload QL service map %s, keep version %d, error %v", fname, q.Version().Version, err)
+			}
+		}
+	}
+}
+
+// ReloadDelay defines delay of service map reload after file change
+var ReloadDelay = 500 * time.Millisecond
+
+// helper function to reload service map if its content changed
+func (q *QLManager) reload(fname string) error {
+	data, err := os.ReadFile(fname)
+	if err == nil && fmt.Sprintf("%x", sha256.Sum256(data)) == q.Version().Hash {
+		return nil
+	}
+	if err := q.Init(fname); err != nil {
+		return err
+	}
+	v := q.Version()
+	log.Printf("reloaded QL service map %s version %d hash %s", fname, v.Version, v.Hash)
 	return nil
 }
 
+// helper function to return current service map and records
+func (q *QLManager) current() (ServiceMap, []QLRecord) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.Map, q.Records
+}
+
+// helper function to return current service map and its query keys
+func (q *QLManager) snapshot() (ServiceMap, *queryKeys) {
+	q.mu.RLock()
+	mapKeys := q.keys
+	srvMap := q.Map
+	q.mu.RUnlock()
+	if mapKeys == nil {
+		mapKeys = &keyRegistry{}
+	}
+	return srvMap, &queryKeys{mapKeys: mapKeys, keys: registeredKeys()}
+}
+
+// ParseQuery parses user query like ParseQuery function, query keys are
+// checked against keys of current service map and keys registered by
+// RegisterKeys
+func (q *QLManager) ParseQuery(query string) (map[string]any, error) {
+	_, keys := q.snapshot()
+	return parseQuery(query, keys)
+}
+
+// QLRecords returns copy of current QL records
+func (q *QLManager) QLRecords() []QLRecord {
+	_, records := q.current()
+	return append([]QLRecord{}, records...)
+}
+
 // Keys provides list of keys associated with FOXDEN service name
 func (q *QLManager) Keys(srv string) []string {
-	var keys []string
-	if val, ok := q.Map[srv]; ok {
-		sort.Strings(val)
-		return val
-	}
+	srvMap, _ := q.current()
+	keys := append([]string{}, srvMap[srv]...)
 	sort.Strings(keys)
 	return keys
 }
@@ -71,7 +234,8 @@ func (q *QLManager) Keys(srv string) []string {
 // Services returns list of services known to QL manager
 func (q *QLManager) Services() []string {
 	var srv []string
-	for k, _ := range q.Map {
+	srvMap, _ := q.current()
+	for k, _ := range srvMap {
 		srv = append(srv, k)
 	}
 	sort.Strings(srv)
@@ -80,14 +244,38 @@ func (q *QLManager) Services() []string {
 
 // ServiceQueries parses given query string into list of service queries
 func (q *QLManager) ServiceQueries(query string) (map[string]map[string]any, error) {
-	sqMap := make(map[string]map[string]any)
-	spec, err := ParseQuery(query)
+	// use service map and query keys of the same service map version
+	srvMap, keys := q.snapshot()
+	spec, err := parseQuery(query, keys)
 	if err != nil {
 		return nil, fmt.Errorf("[golib.ql.QLManager.ServiceQueries] ParseQuery error: %w", err)
 	}
+	sqMap, dropped := serviceSpecs(srvMap, spec)
+	if len(dropped) > 0 {
+		return nil, fmt.Errorf("[golib.ql.QLManager.ServiceQueries] query key '%s' is not supported by any service", dropped[0])
+	}
+	return sqMap, nil
+}
+
+// helper function to split spec into per-service specs of given service
+// map, it returns sorted keys of spec which are not supported by any service
+func serviceSpecs(srvMap ServiceMap, spec map[string]any) (map[string]map[string]any, []string) {
+	sqMap := make(map[string]map[string]any)
+	var dropped []string
 	for key, smap := range spec {
-		for srv, _ := range q.Map {
-			if allowed := q.QueryKeyAllowed(key, srv); allowed {
+		// operators, e.g. $or, are sent to services which support all their keys
+		keys := []string{key}
+		if strings.HasPrefix(key, "$") {
+			keys = specKeys(smap)
+		}
+		found := false
+		for srv, _ := range srvMap {
+			allowed := true
+			for _, k := range keys {
+				allowed = allowed && keyAllowed(srvMap, k, srv)
+			}
+			if allowed {
+				found = true
 				if val, ok := sqMap[srv]; ok {
 					val[key] = smap
 					sqMap[srv] = val
@@ -96,8 +284,32 @@ func (q *QLManager) ServiceQueries(query string) (map[string]map[string]any, err
 				}
 			}
 		}
+		if !found {
+			dropped = append(dropped, key)
+		}
 	}
-	return sqMap, nil
+	sort.Strings(dropped)
+	return sqMap, dropped
+}
+
+// helper function to collect field keys of MongoDB spec operators
+func specKeys(val any) []string {
+	var keys []string
+	switch v := val.(type) {
+	case []any:
+		for _, item := range v {
+			keys = append(keys, specKeys(item)...)
+		}
+	case map[string]any:
+		for k, item := range v {
+			if strings.HasPrefix(k, "$") {
+				keys = append(keys, specKeys(item)...)
+			} else {
+				keys = append(keys, k)
+			}
+		}
+	}
+	return keys
 }
 
 // Determines if a key from a user query is allowed for querying the given service.
@@ -106,7 +318,13 @@ func (q *QLManager) ServiceQueries(query string) (map[string]map[string]any, err
 // of the allowed service query keys followed by a ".". The latter matching condition
 // allows for queries on nested fields with dot notation.
 func (q *QLManager) QueryKeyAllowed(key string, service string) bool {
-	if service_keys, ok := q.Map[service]; ok {
+	srvMap, _ := q.current()
+	return keyAllowed(srvMap, key, service)
+}
+
+// helper function to check if key is allowed by service of given service map
+func keyAllowed(srvMap ServiceMap, key string, service string) bool {
+	if service_keys, ok := srvMap[service]; ok {
 		if utils.InList(key, service_keys) {
 			return true
 		}
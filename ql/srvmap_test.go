@@ -10,6 +10,7 @@ import (
 
 // TestServiceMap
 func TestServiceMap(t *testing.T) {
+	t.Cleanup(resetKeys)
 	srv1 := "service1"
 	srvKeys1 := []string{"foo", "bla"}
 	srv2 := "service2"
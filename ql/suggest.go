@@ -0,0 +1,232 @@
+package ql
+
+// suggest module provides autocompletion of QL queries
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Suggestion represents completion of partial QL query
+type Suggestion struct {
+	Query       string `json:"query"` // completed query
+	Value       string `json:"value"` // suggested key or value
+	Kind        string `json:"kind"`  // key, value or correction
+	Description string `json:"description,omitempty"`
+}
+
+// Suggester provides suggestions of QL keys and their values for partial
+// queries, e.g.
+//
+//	s := &Suggester{Manager: &qlMgr, Distinct: func(key string) ([]any, error) {
+//		return mongo.Distinct(dbname, collname, key)
+//	}}
+//	suggestions := s.Suggest("beamline:3a sam")
+type Suggester struct {
+	Manager     *QLManager                      // QL manager with QL records
+	Distinct    func(key string) ([]any, error) // source of known values of the key
+	MaxValues   int                             // max number of distinct values to suggest values of the key, default 50
+	MaxDistance int                             // max edit distance of key corrections, default 2
+	Limit       int                             // max number of suggestions, default 10
+	CacheTTL    time.Duration                   // cache lifetime of distinct values, default 10 minutes
+
+	mu    sync.Mutex
+	cache map[string]distinctValues
+}
+
+// distinctValues represents cached distinct values of the key
+type distinctValues struct {
+	values  []string
+	expires time.Time
+}
+
+// Suggest returns suggestions for the last term of partial query: keys
+// matching its prefix, known values of low-cardinality keys after key
+// separator and corrections of mistyped keys
+func (s *Suggester) Suggest(query string) []Suggestion {
+	start := strings.LastIndexFunc(query, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '('
+	}) + 1
+	base, term := query[:start], query[start:]
+	// keep negation prefix of the term
+	if idx := strings.IndexFunc(term, func(r rune) bool { return !strings.ContainsRune("-!+", r) }); idx > 0 {
+		base, term = base+term[:idx], term[idx:]
+	}
+	var out []Suggestion
+	if idx := strings.Index(term, Separator); idx > 0 {
+		key, prefix := term[:idx], strings.Trim(term[idx+len(Separator):], `"`)
+		if rec, ok := s.record(key); ok {
+			for _, val := range s.values(rec.Key) {
+				if strings.HasPrefix(strings.ToLower(val), strings.ToLower(prefix)) {
+					out = append(out, Suggestion{
+						Query: base + rec.Key + Separator + escape(val),
+						Value: val,
+						Kind:  "value",
+					})
+				}
+			}
+			return s.limit(out)
+		}
+		for _, rec := range s.corrections(key) {
+			out = append(out, Suggestion{
+				Query:       base + rec.Key + Separator + term[idx+len(Separator):],
+				Value:       rec.Key,
+				Kind:        "correction",
+				Description: rec.Description,
+			})
+		}
+		return s.limit(out)
+	}
+	for _, rec := range s.records() {
+		if strings.HasPrefix(strings.ToLower(rec.Key), strings.ToLower(term)) {
+			out = append(out, Suggestion{
+				Query:       base + rec.Key + Separator,
+				Value:       rec.Key,
+				Kind:        "key",
+				Description: rec.Description,
+			})
+		}
+	}
+	if len(out) == 0 && term != "" {
+		for _, rec := range s.corrections(term) {
+			out = append(out, Suggestion{
+				Query:       base + rec.Key + Separator,
+				Value:       rec.Key,
+				Kind:        "correction",
+				Description: rec.Description,
+			})
+		}
+	}
+	return s.limit(out)
+}
+
+// helper function to limit number of suggestions
+func (s *Suggester) limit(out []Suggestion) []Suggestion {
+	limit := s.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+	if len(out) > limit {
+		return out[:limit]
+	}
+	return out
+}
+
+// helper function to return QL records with unique keys sorted by key
+func (s *Suggester) records() []QLRecord {
+	var out []QLRecord
+	if s.Manager == nil {
+		return out
+	}
+	seen := make(map[string]bool)
+	_, records := s.Manager.current()
+	for _, rec := range records {
+		if !seen[rec.Key] {
+			seen[rec.Key] = true
+			out = append(out, rec)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+// helper function to find QL record of the key
+func (s *Suggester) record(key string) (QLRecord, bool) {
+	for _, rec := range s.records() {
+		if strings.EqualFold(rec.Key, key) {
+			return rec, true
+		}
+	}
+	return QLRecord{}, false
+}
+
+// helper function to return QL records of keys close to given key, records
+// are sorted by edit distance
+func (s *Suggester) corrections(key string) []QLRecord {
+	maxDistance := s.MaxDistance
+	if maxDistance <= 0 {
+		maxDistance = 2
+	}
+	distances := make(map[string]int)
+	var out []QLRecord
+	for _, rec := range s.records() {
+		d := editDistance(strings.ToLower(key), strings.ToLower(rec.Key))
+		if d <= maxDistance {
+			distances[rec.Key] = d
+			out = append(out, rec)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return distances[out[i].Key] < distances[out[j].Key]
+	})
+	return out
+}
+
+// helper function to return sorted distinct values of low-cardinality key
+func (s *Suggester) values(key string) []string {
+	if s.Distinct == nil {
+		return nil
+	}
+	s.mu.Lock()
+	v, ok := s.cache[key]
+	s.mu.Unlock()
+	if ok && time.Now().Before(v.expires) {
+		return v.values
+	}
+	ttl := s.CacheTTL
+	if ttl == 0 {
+		ttl = 10 * time.Minute
+	}
+	maxValues := s.MaxValues
+	if maxValues <= 0 {
+		maxValues = 50
+	}
+	var values []string
+	// do not hold the lock while distinct values are fetched from database
+	vals, err := s.Distinct(key)
+	if err != nil {
+		log.Printf("ERROR: unable to get distinct values of %s, error %v", key, err)
+		return nil
+	}
+	// high-cardinality keys are cached without values
+	if len(vals) <= maxValues {
+		for _, v := range vals {
+			values = append(values, fmt.Sprintf("%v", v))
+		}
+		sort.Strings(values)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cache == nil {
+		s.cache = make(map[string]distinctValues)
+	}
+	s.cache[key] = distinctValues{values: values, expires: time.Now().Add(ttl)}
+	return values
+}
+
+// helper function to calculate Levenshtein edit distance of two strings
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,97 @@
+package ql
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestSuggest
+func TestSuggest(t *testing.T) {
+	calls := 0
+	s := &Suggester{
+		Manager: &QLManager{Records: []QLRecord{
+			{Key: "beamline", Service: "MetaData", Description: "beamline name"},
+			{Key: "beam_energy", Service: "MetaData"},
+			{Key: "sample", Service: "MetaData"},
+			{Key: "did", Service: "MetaData"},
+			{Key: "beamline", Service: "SpecScans"},
+		}},
+		Distinct: func(key string) ([]any, error) {
+			calls++
+			if key == "did" {
+				return []any{"/a", "/b", "/c"}, nil
+			}
+			return []any{"4b", "3a", "id3a"}, nil
+		},
+		MaxValues: 3,
+	}
+	tests := []struct {
+		query  string
+		expect []string
+	}{
+		{"bea", []string{"beam_energy:", "beamline:"}},
+		{"sample:x -bea", []string{"sample:x -beam_energy:", "sample:x -beamline:"}},
+		{"(beamline:3", []string{"(beamline:3a"}},
+		{"beamline:", []string{"beamline:3a", "beamline:4b", "beamline:id3a"}},
+		{"smaple", []string{"sample:"}},
+		{"bemline:3a", []string{"beamline:3a"}},
+		{"xyz", nil},
+	}
+	for _, tc := range tests {
+		var got []string
+		for _, s := range s.Suggest(tc.query) {
+			got = append(got, s.Query)
+		}
+		if !reflect.DeepEqual(got, tc.expect) {
+			t.Errorf("query %s, wrong suggestions %v != %v", tc.query, got, tc.expect)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("distinct values are not cached, %d calls", calls)
+	}
+	// values of high-cardinality keys are not suggested
+	s.MaxValues = 2
+	if out := s.Suggest("did:/"); len(out) != 0 {
+		t.Errorf("unexpected suggestions %+v", out)
+	}
+	if d := editDistance("kitten", "sitting"); d != 3 {
+		t.Errorf("wrong edit distance %d", d)
+	}
+}
+
+// TestSuggestSlowDistinct tests that slow distinct values of one key do not
+// block suggestions of other keys
+func TestSuggestSlowDistinct(t *testing.T) {
+	block := make(chan struct{})
+	s := &Suggester{
+		Manager: &QLManager{Records: []QLRecord{
+			{Key: "beamline", Service: "MetaData"},
+			{Key: "sample", Service: "MetaData"},
+		}},
+		Distinct: func(key string) ([]any, error) {
+			if key == "beamline" {
+				<-block
+			}
+			return []any{"iron"}, nil
+		},
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Suggest("beamline:")
+	}()
+	time.Sleep(20 * time.Millisecond)
+	out := make(chan []Suggestion)
+	go func() { out <- s.Suggest("sample:") }()
+	select {
+	case got := <-out:
+		if len(got) != 1 || got[0].Query != "sample:iron" {
+			t.Errorf("wrong suggestions %+v", got)
+		}
+	case <-time.After(time.Second):
+		t.Error("suggestions are blocked by distinct values of other key")
+	}
+	close(block)
+	<-done
+}
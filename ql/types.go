@@ -0,0 +1,264 @@
+package ql
+
+// types module provides schema-aware coercion of QL query values
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	beamlines "github.com/CHESSComputing/golib/beamlines"
+	srvConfig "github.com/CHESSComputing/golib/config"
+)
+
+// ExactMatchKeys defines string keys whose values are matched exactly
+// instead of case-insensitive regex, keys of QL.ExactMatchKeys
+// configuration are matched exactly as well
+var ExactMatchKeys []string
+
+// DateLayouts defines layouts of date values converted into seconds since
+// epoch for keys with integer or date data types
+var DateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"20060102",
+}
+
+// keyRegistry represents query keys with their data types
+type keyRegistry struct {
+	types map[string]string // data types of keys, keys are in lower case
+	names SchemaKeys        // schema keys of lower case keys
+}
+
+// helper function to add keys with data types to the registry, keys without
+// data type keep their registered type or get string type
+func (r *keyRegistry) add(types map[string]string) {
+	if r.types == nil {
+		r.types = make(map[string]string)
+		r.names = make(SchemaKeys)
+	}
+	for key, dtype := range types {
+		if key == "" {
+			continue
+		}
+		lkey := strings.ToLower(key)
+		if _, ok := r.names[lkey]; !ok {
+			r.names[lkey] = key
+		}
+		// QL records may not define data type, keep type from schema if any
+		if dtype == "" || dtype == "N/A" {
+			if _, ok := r.types[lkey]; ok {
+				continue
+			}
+			dtype = "string"
+		}
+		r.types[lkey] = dtype
+	}
+}
+
+// helper function to copy the registry
+func (r *keyRegistry) clone() *keyRegistry {
+	reg := &keyRegistry{types: make(map[string]string), names: make(SchemaKeys)}
+	for lkey, dtype := range r.types {
+		reg.types[lkey] = dtype
+	}
+	for lkey, name := range r.names {
+		reg.names[lkey] = name
+	}
+	return reg
+}
+
+// keys registered by RegisterKeys, registry is replaced on each registration
+// and therefore it is not modified once it is used by queries
+var _keys = &keyRegistry{}
+var _keyMutex sync.RWMutex
+
+// RegisterKeys registers query keys with their data types, e.g. int64,
+// float, bool, string, date, list_str. Values of registered keys are
+// converted to their data types and queries with unknown keys are rejected.
+// Registered keys are known to ParseQuery and to all QL managers.
+func RegisterKeys(types map[string]string) {
+	_keyMutex.Lock()
+	defer _keyMutex.Unlock()
+	reg := _keys.clone()
+	reg.add(types)
+	_keys = reg
+}
+
+// RegisterSchemas registers keys of beamline schemas with their data types,
+// e.g. RegisterSchemas(smgr.MetaDetails())
+func RegisterSchemas(details []beamlines.SchemaDetails) {
+	for _, rec := range details {
+		RegisterKeys(rec.DataTypes)
+	}
+}
+
+// helper function to return keys registered by RegisterKeys
+func registeredKeys() *keyRegistry {
+	_keyMutex.RLock()
+	defer _keyMutex.RUnlock()
+	return _keys
+}
+
+// helper function to create registry of QL service map keys. Keys without
+// data type get type of registered key or string type.
+func newMapKeys(types map[string]string) *keyRegistry {
+	reg := &keyRegistry{types: make(map[string]string), names: make(SchemaKeys)}
+	for key, dtype := range types {
+		if key == "" {
+			continue
+		}
+		lkey := strings.ToLower(key)
+		reg.names[lkey] = key
+		if dtype == "N/A" {
+			dtype = ""
+		}
+		reg.types[lkey] = dtype
+	}
+	return reg
+}
+
+// queryKeys represents keys known to query parser, i.e. keys of QL service
+// map of QL manager and keys registered by RegisterKeys
+type queryKeys struct {
+	mapKeys *keyRegistry // keys of QL service map
+	keys    *keyRegistry // keys registered by RegisterKeys
+}
+
+// helper function to return query keys known without QL manager
+func defaultKeys() *queryKeys {
+	return &queryKeys{mapKeys: &keyRegistry{}, keys: registeredKeys()}
+}
+
+// helper function to return data type of the key and whether the key is
+// known, all keys are considered known if no keys are registered
+func (k *queryKeys) keyType(key string) (string, bool) {
+	if len(k.keys.types) == 0 && len(k.mapKeys.types) == 0 {
+		return "", true
+	}
+	if key == "did" || key == "_id" {
+		return "string", true
+	}
+	lkey := strings.ToLower(key)
+	if dtype, ok := k.mapKeys.types[lkey]; ok && dtype != "" {
+		return dtype, true
+	}
+	if dtype, ok := k.keys.types[lkey]; ok {
+		return dtype, true
+	}
+	if _, ok := k.mapKeys.types[lkey]; ok {
+		return "string", true
+	}
+	// nested attributes of known keys, e.g. scan.energy
+	for _, reg := range []*keyRegistry{k.mapKeys, k.keys} {
+		for key := range reg.types {
+			if strings.HasPrefix(lkey, key+".") {
+				return "", true
+			}
+		}
+	}
+	return "", false
+}
+
+// helper function to return schema key of query key
+func (k *queryKeys) schemaKey(key string) (string, bool) {
+	lkey := strings.ToLower(key)
+	for _, reg := range []*keyRegistry{k.mapKeys, k.keys} {
+		if name, ok := reg.names[lkey]; ok {
+			return name, true
+		}
+	}
+	return key, false
+}
+
+// helper function to check if values of string key should match exactly
+func exactMatch(key string) bool {
+	var keys []string
+	if srvConfig.Config != nil {
+		keys = srvConfig.Config.QL.ExactMatchKeys
+	}
+	for _, list := range [][]string{ExactMatchKeys, keys} {
+		for _, k := range list {
+			if strings.EqualFold(k, key) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// helper function to create error of unknown key
+func unknownKey(key string) error {
+	return fmt.Errorf("unknown query key '%s'", key)
+}
+
+// helper function to convert value of range or list into data type of the key
+func (k *queryKeys) typedValue(key, val string) (any, error) {
+	dtype, known := k.keyType(key)
+	if !known {
+		return nil, unknownKey(key)
+	}
+	if dtype == "" {
+		return number(val), nil
+	}
+	if stringType(dtype) {
+		return val, nil
+	}
+	v, err := coerce(dtype, val)
+	if err != nil {
+		return nil, fmt.Errorf("key '%s' of type %s: %w", key, dtype, err)
+	}
+	return v, nil
+}
+
+// helper function to convert string value into given data type, value of
+// list data types is converted into type of list elements
+func coerce(dtype, val string) (any, error) {
+	dtype = strings.TrimPrefix(strings.ToLower(dtype), "list_")
+	switch {
+	case dtype == "bool":
+		v, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, fmt.Errorf("value %q is not a boolean", val)
+		}
+		return v, nil
+	case strings.HasPrefix(dtype, "int"), dtype == "date", dtype == "time", dtype == "epoch":
+		if v, err := strconv.ParseInt(val, 10, 64); err == nil {
+			return v, nil
+		}
+		if v, ok := epoch(val); ok {
+			return v, nil
+		}
+		if strings.HasPrefix(dtype, "int") {
+			return nil, fmt.Errorf("value %q is not an integer or a date", val)
+		}
+		return nil, fmt.Errorf("value %q is not a date", val)
+	case strings.HasPrefix(dtype, "float"):
+		v, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("value %q is not a number", val)
+		}
+		return v, nil
+	}
+	return val, nil
+}
+
+// helper function to convert date into seconds since epoch
+func epoch(val string) (int64, bool) {
+	for _, layout := range DateLayouts {
+		if t, err := time.Parse(layout, val); err == nil {
+			return t.Unix(), true
+		}
+	}
+	return 0, false
+}
+
+// helper function to check if data type is string type
+func stringType(dtype string) bool {
+	dtype = strings.TrimPrefix(strings.ToLower(dtype), "list_")
+	return dtype == "" || strings.HasPrefix(dtype, "str")
+}
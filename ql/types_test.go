@@ -0,0 +1,88 @@
+package ql
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// helper function to reset registered keys
+func resetKeys() {
+	_keyMutex.Lock()
+	defer _keyMutex.Unlock()
+	_keys = &keyRegistry{}
+}
+
+// TestCoercion
+func TestCoercion(t *testing.T) {
+	t.Cleanup(resetKeys)
+	RegisterKeys(map[string]string{
+		"Calibration": "bool",
+		"Date":        "int64",
+		"Energy":      "float64",
+		"Beamline":    "list_str",
+		"Sample":      "string",
+		"Cycle":       "string",
+		"Runs":        "list_int",
+	})
+	ExactMatchKeys = []string{"cycle"}
+	defer func() { ExactMatchKeys = nil }()
+
+	tests := []struct {
+		query string
+		spec  map[string]any
+	}{
+		{"calibration:true", map[string]any{"Calibration": true}},
+		{"date:2024-03-01", map[string]any{"Date": int64(1709251200)}},
+		{"date:[2024-03-01 TO 1709337600}", map[string]any{"Date": map[string]any{"$gte": int64(1709251200), "$lt": int64(1709337600)}}},
+		{"energy:>10", map[string]any{"Energy": map[string]any{"$gt": 10.0}}},
+		{"runs:[1,2]", map[string]any{"Runs": map[string]any{"$in": []any{int64(1), int64(2)}}}},
+		{"runs:3", map[string]any{"Runs": int64(3)}},
+		{"beamline:[3a,4b]", map[string]any{"Beamline": map[string]any{"$in": []any{"3a", "4b"}}}},
+		{"sample:iron", map[string]any{"Sample": map[string]any{"$regex": "^iron$", "$options": "i"}}},
+		{"cycle:2024-3", map[string]any{"Cycle": "2024-3"}},
+		{"sample:[iron,Cu]", map[string]any{"Sample": map[string]any{"$in": []any{
+			bson.Regex{Pattern: "^iron$", Options: "i"}, bson.Regex{Pattern: "^Cu$", Options: "i"}}}}},
+		{`{"sample":["iron","Cu"]}`, map[string]any{"Sample": map[string]any{"$in": []any{
+			bson.Regex{Pattern: "^iron$", Options: "i"}, bson.Regex{Pattern: "^Cu$", Options: "i"}}}}},
+		{"cycle:[2024-3,2025-1]", map[string]any{"Cycle": map[string]any{"$in": []any{"2024-3", "2025-1"}}}},
+		{`{"cycle":["2024-3"],"runs":[1,2]}`, map[string]any{"Cycle": map[string]any{"$in": []any{"2024-3"}},
+			"Runs": map[string]any{"$in": []any{1.0, 2.0}}}},
+		{`{"cycle":"2024*","sample":["ir*","Cu"]}`, map[string]any{"Cycle": map[string]any{"$regex": "^2024.*$"},
+			"Sample": map[string]any{"$in": []any{bson.Regex{Pattern: "^ir.*$"}, bson.Regex{Pattern: "^Cu$", Options: "i"}}}}},
+		{`{"calibration":"false","did":"/beamline=3a"}`, map[string]any{"Calibration": false, "did": "/beamline=3a"}},
+	}
+	for _, tc := range tests {
+		spec, err := ParseQuery(tc.query)
+		if err != nil {
+			t.Errorf("query %s, error %v", tc.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(spec, tc.spec) {
+			t.Errorf("query %s, wrong spec %+v != %+v", tc.query, spec, tc.spec)
+		}
+	}
+
+	// wrong values and unknown keys
+	errorTests := []struct {
+		query  string
+		column int
+	}{
+		{"calibration:maybe", 1},
+		{"sample:iron energy:[a TO 1]", 13},
+		{"sample:iron foo:1", 13},
+		{"foo:*", 1},
+	}
+	for _, tc := range errorTests {
+		_, err := ParseQuery(tc.query)
+		var serr *SyntaxError
+		if !errors.As(err, &serr) || serr.Column() != tc.column {
+			t.Errorf("query %s, expected syntax error at position %d, got %v", tc.query, tc.column, err)
+		}
+	}
+	if _, err := ParseQuery(`{"foo":1}`); err == nil {
+		t.Error("expected error of unknown key of MongoDB spec")
+	}
+}
@@ -1,15 +1,19 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	srvConfig "github.com/CHESSComputing/golib/config"
+	mongo "github.com/CHESSComputing/golib/mongo"
+	ql "github.com/CHESSComputing/golib/ql"
 	"github.com/dchest/captcha"
 	"github.com/gin-gonic/gin"
 )
@@ -17,6 +21,12 @@ import (
 // Time0 represents initial time when we started the server
 var Time0 time.Time
 
+// QL manager used by QL handlers, if it is not set it is initialized from
+// QL.ServiceMapFile configuration on first use
+var qlMgr *ql.QLManager
+var qlCancel context.CancelFunc
+var qlMutex sync.Mutex
+
 // init function
 func init() {
 	Time0 = time.Now()
@@ -55,7 +65,7 @@ func MetricsHandler(c *gin.Context) {
 	c.Writer.Write([]byte(promMetrics(metricsPrefix)))
 }
 
-// HealthHandler provides metrics JSON for monitoring purposes (Prometheus)
+// HealthHandler provides health status of the service and its MongoDB connection
 func HealthHandler(webServer srvConfig.WebServer) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		name := "foxden-service"
@@ -63,12 +73,26 @@ func HealthHandler(webServer srvConfig.WebServer) gin.HandlerFunc {
 			name = webServer.Name
 		}
 
-		c.JSON(200, gin.H{
-			"status":  "ok",
+		status, code := "ok", http.StatusOK
+		health := gin.H{
 			"service": name,
 			"port":    webServer.Port,
 			"time":    time.Now().UTC(),
-		})
+		}
+		// report MongoDB health if service uses it
+		if mongo.Mongo.URI != "" {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+			defer cancel()
+			if err := mongo.Ping(ctx); err != nil {
+				log.Println("ERROR:", err)
+				status, code = "error", http.StatusServiceUnavailable
+				health["mongodb"] = gin.H{"status": "error", "error": err.Error()}
+			} else {
+				health["mongodb"] = gin.H{"status": "ok", "pool": mongo.Stats()}
+			}
+		}
+		health["status"] = status
+		c.JSON(code, health)
 	}
 }
 
@@ -109,3 +133,67 @@ func QLKeysHandler(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, keys)
 }
+
+// SetQLManager sets QL manager used by QL handlers, nil manager resets it
+// and QL manager is initialized again from configuration on next use
+func SetQLManager(mgr *ql.QLManager) {
+	qlMutex.Lock()
+	defer qlMutex.Unlock()
+	if qlCancel != nil {
+		// stop watcher of previous QL manager
+		qlCancel()
+		qlCancel = nil
+	}
+	qlMgr = mgr
+}
+
+// helper function to return QL manager, the service map file of QL manager
+// initialized from configuration is watched for changes during server lifetime
+func qlManager() *ql.QLManager {
+	qlMutex.Lock()
+	defer qlMutex.Unlock()
+	if qlMgr != nil {
+		return qlMgr
+	}
+	mgr := &ql.QLManager{}
+	if srvConfig.Config != nil && srvConfig.Config.QL.ServiceMapFile != "" {
+		fname := srvConfig.Config.QL.ServiceMapFile
+		if err := mgr.Init(fname); err != nil {
+			log.Println("ERROR:", err)
+		}
+		// watch file even if it is invalid since it can be fixed later
+		ctx, cancel := context.WithCancel(context.Background())
+		qlCancel = cancel
+		go func() {
+			if err := mgr.Watch(ctx, fname); err != nil {
+				log.Println("ERROR:", err)
+			}
+		}()
+	}
+	qlMgr = mgr
+	return qlMgr
+}
+
+// QLExplainHandler provides explanation of QL query given by query parameter,
+// i.e. parsed tokens, schema keys, per-service specs and dropped keys
+func QLExplainHandler(c *gin.Context) {
+	query := c.Query("query")
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing query parameter"})
+		return
+	}
+	c.JSON(http.StatusOK, qlManager().Explain(query))
+}
+
+// QLSuggestHandler provides suggestions of QL keys and values for partial
+// query given by query parameter, if suggester is nil it suggests keys of
+// QL manager only
+func QLSuggestHandler(suggester *ql.Suggester) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s := suggester
+		if s == nil {
+			s = &ql.Suggester{Manager: qlManager()}
+		}
+		c.JSON(http.StatusOK, s.Suggest(c.Query("query")))
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"runtime"
 	"time"
 
+	mongo "github.com/CHESSComputing/golib/mongo"
 	"github.com/shirou/gopsutil/cpu"
 	"github.com/shirou/gopsutil/load"
 	"github.com/shirou/gopsutil/mem"
@@ -102,6 +103,7 @@ type Metrics struct {
 	ProcFS             ProcFS                  `json:"procfs"`             // metrics from prometheus procfs
 	MaxDBConnections   uint64                  `json:"maxDBConnections"`   // max number of DB connections
 	MaxIdleConnections uint64                  `json:"maxIdleConnections"` // max number of idle DB connections
+	MongoDB            mongo.PoolStats         `json:"mongodb"`            // MongoDB connection pool metrics
 
 	// Migration server metrics
 	MigrationRequests   uint64 `json:"migrationRequests"`   // total number of migration requests across all services
@@ -163,6 +165,8 @@ func metrics() Metrics {
 	metrics.PostRequests = TotalPostRequests
 	metrics.PutRequests = TotalPutRequests
 
+	metrics.MongoDB = mongo.Stats()
+
 	lapse := time.Since(rstat.Time).Seconds()
 	total := float64(TotalGetRequests + TotalPostRequests + TotalPutRequests)
 	metrics.RPS = (total - float64(rstat.TotalGetRequests+rstat.TotalPostRequests+rstat.TotalPutRequests)) / lapse
@@ -336,6 +340,31 @@ func promMetrics(prefix string) string {
 	out += fmt.Sprintf("# TYPE %s_rps_logical_cpu gauge\n", prefix)
 	out += fmt.Sprintf("%s_rps_logical_cpu %v\n", prefix, data.RPSLogical)
 
+	// MongoDB connection pool metrics
+	out += fmt.Sprintf("# HELP %s_mongodb_open_connections reports number of open MongoDB connections\n", prefix)
+	out += fmt.Sprintf("# TYPE %s_mongodb_open_connections gauge\n", prefix)
+	out += fmt.Sprintf("%s_mongodb_open_connections %v\n", prefix, data.MongoDB.Open)
+
+	out += fmt.Sprintf("# HELP %s_mongodb_checked_out_connections reports number of MongoDB connections in use\n", prefix)
+	out += fmt.Sprintf("# TYPE %s_mongodb_checked_out_connections gauge\n", prefix)
+	out += fmt.Sprintf("%s_mongodb_checked_out_connections %v\n", prefix, data.MongoDB.CheckedOut)
+
+	out += fmt.Sprintf("# HELP %s_mongodb_checkouts reports total number of MongoDB connection check-outs\n", prefix)
+	out += fmt.Sprintf("# TYPE %s_mongodb_checkouts counter\n", prefix)
+	out += fmt.Sprintf("%s_mongodb_checkouts %v\n", prefix, data.MongoDB.CheckOuts)
+
+	out += fmt.Sprintf("# HELP %s_mongodb_checkout_failures reports total number of failed MongoDB connection check-outs\n", prefix)
+	out += fmt.Sprintf("# TYPE %s_mongodb_checkout_failures counter\n", prefix)
+	out += fmt.Sprintf("%s_mongodb_checkout_failures %v\n", prefix, data.MongoDB.CheckOutFailures)
+
+	out += fmt.Sprintf("# HELP %s_mongodb_wait_seconds reports total time spent waiting for MongoDB connections\n", prefix)
+	out += fmt.Sprintf("# TYPE %s_mongodb_wait_seconds counter\n", prefix)
+	out += fmt.Sprintf("%s_mongodb_wait_seconds %v\n", prefix, data.MongoDB.WaitTime.Seconds())
+
+	out += fmt.Sprintf("# HELP %s_mongodb_pool_cleared reports number of times MongoDB connection pool was cleared\n", prefix)
+	out += fmt.Sprintf("# TYPE %s_mongodb_pool_cleared counter\n", prefix)
+	out += fmt.Sprintf("%s_mongodb_pool_cleared %v\n", prefix, data.MongoDB.Cleared)
+
 	// migration server metrics
 	out += fmt.Sprintf("# HELP %s_requests reports total number of migration requests\n", prefix)
 	out += fmt.Sprintf("# TYPE %s_requests counter\n", prefix)
@@ -129,6 +129,8 @@ func Router(routes []Route, fsys fs.FS, static string, webServer srvConfig.WebSe
 	// GET routes
 	r.GET("/apis", ApisHandler)
 	r.GET("/qlkeys", QLKeysHandler)
+	r.GET("/qlexplain", QLExplainHandler)
+	r.GET("/qlsuggest", QLSuggestHandler(nil))
 	r.GET("/metrics", MetricsHandler)
 	r.GET("/health", HealthHandler(webServer))
 
@@ -1,8 +1,16 @@
 package server
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	mongo "github.com/CHESSComputing/golib/mongo"
+	ql "github.com/CHESSComputing/golib/ql"
+	"github.com/gin-gonic/gin"
 )
 
 // TestLogName
@@ -12,3 +20,68 @@ func TestLogName(t *testing.T) {
 		t.Error("Invalid log name", lname)
 	}
 }
+
+// TestHealthHandler tests health status with unreachable MongoDB
+func TestHealthHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/health", HealthHandler(srvConfig.WebServer{Name: "test"}))
+	for _, test := range []struct {
+		uri  string
+		code int
+	}{
+		{"", http.StatusOK},
+		{"mongodb://localhost:1/?serverSelectionTimeoutMS=100", http.StatusServiceUnavailable},
+	} {
+		mongo.InitMongoDB(test.uri)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+		if w.Code != test.code {
+			t.Errorf("uri %s, expected code %d, got %d: %s", test.uri, test.code, w.Code, w.Body.String())
+		}
+	}
+	mongo.InitMongoDB("")
+}
+
+// TestQLExplainHandler tests explanation of QL queries
+func TestQLExplainHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	SetQLManager(&ql.QLManager{Map: ql.ServiceMap{"MetaData": []string{"beamline"}}})
+	defer SetQLManager(nil)
+	r := gin.New()
+	r.GET("/qlexplain", QLExplainHandler)
+	for _, test := range []struct {
+		query string
+		code  int
+		body  string
+	}{
+		{"", http.StatusBadRequest, "missing query"},
+		{"beamline:3a", http.StatusOK, `"MetaData"`},
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/qlexplain?query="+url.QueryEscape(test.query), nil))
+		if w.Code != test.code || !strings.Contains(w.Body.String(), test.body) {
+			t.Errorf("query %s, unexpected response %d: %s", test.query, w.Code, w.Body.String())
+		}
+	}
+	// QL manager is initialized again after reset
+	SetQLManager(nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/qlexplain?query=beamline:3a", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("unexpected response after reset %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// TestQLSuggestHandler tests suggestions of QL keys
+func TestQLSuggestHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mgr := &ql.QLManager{Records: []ql.QLRecord{{Key: "beamline", Service: "MetaData"}}}
+	r := gin.New()
+	r.GET("/qlsuggest", QLSuggestHandler(&ql.Suggester{Manager: mgr}))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/qlsuggest?query=beam", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"beamline:"`) {
+		t.Errorf("unexpected response %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -26,6 +26,7 @@ type ServiceQuery struct {
 	Spec       map[string]any `json:"spec"`
 	Projection map[string]any `json:"projection"`
 	SQL        string         `json:"sql"`
+	Args       []any          `json:"args,omitempty"`
 	Idx        int            `json:"idx"`
 	Limit      int            `json:"limit"`
 	SortKeys   []string       `json:"sort_keys"`
@@ -51,6 +52,7 @@ func (s *ServiceRequest) String() string {
 	out += fmt.Sprintf("\n\tspec      : %+v", s.ServiceQuery.Spec)
 	out += fmt.Sprintf("\n\tprojection: %+v", s.ServiceQuery.Projection)
 	out += fmt.Sprintf("\n\tsql       : %s", s.ServiceQuery.SQL)
+	out += fmt.Sprintf("\n\targs      : %v", s.ServiceQuery.Args)
 	out += fmt.Sprintf("\n\tidx       : %d", s.ServiceQuery.Idx)
 	out += fmt.Sprintf("\n\tlimit     : %d", s.ServiceQuery.Limit)
 	out += fmt.Sprintf("\n\tsort key  : %v", s.ServiceQuery.SortKeys)
@@ -31,6 +31,7 @@ type HttpRequest struct {
 	Expires time.Time
 	Verbose int
 	Headers map[string][]string
+	Timeout time.Duration // timeout of HTTP requests, zero means no timeout
 }
 
 // NewHttpRequest initilizes and returns new HttpRequest object
@@ -129,7 +130,7 @@ func (h *HttpRequest) Get(rurl string) (*http.Response, error) {
 	if os.Getenv("FOXDEN_DEBUG") != "" || h.Verbose > 2 {
 		log.Printf("HTTP GET request to %s", rurl)
 	}
-	client := &http.Client{}
+	client := &http.Client{Timeout: h.Timeout}
 	if os.Getenv("FOXDEN_DEBUG") != "" || h.Verbose > 2 {
 		dump, err := httputil.DumpRequestOut(req, true)
 		log.Println("HttpRequest: GET request", string(dump), err)
@@ -200,7 +201,7 @@ func (h *HttpRequest) Request(method, rurl, contentType string, buffer *bytes.Bu
 	if os.Getenv("FOXDEN_DEBUG") != "" || h.Verbose > 2 {
 		log.Printf("HTTP %s request to %s", method, rurl)
 	}
-	client := &http.Client{}
+	client := &http.Client{Timeout: h.Timeout}
 	if os.Getenv("FOXDEN_DEBUG") != "" || h.Verbose > 2 {
 		dump, err := httputil.DumpRequestOut(req, true)
 		log.Printf("HttpRequest: %s request %s, error %v", method, string(dump), err)
@@ -229,7 +230,7 @@ func (h *HttpRequest) PostForm(rurl string, formData url.Values) (*http.Response
 	if os.Getenv("FOXDEN_DEBUG") != "" || h.Verbose > 2 {
 		log.Printf("HTTP POST request to %s", rurl)
 	}
-	client := &http.Client{}
+	client := &http.Client{Timeout: h.Timeout}
 	if os.Getenv("FOXDEN_DEBUG") != "" || h.Verbose > 2 {
 		dump, err := httputil.DumpRequestOut(req, true)
 		log.Println("HttpRequest: POST form request", string(dump), err)